@@ -0,0 +1,144 @@
+package carrier
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Trạng thái vận đơn đã chuẩn hóa (mỗi hãng tự map mã trạng thái riêng về các giá trị này)
+const (
+	StatusCreated        = "created"
+	StatusPickedUp       = "picked_up"
+	StatusInTransit      = "in_transit"
+	StatusDelivered      = "delivered"
+	StatusDeliveryFailed = "delivery_failed"
+	StatusReturned       = "returned"
+	StatusCancelled      = "cancelled"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid carrier signature")
+	ErrInvalidWebhook   = errors.New("invalid carrier webhook")
+	ErrNotCancellable   = errors.New("shipment can no longer be cancelled")
+)
+
+// ShipmentRequest - Thông tin tạo vận đơn cho 1 đơn hàng
+type ShipmentRequest struct {
+	OrderID       string
+	OrderNumber   string
+	RecipientName string
+	Phone         string
+	Address       string
+	ProvinceCode  string
+	DistrictCode  string
+	WardCode      string
+	WeightGrams   int
+	CODAmount     int64 // Tiền thu hộ (VND), 0 nếu đã thanh toán online
+	Note          string
+	Items         []ShipmentItem
+}
+
+// ShipmentItem - Dòng hàng in trên vận đơn
+type ShipmentItem struct {
+	Name string
+	Qty  int
+}
+
+// Shipment - Vận đơn hãng trả về
+type Shipment struct {
+	TrackingCode string
+	Fee          int64
+	Status       string
+}
+
+// Label - File nhãn vận đơn để in
+type Label struct {
+	ContentType string
+	Data        []byte
+}
+
+// TrackingEvent - 1 sự kiện hành trình hãng gửi qua webhook
+type TrackingEvent struct {
+	EventID      string
+	TrackingCode string
+	Status       string // Trạng thái chuẩn hóa (StatusPickedUp, StatusDelivered, ...)
+	RawStatus    string // Mã trạng thái gốc của hãng
+	Description  string
+	Location     string
+	At           time.Time
+}
+
+// Carrier - Hãng vận chuyển (GHN/GHTK/...): tạo, in nhãn, hủy vận đơn và nhận webhook hành trình
+type Carrier interface {
+	Name() string
+	CreateShipment(ctx context.Context, req ShipmentRequest) (Shipment, error)
+	GetLabel(ctx context.Context, trackingCode string) (Label, error)
+	CancelShipment(ctx context.Context, trackingCode string) error
+	ParseWebhook(r *http.Request) ([]TrackingEvent, error)
+}
+
+// FromEnv - Chọn các hãng vận chuyển theo CARRIERS (mặc định: không có hãng nào).
+// Hãng "fake" nhận webhook đổi trạng thái đơn nên phải bật rõ ràng và có FAKE_CARRIER_SECRET.
+func FromEnv() []Carrier {
+	names := os.Getenv("CARRIERS")
+	if names == "" {
+		log.Println("🚚 No carrier configured (CARRIERS)")
+	}
+
+	carriers := []Carrier{}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "fake":
+			secret := os.Getenv("FAKE_CARRIER_SECRET")
+			if secret == "" {
+				log.Println("❌ FAKE_CARRIER_SECRET not set, fake carrier disabled")
+				continue
+			}
+			baseURL := os.Getenv("FAKE_CARRIER_URL")
+			if baseURL == "" {
+				baseURL = "http://localhost:9090"
+			}
+			webhookURL := os.Getenv("CARRIER_WEBHOOK_BASE_URL")
+			if webhookURL == "" {
+				port := os.Getenv("PORT")
+				if port == "" {
+					port = "10000"
+				}
+				webhookURL = "http://localhost:" + port
+			}
+			log.Println("🚚 Carrier: fake @", baseURL)
+			carriers = append(carriers, &FakeCarrier{
+				BaseURL:    strings.TrimRight(baseURL, "/"),
+				Secret:     []byte(secret),
+				WebhookURL: strings.TrimRight(webhookURL, "/") + "/api/shipping/fake/webhook",
+			})
+		case "":
+		default:
+			log.Println("⚠️ Unknown carrier:", name)
+		}
+	}
+	return carriers
+}
+
+// SignBody - HMAC-SHA256 (hex) trên body webhook
+func SignBody(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyBody - So sánh chữ ký body theo thời gian hằng số
+func VerifyBody(secret, body []byte, signature string) bool {
+	if signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(SignBody(secret, body)))
+}
@@ -0,0 +1,238 @@
+package carrier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testSecret = []byte("secret")
+
+func TestVerifyBody(t *testing.T) {
+	body := []byte(`{"events":[]}`)
+	valid := "a642b59553c93e227ec0f2f38910fbf71231a2197c00899833c00478cec86f34"
+
+	if got := SignBody(testSecret, body); got != valid {
+		t.Fatalf("SignBody = %s, want %s", got, valid)
+	}
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		body      string
+		signature string
+		want      bool
+	}{
+		{"valid", testSecret, `{"events":[]}`, valid, true},
+		{"tampered body", testSecret, `{"events":[{}]}`, valid, false},
+		{"wrong secret", []byte("other"), `{"events":[]}`, valid, false},
+		{"missing signature", testSecret, `{"events":[]}`, "", false},
+		{"uppercase hex", testSecret, `{"events":[]}`, strings.ToUpper(valid), false},
+		{"truncated", testSecret, `{"events":[]}`, valid[:32], false},
+	}
+	for _, tt := range tests {
+		if got := VerifyBody(tt.secret, []byte(tt.body), tt.signature); got != tt.want {
+			t.Errorf("%s: VerifyBody = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseWebhook(t *testing.T) {
+	c := &FakeCarrier{Secret: testSecret}
+	tests := []struct {
+		name    string
+		body    string
+		sign    bool
+		want    []TrackingEvent
+		wantErr error
+	}{
+		{
+			name: "picked",
+			body: `{"events":[{"eventId":"FAKE1-1","trackingCode":"FAKE1","status":"picked","location":"Hà Nội"}]}`,
+			sign: true,
+			want: []TrackingEvent{{EventID: "FAKE1-1", TrackingCode: "FAKE1", Status: StatusPickedUp, RawStatus: "picked", Location: "Hà Nội"}},
+		},
+		{
+			name: "delivering maps to in transit",
+			body: `{"events":[{"eventId":"FAKE1-2","trackingCode":"FAKE1","status":"delivering"}]}`,
+			sign: true,
+			want: []TrackingEvent{{EventID: "FAKE1-2", TrackingCode: "FAKE1", Status: StatusInTransit, RawStatus: "delivering"}},
+		},
+		{"unsigned", `{"events":[]}`, false, nil, ErrInvalidSignature},
+		{"unknown status", `{"events":[{"eventId":"1","trackingCode":"FAKE1","status":"lost"}]}`, true, nil, ErrInvalidWebhook},
+		{"missing event id", `{"events":[{"trackingCode":"FAKE1","status":"picked"}]}`, true, nil, ErrInvalidWebhook},
+		{"not json", `events`, true, nil, ErrInvalidWebhook},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/shipping/fake/webhook", strings.NewReader(tt.body))
+		if tt.sign {
+			r.Header.Set(SignatureHeader, SignBody(testSecret, []byte(tt.body)))
+		}
+		got, err := c.ParseWebhook(r)
+		if err != tt.wantErr {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d events, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: event %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestFakeFee(t *testing.T) {
+	tests := []struct {
+		weight int
+		want   int64
+	}{
+		{0, 20000},
+		{500, 20000},
+		{501, 25000},
+		{1000, 25000},
+		{1200, 30000},
+	}
+	for _, tt := range tests {
+		if got := fakeFee(tt.weight); got != tt.want {
+			t.Errorf("fakeFee(%d) = %d, want %d", tt.weight, got, tt.want)
+		}
+	}
+}
+
+// TestFakeCarrierFlow - Client FakeCarrier chạy với FakeServer: tạo vận đơn, in nhãn, webhook đã ký, hủy
+func TestFakeCarrierFlow(t *testing.T) {
+	ctx := context.Background()
+
+	var c *FakeCarrier
+	received := make(chan []TrackingEvent, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events, err := c.ParseWebhook(r)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		received <- events
+	}))
+	defer backend.Close()
+
+	server := httptest.NewServer(NewFakeServer(testSecret).Handler())
+	defer server.Close()
+
+	c = &FakeCarrier{BaseURL: server.URL, Secret: testSecret, WebhookURL: backend.URL}
+
+	req := ShipmentRequest{
+		OrderID: "abc", OrderNumber: "GS-0001", RecipientName: "Nguyễn Văn A", Phone: "0900000000",
+		Address: "1 Lê Lợi, Quận 1", WeightGrams: 1200, CODAmount: 350000,
+		Items: []ShipmentItem{{Name: "Giày chạy bộ", Qty: 1}},
+	}
+	shipment, err := c.CreateShipment(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(shipment.TrackingCode, "FAKE") || shipment.Status != StatusCreated || shipment.Fee != 30000 {
+		t.Fatalf("CreateShipment = %+v", shipment)
+	}
+
+	label, err := c.GetLabel(ctx, shipment.TrackingCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(label.ContentType, "text/plain") || !strings.Contains(string(label.Data), shipment.TrackingCode) {
+		t.Errorf("GetLabel = %s %q", label.ContentType, label.Data)
+	}
+	if _, err := c.GetLabel(ctx, "FAKE-MISSING"); err == nil {
+		t.Error("GetLabel for unknown shipment succeeded")
+	}
+
+	resp, err := http.Post(server.URL+"/shipments/"+shipment.TrackingCode+"/events", "application/json",
+		strings.NewReader(`{"status":"picked"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		WebhookDelivered bool `json:"webhookDelivered"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if !result.WebhookDelivered {
+		t.Fatal("webhook was not accepted by the backend")
+	}
+	events := <-received
+	if len(events) != 1 || events[0].Status != StatusPickedUp || events[0].TrackingCode != shipment.TrackingCode {
+		t.Errorf("webhook events = %+v", events)
+	}
+
+	if err := c.CancelShipment(ctx, shipment.TrackingCode); err != ErrNotCancellable {
+		t.Errorf("CancelShipment after pickup = %v, want %v", err, ErrNotCancellable)
+	}
+
+	other, err := c.CreateShipment(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CancelShipment(ctx, other.TrackingCode); err != nil {
+		t.Errorf("CancelShipment before pickup = %v", err)
+	}
+}
+
+// TestFakeCarrierWrongSecret - Webhook ký bằng secret khác bị backend từ chối
+func TestFakeCarrierWrongSecret(t *testing.T) {
+	c := &FakeCarrier{Secret: testSecret}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := c.ParseWebhook(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer backend.Close()
+
+	server := httptest.NewServer(NewFakeServer([]byte("other")).Handler())
+	defer server.Close()
+	c.BaseURL, c.WebhookURL = server.URL, backend.URL
+
+	shipment, err := c.CreateShipment(context.Background(), ShipmentRequest{OrderID: "abc", Address: "1 Lê Lợi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Post(server.URL+"/shipments/"+shipment.TrackingCode+"/events", "application/json",
+		strings.NewReader(`{"status":"delivered"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result struct {
+		WebhookStatus    int  `json:"webhookStatus"`
+		WebhookDelivered bool `json:"webhookDelivered"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if result.WebhookDelivered || result.WebhookStatus != http.StatusUnauthorized {
+		t.Errorf("webhook with wrong secret = %+v, want 401", result)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		carriers string
+		secret   string
+		want     int
+	}{
+		{"none by default", "", "", 0},
+		{"fake without secret", "fake", "", 0},
+		{"fake with secret", "fake", "s3cret", 1},
+		{"unknown", "ghn", "s3cret", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("CARRIERS", tt.carriers)
+		t.Setenv("FAKE_CARRIER_SECRET", tt.secret)
+		if got := len(FromEnv()); got != tt.want {
+			t.Errorf("%s: %d carriers, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package carrier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// SignatureHeader - Header chứa chữ ký HMAC của webhook fake carrier
+const SignatureHeader = "X-Carrier-Signature"
+
+// fakeStatuses - Mã trạng thái kiểu GHN của fake carrier -> trạng thái chuẩn hóa
+var fakeStatuses = map[string]string{
+	"ready_to_pick": StatusCreated,
+	"picked":        StatusPickedUp,
+	"storing":       StatusInTransit,
+	"transporting":  StatusInTransit,
+	"delivering":    StatusInTransit,
+	"delivered":     StatusDelivered,
+	"delivery_fail": StatusDeliveryFailed,
+	"return":        StatusReturned,
+	"returned":      StatusReturned,
+	"cancel":        StatusCancelled,
+}
+
+// FakeCarrier - Client cho fake carrier server (cmd/fakecarrier), API giống hãng thật nhưng chạy local
+type FakeCarrier struct {
+	BaseURL    string
+	Secret     []byte
+	WebhookURL string // URL backend nhận webhook hành trình
+	Client     *http.Client
+}
+
+func (c *FakeCarrier) Name() string {
+	return "fake"
+}
+
+func (c *FakeCarrier) client() *http.Client {
+	if c.Client != nil {
+		return c.Client
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// fakeShipmentPayload - Body tạo vận đơn gửi fake carrier
+type fakeShipmentPayload struct {
+	OrderID       string         `json:"orderId"`
+	OrderNumber   string         `json:"orderNumber"`
+	RecipientName string         `json:"recipientName"`
+	Phone         string         `json:"phone"`
+	Address       string         `json:"address"`
+	ProvinceCode  string         `json:"provinceCode,omitempty"`
+	DistrictCode  string         `json:"districtCode,omitempty"`
+	WardCode      string         `json:"wardCode,omitempty"`
+	Weight        int            `json:"weight"`
+	CODAmount     int64          `json:"codAmount"`
+	Note          string         `json:"note,omitempty"`
+	Items         []ShipmentItem `json:"items"`
+	WebhookURL    string         `json:"webhookUrl"`
+}
+
+type fakeShipment struct {
+	fakeShipmentPayload
+	TrackingCode string      `json:"trackingCode"`
+	Fee          int64       `json:"fee"`
+	Status       string      `json:"status"`
+	Events       []fakeEvent `json:"events"`
+	CreatedAt    time.Time   `json:"createdAt"`
+}
+
+type fakeEvent struct {
+	EventID      string    `json:"eventId"`
+	TrackingCode string    `json:"trackingCode"`
+	Status       string    `json:"status"`
+	Description  string    `json:"description,omitempty"`
+	Location     string    `json:"location,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+type fakeWebhook struct {
+	Events []fakeEvent `json:"events"`
+}
+
+func (c *FakeCarrier) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusConflict {
+		return ErrNotCancellable
+	}
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("fake carrier %s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+func (c *FakeCarrier) CreateShipment(ctx context.Context, req ShipmentRequest) (Shipment, error) {
+	var created fakeShipment
+	err := c.do(ctx, http.MethodPost, "/shipments", fakeShipmentPayload{
+		OrderID:       req.OrderID,
+		OrderNumber:   req.OrderNumber,
+		RecipientName: req.RecipientName,
+		Phone:         req.Phone,
+		Address:       req.Address,
+		ProvinceCode:  req.ProvinceCode,
+		DistrictCode:  req.DistrictCode,
+		WardCode:      req.WardCode,
+		Weight:        req.WeightGrams,
+		CODAmount:     req.CODAmount,
+		Note:          req.Note,
+		Items:         req.Items,
+		WebhookURL:    c.WebhookURL,
+	}, &created)
+	if err != nil {
+		return Shipment{}, err
+	}
+
+	return Shipment{
+		TrackingCode: created.TrackingCode,
+		Fee:          created.Fee,
+		Status:       fakeStatuses[created.Status],
+	}, nil
+}
+
+func (c *FakeCarrier) GetLabel(ctx context.Context, trackingCode string) (Label, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/shipments/"+trackingCode+"/label", nil)
+	if err != nil {
+		return Label{}, err
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return Label{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Label{}, fmt.Errorf("fake carrier label %s: %d", trackingCode, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Label{}, err
+	}
+	return Label{ContentType: resp.Header.Get("Content-Type"), Data: data}, nil
+}
+
+func (c *FakeCarrier) CancelShipment(ctx context.Context, trackingCode string) error {
+	return c.do(ctx, http.MethodDelete, "/shipments/"+trackingCode, nil, nil)
+}
+
+// ParseWebhook - Kiểm tra chữ ký body rồi map trạng thái của fake carrier về trạng thái chuẩn
+func (c *FakeCarrier) ParseWebhook(r *http.Request) ([]TrackingEvent, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, ErrInvalidWebhook
+	}
+	if !VerifyBody(c.Secret, body, r.Header.Get(SignatureHeader)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var payload fakeWebhook
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, ErrInvalidWebhook
+	}
+
+	events := make([]TrackingEvent, 0, len(payload.Events))
+	for _, e := range payload.Events {
+		status, ok := fakeStatuses[e.Status]
+		if !ok || e.TrackingCode == "" || e.EventID == "" {
+			return nil, ErrInvalidWebhook
+		}
+		events = append(events, TrackingEvent{
+			EventID:      e.EventID,
+			TrackingCode: e.TrackingCode,
+			Status:       status,
+			RawStatus:    e.Status,
+			Description:  e.Description,
+			Location:     e.Location,
+			At:           e.At,
+		})
+	}
+	return events, nil
+}
+
+// FakeServer - Fake carrier chạy local (in-memory) cho dev/test:
+//
+//	POST   /shipments                    tạo vận đơn
+//	GET    /shipments/{code}             xem vận đơn và hành trình
+//	GET    /shipments/{code}/label       nhãn vận đơn (text)
+//	DELETE /shipments/{code}             hủy (chỉ khi chưa lấy hàng)
+//	POST   /shipments/{code}/events      giả lập sự kiện {"status":"picked"} và gửi webhook đã ký
+type FakeServer struct {
+	Secret []byte
+	Client *http.Client
+
+	mu        sync.Mutex
+	seq       int
+	shipments map[string]*fakeShipment
+}
+
+// NewFakeServer - Tạo fake carrier ký webhook bằng secret (phải trùng FAKE_CARRIER_SECRET của backend)
+func NewFakeServer(secret []byte) *FakeServer {
+	return &FakeServer{
+		Secret:    secret,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+		shipments: map[string]*fakeShipment{},
+	}
+}
+
+// Handler - Router HTTP của fake carrier
+func (s *FakeServer) Handler() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/shipments", s.create).Methods("POST")
+	r.HandleFunc("/shipments/{code}", s.get).Methods("GET")
+	r.HandleFunc("/shipments/{code}/label", s.label).Methods("GET")
+	r.HandleFunc("/shipments/{code}", s.cancel).Methods("DELETE")
+	r.HandleFunc("/shipments/{code}/events", s.event).Methods("POST")
+	return r
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// fakeFee - Phí giả lập: 20.000đ cho 500g đầu, mỗi 500g tiếp theo thêm 5.000đ
+func fakeFee(weight int) int64 {
+	fee := int64(20000)
+	if weight > 500 {
+		fee += int64((weight-500+499)/500) * 5000
+	}
+	return fee
+}
+
+func (s *FakeServer) create(w http.ResponseWriter, r *http.Request) {
+	var payload fakeShipmentPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.OrderID == "" || payload.Address == "" {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid shipment"})
+		return
+	}
+
+	s.mu.Lock()
+	s.seq++
+	code := fmt.Sprintf("FAKE%s%05d", time.Now().Format("0102"), s.seq)
+	shipment := &fakeShipment{
+		fakeShipmentPayload: payload,
+		TrackingCode:        code,
+		Fee:                 fakeFee(payload.Weight),
+		Status:              "ready_to_pick",
+		Events:              []fakeEvent{},
+		CreatedAt:           time.Now(),
+	}
+	s.shipments[code] = shipment
+	s.mu.Unlock()
+
+	log.Printf("🚚 [fake carrier] shipment %s created for order %s\n", code, payload.OrderNumber)
+	writeFakeJSON(w, http.StatusCreated, shipment)
+}
+
+func (s *FakeServer) find(w http.ResponseWriter, r *http.Request) (*fakeShipment, bool) {
+	shipment, ok := s.shipments[mux.Vars(r)["code"]]
+	if !ok {
+		writeFakeJSON(w, http.StatusNotFound, map[string]string{"error": "shipment not found"})
+	}
+	return shipment, ok
+}
+
+func (s *FakeServer) get(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if shipment, ok := s.find(w, r); ok {
+		writeFakeJSON(w, http.StatusOK, shipment)
+	}
+}
+
+func (s *FakeServer) label(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shipment, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "FAKE CARRIER - %s\n", shipment.TrackingCode)
+	fmt.Fprintf(&b, "Order: %s\n", shipment.OrderNumber)
+	fmt.Fprintf(&b, "To: %s - %s\n%s\n", shipment.RecipientName, shipment.Phone, shipment.Address)
+	fmt.Fprintf(&b, "Weight: %dg  COD: %d VND\n", shipment.Weight, shipment.CODAmount)
+	for _, item := range shipment.Items {
+		fmt.Fprintf(&b, "  %d x %s\n", item.Qty, item.Name)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+func (s *FakeServer) cancel(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	shipment, ok := s.find(w, r)
+	if !ok {
+		return
+	}
+	if shipment.Status != "ready_to_pick" {
+		writeFakeJSON(w, http.StatusConflict, map[string]string{"error": "shipment already picked up"})
+		return
+	}
+	shipment.Status = "cancel"
+	writeFakeJSON(w, http.StatusOK, shipment)
+}
+
+// event - Giả lập hãng cập nhật hành trình và gửi webhook về backend
+func (s *FakeServer) event(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status      string `json:"status"`
+		Description string `json:"description"`
+		Location    string `json:"location"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid event"})
+		return
+	}
+	if _, ok := fakeStatuses[req.Status]; !ok {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown status " + req.Status})
+		return
+	}
+
+	s.mu.Lock()
+	shipment, ok := s.find(w, r)
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	event := fakeEvent{
+		EventID:      fmt.Sprintf("%s-%d", shipment.TrackingCode, len(shipment.Events)+1),
+		TrackingCode: shipment.TrackingCode,
+		Status:       req.Status,
+		Description:  req.Description,
+		Location:     req.Location,
+		At:           time.Now(),
+	}
+	shipment.Status = req.Status
+	shipment.Events = append(shipment.Events, event)
+	webhookURL := shipment.WebhookURL
+	s.mu.Unlock()
+
+	delivered, err := s.sendWebhook(r.Context(), webhookURL, fakeWebhook{Events: []fakeEvent{event}})
+	if err != nil {
+		log.Printf("⚠️ [fake carrier] webhook for %s failed: %v\n", event.TrackingCode, err)
+	}
+	writeFakeJSON(w, http.StatusOK, map[string]interface{}{
+		"event":            event,
+		"webhookStatus":    delivered,
+		"webhookDelivered": err == nil,
+	})
+}
+
+func (s *FakeServer) sendWebhook(ctx context.Context, webhookURL string, payload fakeWebhook) (int, error) {
+	if webhookURL == "" {
+		return 0, errors.New("no webhook url")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, SignBody(s.Secret, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
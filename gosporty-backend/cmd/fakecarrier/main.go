@@ -0,0 +1,33 @@
+// Command fakecarrier - Hãng vận chuyển giả lập chạy local để test luồng tạo vận đơn và webhook hành trình.
+//
+//	FAKE_CARRIER_SECRET=... FAKE_CARRIER_ADDR=:9090 go run ./cmd/fakecarrier
+//
+// Giả lập hãng cập nhật trạng thái (gửi webhook đã ký về backend):
+//
+//	curl -X POST localhost:9090/shipments/{code}/events -d '{"status":"picked"}'
+//	curl -X POST localhost:9090/shipments/{code}/events -d '{"status":"delivered"}'
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"gosporty-backend/carrier"
+)
+
+func main() {
+	secret := os.Getenv("FAKE_CARRIER_SECRET")
+	if secret == "" {
+		log.Fatal("❌ FAKE_CARRIER_SECRET is required (must match the backend)")
+	}
+
+	addr := os.Getenv("FAKE_CARRIER_ADDR")
+	if addr == "" {
+		addr = ":9090"
+	}
+
+	server := carrier.NewFakeServer([]byte(secret))
+	log.Println("🚚 Fake carrier listening on", addr)
+	log.Fatal(http.ListenAndServe(addr, server.Handler()))
+}
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+)
+
+// utf8BOM - Để Excel mở file CSV tiếng Việt đúng bảng mã
+const utf8BOM = "\uFEFF"
+
+type csvWriter struct {
+	w       *csv.Writer
+	started bool
+	out     io.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w), out: w}
+}
+
+func (c *csvWriter) Write(row []interface{}) error {
+	if !c.started {
+		c.started = true
+		if _, err := io.WriteString(c.out, utf8BOM); err != nil {
+			return err
+		}
+	}
+
+	record := make([]string, len(row))
+	for i, v := range row {
+		record[i] = text(v)
+		if _, ok := v.(string); ok {
+			record[i] = escapeFormula(record[i])
+		}
+	}
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+	return c.w.Error()
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	return c.Flush()
+}
+
+// escapeFormula - Chặn CSV injection: chuỗi bắt đầu bằng = + - @ bị Excel hiểu là công thức
+func escapeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
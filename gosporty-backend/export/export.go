@@ -0,0 +1,118 @@
+// Package export - Ghi bảng dữ liệu ra CSV / XLSX theo kiểu stream (ghi từng dòng, không giữ cả file trong bộ nhớ).
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format - Định dạng file xuất
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// ParseFormat - "csv" (mặc định khi rỗng) | "xlsx"
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(value))) {
+	case "", CSV:
+		return CSV, nil
+	case XLSX:
+		return XLSX, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ContentType - MIME type của file
+func (f Format) ContentType() string {
+	if f == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Extension - Đuôi file
+func (f Format) Extension() string {
+	return "." + string(f)
+}
+
+// Writer - Ghi từng dòng; Flush đẩy phần đã ghi xuống w, Close hoàn tất file (XLSX chỉ hợp lệ sau khi Close)
+//
+// Giá trị ô: string, int, int64, float64, bool, time.Time, *time.Time hoặc nil (ô trống).
+// Số giữ kiểu số trong XLSX để cộng/lọc được trên Excel.
+type Writer interface {
+	Write(row []interface{}) error
+	Flush() error
+	Close() error
+}
+
+// NewWriter - Writer theo định dạng, header là dòng tiêu đề cột
+func NewWriter(w io.Writer, format Format, sheet string, header []string) (Writer, error) {
+	var out Writer
+	switch format {
+	case CSV:
+		out = newCSVWriter(w)
+	case XLSX:
+		x, err := newXLSXWriter(w, sheet)
+		if err != nil {
+			return nil, err
+		}
+		out = x
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	row := make([]interface{}, len(header))
+	for i, h := range header {
+		row[i] = h
+	}
+	if err := out.Write(row); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Location - Múi giờ hiển thị ngày giờ trong file (giờ Việt Nam)
+var Location = time.FixedZone("ICT", 7*60*60)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// text - Giá trị ô dạng chuỗi (CSV, hoặc kiểu XLSX không hỗ trợ dạng số)
+func text(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case int:
+		return strconv.Itoa(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		if x {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		if x.IsZero() {
+			return ""
+		}
+		return x.In(Location).Format(timeLayout)
+	case *time.Time:
+		if x == nil {
+			return ""
+		}
+		return text(*x)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,235 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRows = [][]interface{}{
+	{"GS-0001", "Nguyễn Văn A", 2, int64(1500000), 1250000.5, true, time.Date(2026, 10, 1, 2, 30, 0, 0, time.UTC)},
+	{"=HYPERLINK(\"x\")", "", nil, 0, 0.0, false, (*time.Time)(nil)},
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Format
+		err   error
+	}{
+		{"", CSV, nil},
+		{"csv", CSV, nil},
+		{" XLSX ", XLSX, nil},
+		{"pdf", "", ErrUnknownFormat},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.value)
+		if got != tt.want || err != tt.err {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q, %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, CSV, "orders", []string{"Mã đơn", "Khách hàng", "SL", "Tổng", "Giá", "Đã trả", "Ngày"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range testRows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, utf8BOM) {
+		t.Fatal("missing UTF-8 BOM")
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(out, utf8BOM))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"Mã đơn", "Khách hàng", "SL", "Tổng", "Giá", "Đã trả", "Ngày"},
+		{"GS-0001", "Nguyễn Văn A", "2", "1500000", "1250000.5", "TRUE", "2026-10-01 09:30:00"},
+		{"'=HYPERLINK(\"x\")", "", "", "0", "0", "FALSE", ""},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"abc", "abc"},
+		{"=1+1", "'=1+1"},
+		{"+84900000000", "'+84900000000"},
+		{"-5", "'-5"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tx", "'\tx"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := escapeFormula(tt.in); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{51, "AZ"},
+		{52, "BA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+	}
+	for _, tt := range tests {
+		if got := columnName(tt.i); got != tt.want {
+			t.Errorf("columnName(%d) = %q, want %q", tt.i, got, tt.want)
+		}
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"orders", "orders"},
+		{"  ", "Sheet1"},
+		{"a/b:c?d*e[f]g\\h", "a_b_c_d_e_f_g_h"},
+		{strings.Repeat("đ", 40), strings.Repeat("đ", 31)},
+	}
+	for _, tt := range tests {
+		if got := sheetName(tt.in); got != tt.want {
+			t.Errorf("sheetName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// xlsxCell - 1 ô trong sheet1.xml
+type xlsxCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Style  string `xml:"s,attr"`
+	Value  string `xml:"v"`
+	Inline string `xml:"is>t"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Ref   string     `xml:"r,attr"`
+		Cells []xlsxCell `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, XLSX, "Đơn hàng", []string{"Mã đơn", "Khách hàng", "SL", "Tổng", "Giá", "Đã trả", "Ngày"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range testRows {
+		if err := w.Write(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := map[string][]byte{}
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		data, ok := parts[name]
+		if !ok {
+			t.Fatalf("missing part %s", name)
+		}
+		if err := xml.Unmarshal(data, new(interface{})); err != nil {
+			t.Errorf("%s is not well-formed XML: %v", name, err)
+		}
+	}
+	if !bytes.Contains(parts["xl/workbook.xml"], []byte(`name="Đơn hàng"`)) {
+		t.Error("workbook missing sheet name")
+	}
+
+	var sheet xlsxSheet
+	if err := xml.Unmarshal(parts["xl/worksheets/sheet1.xml"], &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Rows) != 3 {
+		t.Fatalf("%d rows, want 3", len(sheet.Rows))
+	}
+	for _, c := range sheet.Rows[0].Cells {
+		if c.Style != "1" || c.Type != "inlineStr" {
+			t.Errorf("header cell %s = %+v, want bold inline string", c.Ref, c)
+		}
+	}
+
+	want := []xlsxCell{
+		{Ref: "A2", Type: "inlineStr", Inline: "GS-0001"},
+		{Ref: "B2", Type: "inlineStr", Inline: "Nguyễn Văn A"},
+		{Ref: "C2", Value: "2"},
+		{Ref: "D2", Value: "1500000"},
+		{Ref: "E2", Value: "1250000.5"},
+		{Ref: "F2", Type: "b", Value: "1"},
+		{Ref: "G2", Type: "inlineStr", Inline: "2026-10-01 09:30:00"},
+	}
+	if got := sheet.Rows[1].Cells; !reflect.DeepEqual(got, want) {
+		t.Errorf("row 2 = %+v, want %+v", got, want)
+	}
+
+	// Ô rỗng / nil bị bỏ qua; chuỗi giữ nguyên vì XLSX inline string không phải công thức
+	want = []xlsxCell{
+		{Ref: "A3", Type: "inlineStr", Inline: "=HYPERLINK(\"x\")"},
+		{Ref: "D3", Value: "0"},
+		{Ref: "E3", Value: "0"},
+		{Ref: "F3", Type: "b", Value: "0"},
+	}
+	if got := sheet.Rows[2].Cells; !reflect.DeepEqual(got, want) {
+		t.Errorf("row 3 = %+v, want %+v", got, want)
+	}
+}
+
+func TestXLSXRowLimit(t *testing.T) {
+	x, err := newXLSXWriter(io.Discard, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.rows = maxXLSXRows - 1
+	if err := x.Write([]interface{}{"last"}); err != nil {
+		t.Fatalf("row %d: %v", maxXLSXRows, err)
+	}
+	if err := x.Write([]interface{}{"overflow"}); err != ErrTooManyRows {
+		t.Errorf("row %d: err = %v, want %v", maxXLSXRows+1, err, ErrTooManyRows)
+	}
+}
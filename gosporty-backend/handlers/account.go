@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+
+	"gosporty-backend/database"
+	"gosporty-backend/mailer"
+)
+
+// Trạng thái tài khoản
+const (
+	UserStatusActive     = "active"
+	UserStatusUnverified = "unverified" // Đã đăng ký nhưng chưa xác nhận email
+	UserStatusSuspended  = "suspended"  // Bị admin khóa
+	UserStatusDeleted    = "deleted"    // Đã xóa mềm
+)
+
+// Mục đích của token gửi qua email
+const (
+	tokenPurposePasswordReset = "password_reset"
+	tokenPurposeEmailVerify   = "email_verify"
+)
+
+const (
+	passwordResetTTL = 1 * time.Hour
+	emailVerifyTTL   = 48 * time.Hour
+	minPasswordLen   = 6
+)
+
+var errInvalidAccountToken = errors.New("invalid or expired token")
+
+// UserToken - Token 1 lần dùng (reset mật khẩu / xác nhận email), chỉ lưu hash
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId"`
+	Purpose   string             `bson:"purpose"`
+	TokenHash string             `bson:"tokenHash"`
+	CreatedAt time.Time          `bson:"createdAt"`
+	ExpiresAt time.Time          `bson:"expiresAt"`
+	UsedAt    *time.Time         `bson:"usedAt,omitempty"`
+}
+
+var appMailer mailer.Mailer = &mailer.StdoutMailer{}
+
+// SetMailer - Cấu hình mailer dùng cho toàn bộ handlers
+func SetMailer(m mailer.Mailer) {
+	appMailer = m
+}
+
+// InitUserTokenCollection - Tạo index cho user_tokens
+func InitUserTokenCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("user_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "purpose", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create user token index:", err)
+	} else {
+		log.Println("✅ User token collection initialized with index")
+	}
+}
+
+// clientURL - URL frontend dùng để tạo link trong email
+func clientURL() string {
+	url := os.Getenv("CLIENT_URL")
+	if url == "" {
+		url = "http://localhost:3000"
+	}
+	return strings.TrimRight(url, "/")
+}
+
+// createUserToken - Tạo token mới, vô hiệu các token cùng mục đích chưa dùng trước đó
+func createUserToken(ctx context.Context, userID primitive.ObjectID, purpose string, ttl time.Duration) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	coll := database.DB.Collection("user_tokens")
+	now := time.Now()
+
+	_, err = coll.UpdateMany(ctx,
+		bson.M{"userId": userID, "purpose": purpose, "usedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"usedAt": now}},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = coll.InsertOne(ctx, UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(token),
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken - Đánh dấu token đã dùng (nguyên tử) và trả về userId
+func consumeUserToken(ctx context.Context, token, purpose string) (primitive.ObjectID, error) {
+	now := time.Now()
+
+	var doc UserToken
+	err := database.DB.Collection("user_tokens").FindOneAndUpdate(ctx,
+		bson.M{
+			"tokenHash": hashToken(token),
+			"purpose":   purpose,
+			"usedAt":    bson.M{"$exists": false},
+			"expiresAt": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"usedAt": now}},
+	).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, errInvalidAccountToken
+	}
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	return doc.UserID, nil
+}
+
+// sendVerificationEmail - Gửi link xác nhận email cho user mới đăng ký
+func sendVerificationEmail(ctx context.Context, user User) error {
+	token, err := createUserToken(ctx, user.ID, tokenPurposeEmailVerify, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", clientURL(), token)
+	return appMailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Xác nhận email tài khoản GoSporty",
+		Text: fmt.Sprintf("Xin chào %s,\n\nVui lòng xác nhận email bằng cách mở link sau (hiệu lực 48 giờ):\n%s\n\nGoSporty",
+			user.Name, link),
+		HTML: fmt.Sprintf(`<p>Xin chào %s,</p><p>Vui lòng <a href="%s">xác nhận email</a> của bạn (hiệu lực 48 giờ).</p><p>GoSporty</p>`,
+			htmlEscape(user.Name), link),
+	})
+}
+
+// htmlEscape - Escape chuỗi do người dùng nhập trước khi đưa vào email HTML
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&#39;").Replace(s)
+}
+
+// ForgotPasswordHandler - Gửi email reset mật khẩu
+// Luôn trả về 200 để không lộ email nào đã đăng ký
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Email required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user User
+	err := database.DB.Collection("users").FindOne(ctx, bson.M{"email": req.Email}).Decode(&user)
+	if err == nil {
+		token, err := createUserToken(ctx, user.ID, tokenPurposePasswordReset, passwordResetTTL)
+		if err != nil {
+			log.Println("❌ Failed to create reset token:", err)
+		} else {
+			link := fmt.Sprintf("%s/reset-password?token=%s", clientURL(), token)
+			err = appMailer.Send(ctx, mailer.Message{
+				To:      user.Email,
+				Subject: "Đặt lại mật khẩu GoSporty",
+				Text: fmt.Sprintf("Xin chào %s,\n\nMở link sau để đặt lại mật khẩu (hiệu lực 1 giờ):\n%s\n\nNếu bạn không yêu cầu, hãy bỏ qua email này.\n\nGoSporty",
+					user.Name, link),
+				HTML: fmt.Sprintf(`<p>Xin chào %s,</p><p><a href="%s">Đặt lại mật khẩu</a> (hiệu lực 1 giờ).</p><p>Nếu bạn không yêu cầu, hãy bỏ qua email này.</p><p>GoSporty</p>`,
+					htmlEscape(user.Name), link),
+			})
+			if err != nil {
+				log.Println("❌ Failed to send reset email:", err)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Nếu email tồn tại, hướng dẫn đặt lại mật khẩu đã được gửi",
+	})
+}
+
+// ResetPasswordHandler - Đặt mật khẩu mới bằng token trong email
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	if len(req.Password) < minPasswordLen {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Password must be at least %d characters", minPasswordLen)})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to hash password"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := consumeUserToken(ctx, req.Token, tokenPurposePasswordReset)
+	if err == errInvalidAccountToken {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Link đặt lại mật khẩu không hợp lệ hoặc đã hết hạn"})
+		return
+	}
+	if err != nil {
+		log.Println("❌ Reset password error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to reset password"})
+		return
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"password": string(hashedPassword)}},
+	)
+	if err != nil {
+		log.Println("❌ Reset password update error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to reset password"})
+		return
+	}
+
+	// Đổi mật khẩu -> đăng xuất mọi phiên cũ
+	if err := revokeAllSessions(ctx, userID.Hex()); err != nil {
+		log.Println("⚠️ Failed to revoke sessions after reset:", err)
+	}
+
+	log.Println("✅ Password reset for user:", userID.Hex())
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Đặt lại mật khẩu thành công"})
+}
+
+// VerifyEmailHandler - Xác nhận email và kích hoạt tài khoản
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	userID, err := consumeUserToken(ctx, req.Token, tokenPurposeEmailVerify)
+	if err == errInvalidAccountToken {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Link xác nhận không hợp lệ hoặc đã hết hạn"})
+		return
+	}
+	if err != nil {
+		log.Println("❌ Verify email error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to verify email"})
+		return
+	}
+
+	// Chỉ kích hoạt tài khoản đang chờ xác nhận - không ghi đè trạng thái khác (vd: bị khóa)
+	now := time.Now()
+	_, err = database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"emailVerified": true, "emailVerifiedAt": now}},
+	)
+	if err == nil {
+		_, err = database.DB.Collection("users").UpdateOne(ctx,
+			bson.M{"_id": userID, "status": UserStatusUnverified},
+			bson.M{"$set": bson.M{"status": UserStatusActive}},
+		)
+	}
+	if err != nil {
+		log.Println("❌ Verify email update error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to verify email"})
+		return
+	}
+
+	log.Println("✅ Email verified for user:", userID.Hex())
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Xác nhận email thành công"})
+}
+
+// ResendVerificationHandler - Gửi lại email xác nhận (luôn trả về 200)
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Email required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user User
+	err := database.DB.Collection("users").FindOne(ctx, bson.M{"email": req.Email, "status": UserStatusUnverified}).Decode(&user)
+	if err == nil {
+		if err := sendVerificationEmail(ctx, user); err != nil {
+			log.Println("❌ Failed to resend verification email:", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Nếu tài khoản chưa xác nhận, email xác nhận đã được gửi lại",
+	})
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+)
+
+// maxAddressesPerUser - Giới hạn số địa chỉ trong sổ địa chỉ
+const maxAddressesPerUser = 20
+
+var errAddressNotFound = errors.New("address not found")
+
+// Address - Địa chỉ giao hàng đã lưu của user
+type Address struct {
+	ID            primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID        string             `json:"userId" bson:"userId"`
+	Label         string             `json:"label,omitempty" bson:"label,omitempty"` // vd: "Nhà riêng", "Công ty"
+	RecipientName string             `json:"recipientName" bson:"recipientName"`
+	Phone         string             `json:"phone" bson:"phone"`
+	Address       string             `json:"address" bson:"address"` // Địa chỉ 1 dòng (tự ghép nếu có mã hành chính)
+	Street        string             `json:"street,omitempty" bson:"street,omitempty"`
+	WardCode      string             `json:"wardCode,omitempty" bson:"wardCode,omitempty"`
+	WardName      string             `json:"wardName,omitempty" bson:"wardName,omitempty"`
+	DistrictCode  string             `json:"districtCode,omitempty" bson:"districtCode,omitempty"`
+	DistrictName  string             `json:"districtName,omitempty" bson:"districtName,omitempty"`
+	ProvinceCode  string             `json:"provinceCode,omitempty" bson:"provinceCode,omitempty"`
+	ProvinceName  string             `json:"provinceName,omitempty" bson:"provinceName,omitempty"`
+	IsDefault     bool               `json:"isDefault" bson:"isDefault"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// InitAddressCollection - Tạo index cho addresses
+func InitAddressCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("addresses").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "isDefault", Value: -1}},
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create address index:", err)
+	} else {
+		log.Println("✅ Address collection initialized with index")
+	}
+}
+
+// shippingAddress - Địa chỉ có cấu trúc, nil nếu là địa chỉ cũ chỉ có 1 dòng text
+func (a Address) shippingAddress() *ShippingAddress {
+	if a.ProvinceCode == "" {
+		return nil
+	}
+	return &ShippingAddress{
+		RecipientName: a.RecipientName,
+		Phone:         a.Phone,
+		Street:        a.Street,
+		WardCode:      a.WardCode,
+		WardName:      a.WardName,
+		DistrictCode:  a.DistrictCode,
+		DistrictName:  a.DistrictName,
+		ProvinceCode:  a.ProvinceCode,
+		ProvinceName:  a.ProvinceName,
+	}
+}
+
+// validateAddress - Chuẩn hóa và kiểm tra dữ liệu địa chỉ
+// Có mã tỉnh thì kiểm tra theo danh mục hành chính, không thì chấp nhận địa chỉ text (dữ liệu cũ)
+func validateAddress(a *Address) error {
+	a.Label = strings.TrimSpace(a.Label)
+	a.RecipientName = strings.TrimSpace(a.RecipientName)
+	a.Phone = strings.TrimSpace(a.Phone)
+	a.Address = strings.TrimSpace(a.Address)
+
+	if a.ProvinceCode != "" {
+		shipping := a.shippingAddress()
+		if err := normalizeShippingAddress(shipping); err != nil {
+			return err
+		}
+		a.RecipientName = shipping.RecipientName
+		a.Phone = shipping.Phone
+		a.Street = shipping.Street
+		a.WardCode, a.WardName = shipping.WardCode, shipping.WardName
+		a.DistrictCode, a.DistrictName = shipping.DistrictCode, shipping.DistrictName
+		a.ProvinceCode, a.ProvinceName = shipping.ProvinceCode, shipping.ProvinceName
+		a.Address = shipping.FullAddress()
+		return nil
+	}
+
+	a.Street, a.WardCode, a.WardName, a.DistrictCode, a.DistrictName, a.ProvinceName = "", "", "", "", "", ""
+	if a.RecipientName == "" || a.Phone == "" || a.Address == "" {
+		return errors.New("Vui lòng nhập đầy đủ tên người nhận, số điện thoại và địa chỉ")
+	}
+	if !phonePattern.MatchString(a.Phone) {
+		return errors.New("Số điện thoại không hợp lệ")
+	}
+	return nil
+}
+
+// findUserAddress - Load địa chỉ và kiểm tra thuộc về user
+func findUserAddress(ctx context.Context, userID, addressID string) (Address, error) {
+	var address Address
+
+	objectID, err := primitive.ObjectIDFromHex(addressID)
+	if err != nil {
+		return address, errAddressNotFound
+	}
+
+	err = database.DB.Collection("addresses").FindOne(ctx, bson.M{"_id": objectID, "userId": userID}).Decode(&address)
+	if err == mongo.ErrNoDocuments {
+		return address, errAddressNotFound
+	}
+	return address, err
+}
+
+// setDefaultAddress - Bỏ cờ mặc định của các địa chỉ khác rồi đặt cho addressID
+func setDefaultAddress(ctx context.Context, userID string, addressID primitive.ObjectID) error {
+	coll := database.DB.Collection("addresses")
+
+	_, err := coll.UpdateMany(ctx,
+		bson.M{"userId": userID, "_id": bson.M{"$ne": addressID}},
+		bson.M{"$set": bson.M{"isDefault": false}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = coll.UpdateOne(ctx,
+		bson.M{"_id": addressID, "userId": userID},
+		bson.M{"$set": bson.M{"isDefault": true, "updatedAt": time.Now()}},
+	)
+	return err
+}
+
+// GetAddresses - Danh sách địa chỉ của user (mặc định lên đầu)
+func GetAddresses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, _ := GetUserIDFromContext(r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "isDefault", Value: -1}, {Key: "createdAt", Value: -1}})
+	cursor, err := database.DB.Collection("addresses").Find(ctx, bson.M{"userId": userID}, opts)
+	if err != nil {
+		log.Println("❌ GetAddresses error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch addresses"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var addresses []Address
+	if err := cursor.All(ctx, &addresses); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch addresses"})
+		return
+	}
+	if addresses == nil {
+		addresses = []Address{}
+	}
+
+	json.NewEncoder(w).Encode(addresses)
+}
+
+// CreateAddress - Thêm địa chỉ mới (địa chỉ đầu tiên tự động là mặc định)
+func CreateAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, _ := GetUserIDFromContext(r)
+
+	var address Address
+	if err := json.NewDecoder(r.Body).Decode(&address); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+	if err := validateAddress(&address); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	coll := database.DB.Collection("addresses")
+	count, err := coll.CountDocuments(ctx, bson.M{"userId": userID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create address"})
+		return
+	}
+	if count >= maxAddressesPerUser {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Sổ địa chỉ đã đầy"})
+		return
+	}
+
+	now := time.Now()
+	address.ID = primitive.NewObjectID()
+	address.UserID = userID
+	address.CreatedAt = now
+	address.UpdatedAt = now
+	makeDefault := address.IsDefault || count == 0
+	address.IsDefault = false
+
+	if _, err := coll.InsertOne(ctx, address); err != nil {
+		log.Println("❌ CreateAddress error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create address"})
+		return
+	}
+
+	if makeDefault {
+		if err := setDefaultAddress(ctx, userID, address.ID); err != nil {
+			log.Println("⚠️ Failed to set default address:", err)
+		} else {
+			address.IsDefault = true
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(address)
+}
+
+// UpdateAddress - Sửa địa chỉ đã lưu
+func UpdateAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, _ := GetUserIDFromContext(r)
+
+	var req Address
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+	if err := validateAddress(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	address, err := findUserAddress(ctx, userID, mux.Vars(r)["addressId"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy địa chỉ"})
+		return
+	}
+
+	address.Label = req.Label
+	address.RecipientName = req.RecipientName
+	address.Phone = req.Phone
+	address.Address = req.Address
+	address.Street = req.Street
+	address.WardCode, address.WardName = req.WardCode, req.WardName
+	address.DistrictCode, address.DistrictName = req.DistrictCode, req.DistrictName
+	address.ProvinceCode, address.ProvinceName = req.ProvinceCode, req.ProvinceName
+	address.UpdatedAt = time.Now()
+
+	_, err = database.DB.Collection("addresses").UpdateOne(ctx,
+		bson.M{"_id": address.ID, "userId": userID},
+		bson.M{"$set": bson.M{
+			"label":         address.Label,
+			"recipientName": address.RecipientName,
+			"phone":         address.Phone,
+			"address":       address.Address,
+			"street":        address.Street,
+			"wardCode":      address.WardCode,
+			"wardName":      address.WardName,
+			"districtCode":  address.DistrictCode,
+			"districtName":  address.DistrictName,
+			"provinceCode":  address.ProvinceCode,
+			"provinceName":  address.ProvinceName,
+			"updatedAt":     address.UpdatedAt,
+		}},
+	)
+	if err != nil {
+		log.Println("❌ UpdateAddress error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update address"})
+		return
+	}
+
+	if req.IsDefault && !address.IsDefault {
+		if err := setDefaultAddress(ctx, userID, address.ID); err == nil {
+			address.IsDefault = true
+		}
+	}
+
+	json.NewEncoder(w).Encode(address)
+}
+
+// DeleteAddress - Xóa địa chỉ, nếu là mặc định thì chọn địa chỉ mới nhất làm mặc định
+func DeleteAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, _ := GetUserIDFromContext(r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	address, err := findUserAddress(ctx, userID, mux.Vars(r)["addressId"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy địa chỉ"})
+		return
+	}
+
+	coll := database.DB.Collection("addresses")
+	if _, err := coll.DeleteOne(ctx, bson.M{"_id": address.ID, "userId": userID}); err != nil {
+		log.Println("❌ DeleteAddress error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete address"})
+		return
+	}
+
+	if address.IsDefault {
+		var next Address
+		opts := options.FindOne().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+		if err := coll.FindOne(ctx, bson.M{"userId": userID}, opts).Decode(&next); err == nil {
+			setDefaultAddress(ctx, userID, next.ID)
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Đã xóa địa chỉ"})
+}
+
+// SetDefaultAddress - Đặt địa chỉ mặc định
+func SetDefaultAddress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, _ := GetUserIDFromContext(r)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	address, err := findUserAddress(ctx, userID, mux.Vars(r)["addressId"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy địa chỉ"})
+		return
+	}
+
+	if err := setDefaultAddress(ctx, userID, address.ID); err != nil {
+		log.Println("❌ SetDefaultAddress error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update address"})
+		return
+	}
+
+	address.IsDefault = true
+	json.NewEncoder(w).Encode(address)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -15,7 +16,8 @@ import (
 )
 
 type DashboardStats struct {
-	TotalRevenue    float64 `json:"totalRevenue"`
+	TotalRevenue    float64 `json:"totalRevenue"` // Doanh thu thuần (đã trừ tiền hoàn)
+	TotalRefunded   float64 `json:"totalRefunded"`
 	TotalOrders     int64   `json:"totalOrders"`
 	TotalUsers      int64   `json:"totalUsers"`
 	TotalProducts   int64   `json:"totalProducts"`
@@ -23,6 +25,8 @@ type DashboardStats struct {
 	TodayOrders     int64   `json:"todayOrders"`
 	PendingOrders   int64   `json:"pendingOrders"`
 	CompletedOrders int64   `json:"completedOrders"`
+
+	OrdersByStatus map[string]int64 `json:"ordersByStatus"`
 }
 
 type UserStats struct {
@@ -39,6 +43,25 @@ type UserStats struct {
 	LastLogin   string  `json:"lastLogin" bson:"lastLogin"`
 }
 
+// netRevenueExpr - Tiền đơn hàng trừ phần đã hoàn qua đổi trả
+var netRevenueExpr = bson.D{{Key: "$subtract", Value: bson.A{
+	"$total",
+	bson.D{{Key: "$ifNull", Value: bson.A{"$refundedAmount", 0}}},
+}}}
+
+// aggFloat - Số từ kết quả aggregate (có thể là double, int32 hoặc int64)
+func aggFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
+
 // GetDashboardStats - Lấy thống kê tổng quan
 func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -78,7 +101,8 @@ func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 	pipeline := mongo.Pipeline{
 		{{Key: "$group", Value: bson.D{
 			{Key: "_id", Value: nil},
-			{Key: "totalRevenue", Value: bson.D{{Key: "$sum", Value: "$total"}}},
+			{Key: "totalRevenue", Value: bson.D{{Key: "$sum", Value: netRevenueExpr}}},
+			{Key: "totalRefunded", Value: bson.D{{Key: "$sum", Value: "$refundedAmount"}}},
 		}}},
 	}
 
@@ -93,6 +117,7 @@ func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 			} else if total, ok := results[0]["totalRevenue"].(int64); ok {
 				stats.TotalRevenue = float64(total)
 			}
+			stats.TotalRefunded = aggFloat(results[0]["totalRefunded"])
 		}
 	}
 
@@ -111,7 +136,7 @@ func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 		{{Key: "$match", Value: todayFilter}},
 		{{Key: "$group", Value: bson.D{
 			{Key: "_id", Value: nil},
-			{Key: "todayRevenue", Value: bson.D{{Key: "$sum", Value: "$total"}}},
+			{Key: "todayRevenue", Value: bson.D{{Key: "$sum", Value: netRevenueExpr}}},
 		}}},
 	}
 
@@ -126,8 +151,19 @@ func GetDashboardStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Pending and completed orders
-	stats.PendingOrders, _ = database.DB.Collection("orders").CountDocuments(ctx, bson.M{"status": "Đang xử lý"})
-	stats.CompletedOrders, _ = database.DB.Collection("orders").CountDocuments(ctx, bson.M{"status": "Hoàn thành"})
+	stats.PendingOrders, _ = database.DB.Collection("orders").CountDocuments(ctx, bson.M{"status": bson.M{"$in": statusValues(StatusPending)}})
+	stats.CompletedOrders, _ = database.DB.Collection("orders").CountDocuments(ctx, bson.M{"status": bson.M{"$in": statusValues(StatusCompleted)}})
+
+	// Số đơn theo từng trạng thái (dùng chung enum với order handlers)
+	stats.OrdersByStatus = map[string]int64{}
+	for _, status := range orderStatuses {
+		count, err := database.DB.Collection("orders").CountDocuments(ctx, bson.M{"status": bson.M{"$in": statusValues(status)}})
+		if err != nil {
+			log.Println("❌ Error counting orders by status:", err)
+			continue
+		}
+		stats.OrdersByStatus[status.Key()] = count
+	}
 
 	log.Printf("📊 Dashboard stats: Users=%d, Products=%d, Orders=%d, Revenue=%.0f\n",
 		stats.TotalUsers, stats.TotalProducts, stats.TotalOrders, stats.TotalRevenue)
@@ -148,8 +184,8 @@ func GetUsersWithStats(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Get all users
-	cursor, err := database.DB.Collection("users").Find(ctx, bson.M{})
+	// Get all users (bỏ qua tài khoản đã xóa, trừ khi ?includeDeleted=true)
+	cursor, err := database.DB.Collection("users").Find(ctx, customerListFilter(r.URL.Query()))
 	if err != nil {
 		log.Println("❌ Error fetching users:", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -178,6 +214,7 @@ func GetUsersWithStats(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Count orders for this user
+		// (user.ID là string nên driver giải mã _id thành chuỗi hex - cùng dạng với userId lưu trong đơn)
 		orderCount, _ := database.DB.Collection("orders").CountDocuments(ctx, bson.M{"userId": user.ID})
 		user.TotalOrders = int(orderCount)
 
@@ -186,7 +223,7 @@ func GetUsersWithStats(w http.ResponseWriter, r *http.Request) {
 			{{Key: "$match", Value: bson.M{"userId": user.ID}}},
 			{{Key: "$group", Value: bson.D{
 				{Key: "_id", Value: nil},
-				{Key: "totalSpent", Value: bson.D{{Key: "$sum", Value: "$total"}}},
+				{Key: "totalSpent", Value: bson.D{{Key: "$sum", Value: netRevenueExpr}}},
 			}}},
 		}
 
@@ -227,30 +264,34 @@ func GetRecentOrders(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	opts := options.Find().SetLimit(10).SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	// ?limit= (mặc định 10, tối đa maxRecentOrders)
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > maxRecentOrders {
+		limit = maxRecentOrders
+	}
+
+	opts := options.Find().SetLimit(int64(limit)).SetSort(orderListSort(nil))
 
 	cursor, err := database.DB.Collection("orders").Find(ctx, bson.M{}, opts)
 	if err != nil {
 		log.Println("❌ Error fetching orders:", err)
-		w.WriteHeader(http.StatusOK)          // ✅ Trả về 200 với array rỗng
-		json.NewEncoder(w).Encode([]bson.M{}) // ✅ Empty array thay vì error
+		w.WriteHeader(http.StatusOK)         // ✅ Trả về 200 với array rỗng
+		json.NewEncoder(w).Encode([]Order{}) // ✅ Empty array thay vì error
 		return
 	}
 	defer cursor.Close(ctx)
 
-	var orders []bson.M
+	orders := []Order{}
 	if err = cursor.All(ctx, &orders); err != nil {
 		log.Println("❌ Error decoding orders:", err)
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode([]bson.M{})
+		json.NewEncoder(w).Encode([]Order{})
 		return
 	}
 
-	// ✅ Nếu không có orders, trả về empty array
-	if orders == nil {
-		orders = []bson.M{}
-	}
-
 	log.Printf("✅ Fetched %d recent orders\n", len(orders))
 
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gosporty-backend/database"
+)
+
+// loadTargetUser - Đọc user cần thao tác từ {id} trên URL, tự trả lỗi nếu không hợp lệ
+func loadTargetUser(ctx context.Context, w http.ResponseWriter, r *http.Request) (User, bool) {
+	var user User
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+		return user, false
+	}
+
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	if err != nil || user.Status == UserStatusDeleted {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return user, false
+	}
+
+	return user, true
+}
+
+// rejectSelf - Admin không được tự hạ quyền/khóa/xóa chính mình
+func rejectSelf(w http.ResponseWriter, r *http.Request, target User) bool {
+	if actorID, _ := GetUserIDFromContext(r); actorID == target.ID.Hex() {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể thực hiện thao tác này trên chính tài khoản của bạn"})
+		return true
+	}
+	return false
+}
+
+// SetUserRole - Cấp hoặc thu hồi quyền admin
+func SetUserRole(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		IsAdmin *bool `json:"isAdmin"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IsAdmin == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "isAdmin required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := loadTargetUser(ctx, w, r)
+	if !ok || rejectSelf(w, r, user) {
+		return
+	}
+
+	_, err := database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"isAdmin": *req.IsAdmin, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		log.Println("❌ SetUserRole error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update user"})
+		return
+	}
+
+	action := "user.revoke_admin"
+	if *req.IsAdmin {
+		action = "user.grant_admin"
+	}
+	writeAudit(ctx, r, action, "user", user.ID.Hex(), map[string]interface{}{
+		"from": user.IsAdmin,
+		"to":   *req.IsAdmin,
+	})
+
+	user.IsAdmin = *req.IsAdmin
+	log.Printf("✅ %s for user %s\n", action, user.ID.Hex())
+	json.NewEncoder(w).Encode(profileResponse(user))
+}
+
+// SetUserStatus - Khóa (suspended) hoặc mở lại (active) tài khoản
+func SetUserStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+		return
+	}
+	if req.Status != UserStatusActive && req.Status != UserStatusSuspended {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Status must be \"active\" or \"suspended\""})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := loadTargetUser(ctx, w, r)
+	if !ok || rejectSelf(w, r, user) {
+		return
+	}
+
+	set := bson.M{"status": req.Status, "updatedAt": time.Now()}
+	unset := bson.M{}
+	if req.Status == UserStatusSuspended {
+		set["suspendedAt"] = time.Now()
+		set["suspendReason"] = strings.TrimSpace(req.Reason)
+	} else {
+		unset["suspendedAt"] = ""
+		unset["suspendReason"] = ""
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	if _, err := database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, update); err != nil {
+		log.Println("❌ SetUserStatus error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update user"})
+		return
+	}
+
+	// Khóa tài khoản -> thu hồi mọi phiên đăng nhập ngay lập tức
+	if req.Status == UserStatusSuspended {
+		if err := revokeAllSessions(ctx, user.ID.Hex()); err != nil {
+			log.Println("⚠️ Failed to revoke sessions of suspended user:", err)
+		}
+	}
+
+	action := "user.reactivate"
+	if req.Status == UserStatusSuspended {
+		action = "user.suspend"
+	}
+	writeAudit(ctx, r, action, "user", user.ID.Hex(), map[string]interface{}{
+		"from":   user.Status,
+		"to":     req.Status,
+		"reason": req.Reason,
+	})
+
+	log.Printf("✅ %s for user %s\n", action, user.ID.Hex())
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"_id":    user.ID,
+		"status": req.Status,
+	})
+}
+
+// DeleteUser - Xóa mềm tài khoản: đánh dấu "deleted", thu hồi phiên, giữ lại đơn hàng
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := loadTargetUser(ctx, w, r)
+	if !ok || rejectSelf(w, r, user) {
+		return
+	}
+
+	now := time.Now()
+	_, err := database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{
+			"status":    UserStatusDeleted,
+			"isAdmin":   false,
+			"deletedAt": now,
+			"updatedAt": now,
+		}},
+	)
+	if err != nil {
+		log.Println("❌ DeleteUser error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete user"})
+		return
+	}
+
+	if err := revokeAllSessions(ctx, user.ID.Hex()); err != nil {
+		log.Println("⚠️ Failed to revoke sessions of deleted user:", err)
+	}
+
+	writeAudit(ctx, r, "user.delete", "user", user.ID.Hex(), map[string]interface{}{
+		"email": user.Email,
+	})
+
+	log.Println("✅ Soft-deleted user:", user.ID.Hex())
+	json.NewEncoder(w).Encode(map[string]string{"message": "User deleted"})
+}
+
+// isBlockedStatus - Tài khoản bị khóa hoặc đã xóa thì không được đăng nhập
+func isBlockedStatus(status string) bool {
+	return status == UserStatusSuspended || status == UserStatusDeleted
+}
+
+// activeUsersFilter - Bỏ qua tài khoản đã xóa mềm
+func activeUsersFilter() bson.M {
+	return bson.M{"status": bson.M{"$ne": UserStatusDeleted}}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+)
+
+// AuditLog - Nhật ký thao tác quản trị
+type AuditLog struct {
+	ID         primitive.ObjectID     `json:"_id,omitempty" bson:"_id,omitempty"`
+	ActorID    string                 `json:"actorId" bson:"actorId"`
+	ActorEmail string                 `json:"actorEmail,omitempty" bson:"actorEmail,omitempty"`
+	Action     string                 `json:"action" bson:"action"` // vd: "user.suspend"
+	TargetType string                 `json:"targetType" bson:"targetType"`
+	TargetID   string                 `json:"targetId" bson:"targetId"`
+	Details    map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	IP         string                 `json:"ip,omitempty" bson:"ip,omitempty"`
+	CreatedAt  time.Time              `json:"createdAt" bson:"createdAt"`
+}
+
+// InitAuditCollection - Tạo index cho audit_logs
+func InitAuditCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("audit_logs").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create audit log index:", err)
+	} else {
+		log.Println("✅ Audit log collection initialized with index")
+	}
+}
+
+// writeAudit - Ghi 1 dòng audit, lỗi chỉ được log lại (không chặn thao tác chính)
+func writeAudit(ctx context.Context, r *http.Request, action, targetType, targetID string, details map[string]interface{}) {
+	actorID, _ := GetUserIDFromContext(r)
+	actorEmail, _ := r.Context().Value("email").(string)
+
+	entry := AuditLog{
+		ActorID:    actorID,
+		ActorEmail: actorEmail,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Details:    details,
+		IP:         clientIP(r),
+		CreatedAt:  time.Now(),
+	}
+
+	if _, err := database.DB.Collection("audit_logs").InsertOne(ctx, entry); err != nil {
+		log.Printf("❌ Failed to write audit log %s %s/%s: %v\n", action, targetType, targetID, err)
+	}
+}
+
+// GetAuditLogs - Admin xem nhật ký thao tác (?targetType=&targetId=&limit=)
+func GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	filter := bson.M{}
+	if targetType := q.Get("targetType"); targetType != "" {
+		filter["targetType"] = targetType
+	}
+	if targetID := q.Get("targetId"); targetID != "" {
+		filter["targetId"] = targetID
+	}
+
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := database.DB.Collection("audit_logs").Find(ctx, filter, opts)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch audit logs"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var logs []AuditLog
+	if err := cursor.All(ctx, &logs); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch audit logs"})
+		return
+	}
+	if logs == nil {
+		logs = []AuditLog{}
+	}
+
+	json.NewEncoder(w).Encode(logs)
+}
@@ -14,6 +14,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"gosporty-backend/database"
+	"gosporty-backend/middlewares"
 )
 
 type User struct {
@@ -22,12 +23,22 @@ type User struct {
 	Password string             `json:"password,omitempty" bson:"password"`
 	Name     string             `json:"name" bson:"name"`
 	IsAdmin  bool               `json:"isAdmin" bson:"isAdmin"` // ✅ Add this
+	Phone    string             `json:"phone,omitempty" bson:"phone,omitempty"`
+	Avatar   string             `json:"avatar,omitempty" bson:"avatar,omitempty"`
+
+	TokenVersion int `json:"-" bson:"tokenVersion"` // Tăng lên khi "đăng xuất mọi thiết bị"
+
+	Status        string `json:"status,omitempty" bson:"status,omitempty"` // "" = active (tài khoản cũ)
+	EmailVerified bool   `json:"emailVerified" bson:"emailVerified"`
 }
 
 // JWT Claims - PHẢI KHỚP VỚI MIDDLEWARE
 type Claims struct {
-	UserID string `json:"userId"`
-	Email  string `json:"email"`
+	UserID string   `json:"userId"`
+	Email  string   `json:"email"`
+	Roles  []string `json:"roles,omitempty"`
+
+	TokenVersion int `json:"tv"`
 	jwt.RegisteredClaims
 }
 
@@ -71,13 +82,21 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	// Chống brute-force: kiểm tra khóa theo tài khoản và theo IP
+	ip := clientIP(r)
+	if checkLoginLocked(ctx, w, loginData.Email, ip) {
+		return
+	}
+
 	// Find user by email
 	coll := database.DB.Collection("users")
 	var user User
 	err := coll.FindOne(ctx, bson.M{"email": loginData.Email}).Decode(&user)
 
 	if err != nil {
-		log.Println("❌ User not found:", loginData.Email)
+		log.Println("❌ Login failed (unknown account):", maskEmail(loginData.Email))
+		recordLoginFailure(ctx, accountAttemptKey(loginData.Email), accountLoginLimit)
+		recordLoginFailure(ctx, ipAttemptKey(ip), ipLoginLimit)
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid email or password"})
 		return
@@ -86,28 +105,49 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	// Verify password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginData.Password))
 	if err != nil {
-		log.Println("❌ Invalid password for:", loginData.Email)
+		log.Println("❌ Login failed (invalid password):", maskEmail(loginData.Email))
+		recordLoginFailure(ctx, accountAttemptKey(loginData.Email), accountLoginLimit)
+		recordLoginFailure(ctx, ipAttemptKey(ip), ipLoginLimit)
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid email or password"})
 		return
 	}
 
-	// ✅ CRITICAL: Tạo JWT token với userId là STRING
-	userIDStr := user.ID.Hex() // Chuyển ObjectID sang string
+	// Đăng nhập đúng -> reset bộ đếm của tài khoản (bộ đếm IP tự hết hạn)
+	if err := clearLoginFailures(ctx, accountAttemptKey(loginData.Email)); err != nil {
+		log.Println("⚠️ Failed to reset login failures:", err)
+	}
 
-	claims := &Claims{
-		UserID: userIDStr, // ✅ Phải là string
-		Email:  user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)), // 7 days
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   userIDStr, // ✅ Subject cũng là userId
-		},
+	// Tài khoản đã xóa coi như không tồn tại, tài khoản bị khóa thì báo rõ
+	if user.Status == UserStatusDeleted {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid email or password"})
+		return
+	}
+	if user.Status == UserStatusSuspended {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Tài khoản đã bị khóa, vui lòng liên hệ hỗ trợ",
+			"code":  "ACCOUNT_SUSPENDED",
+		})
+		return
+	}
+
+	// Chưa xác nhận email thì chưa được đăng nhập
+	if user.Status == UserStatusUnverified {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Vui lòng xác nhận email trước khi đăng nhập",
+			"code":  "EMAIL_NOT_VERIFIED",
+		})
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
+	// ✅ CRITICAL: userId trong token là STRING
+	userIDStr := user.ID.Hex() // Chuyển ObjectID sang string
 
+	// Access token ngắn hạn + refresh token xoay vòng
+	session, err := issueSession(ctx, r, user)
 	if err != nil {
 		log.Println("❌ Failed to generate token:", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -115,20 +155,30 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Gộp giỏ hàng khách (nếu có) vào giỏ hàng của user
+	if guestToken := r.Header.Get(CartTokenHeader); guestToken != "" {
+		if err := MergeGuestCart(ctx, guestToken, userIDStr); err != nil {
+			log.Println("⚠️ Failed to merge guest cart:", err)
+		}
+	}
+
 	// Don't send password back
 	user.Password = ""
 
-	log.Printf("✅ User logged in: %s (ID: %s)\n", user.Email, userIDStr)
+	log.Printf("✅ User logged in: %s (ID: %s)\n", maskEmail(user.Email), userIDStr)
 
 	// Return token and user info
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"token": tokenString,
+		"token":        session.Token,
+		"refreshToken": session.RefreshToken,
+		"expiresIn":    session.ExpiresIn,
 		"user": map[string]interface{}{
 			"_id":     user.ID,
 			"email":   user.Email,
 			"name":    user.Name,
 			"isAdmin": user.IsAdmin, // ✅ Add this
+			"roles":   middlewares.UserRoles(user.IsAdmin),
 		},
 	})
 }
@@ -179,6 +229,10 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 
 	newUser.Password = string(hashedPassword)
 	newUser.ID = primitive.NewObjectID()
+	newUser.IsAdmin = false // Không cho phép tự đăng ký làm admin
+	newUser.Status = UserStatusUnverified
+	newUser.EmailVerified = false
+	newUser.TokenVersion = 0
 
 	// Insert user
 	_, err = coll.InsertOne(ctx, newUser)
@@ -189,37 +243,19 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate token for new user
-	userIDStr := newUser.ID.Hex()
-
-	claims := &Claims{
-		UserID: userIDStr,
-		Email:  newUser.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 7 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   userIDStr,
-		},
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtSecret)
-
-	if err != nil {
-		log.Println("❌ Failed to generate token:", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate token"})
-		return
+	// Tài khoản chỉ được kích hoạt sau khi xác nhận email
+	if err := sendVerificationEmail(ctx, newUser); err != nil {
+		log.Println("⚠️ Failed to send verification email:", err)
 	}
 
 	// Don't send password back
 	newUser.Password = ""
 
-	log.Printf("✅ New user registered: %s (ID: %s)\n", newUser.Email, userIDStr)
+	log.Printf("✅ New user registered: %s (ID: %s)\n", newUser.Email, newUser.ID.Hex())
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"token": tokenString,
+		"message": "Đăng ký thành công, vui lòng kiểm tra email để xác nhận tài khoản",
 		"user": map[string]interface{}{
 			"_id":   newUser.ID,
 			"email": newUser.Email,
@@ -24,10 +24,12 @@ type CartItem struct {
 }
 
 type Cart struct {
-	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	UserID    string             `json:"userId" bson:"userId"`
-	Items     []CartItem         `json:"items" bson:"items"`
-	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID     string             `json:"userId,omitempty" bson:"userId,omitempty"`
+	GuestID    string             `json:"guestId,omitempty" bson:"guestId,omitempty"`
+	Items      []CartItem         `json:"items" bson:"items"`
+	CouponCode string             `json:"couponCode,omitempty" bson:"couponCode,omitempty"` // Mã giảm giá đã áp dụng
+	UpdatedAt  time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
 
 var cartCollection *mongo.Collection
@@ -36,16 +38,35 @@ var cartCollection *mongo.Collection
 func InitCartCollection(db *mongo.Database) {
 	cartCollection = db.Collection("carts")
 
-	// Tạo index cho userId
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "userId", Value: 1}},
-		Options: options.Index().SetUnique(true),
+	// Index cũ unique trên userId không cho phép nhiều giỏ hàng khách (userId rỗng)
+	if _, err := cartCollection.Indexes().DropOne(ctx, "userId_1"); err == nil {
+		log.Println("✅ Dropped legacy cart index userId_1")
 	}
 
-	_, err := cartCollection.Indexes().CreateOne(ctx, indexModel)
+	indexModels := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "userId", Value: 1}},
+			Options: options.Index().SetName("userId_unique").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"userId": bson.M{"$type": "string"}}),
+		},
+		{
+			Keys: bson.D{{Key: "guestId", Value: 1}},
+			Options: options.Index().SetName("guestId_unique").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"guestId": bson.M{"$type": "string"}}),
+		},
+		{
+			// Giỏ hàng khách tự hết hạn
+			Keys: bson.D{{Key: "updatedAt", Value: 1}},
+			Options: options.Index().SetName("guest_cart_ttl").
+				SetExpireAfterSeconds(int32(guestCartTTL.Seconds())).
+				SetPartialFilterExpression(bson.M{"guestId": bson.M{"$type": "string"}}),
+		},
+	}
+
+	_, err := cartCollection.Indexes().CreateMany(ctx, indexModels)
 	if err != nil {
 		log.Println("⚠️ Warning: Could not create cart index:", err)
 	} else {
@@ -71,7 +92,7 @@ func GetCart(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
-	userID, ok := GetUserIDFromContext(r)
+	owner, ok := getCartOwner(r)
 	if !ok {
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(Cart{Items: []CartItem{}})
@@ -80,7 +101,7 @@ func GetCart(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	var cart Cart
-	err := cartCollection.FindOne(ctx, bson.M{"userId": userID}).Decode(&cart)
+	err := cartCollection.FindOne(ctx, owner.filter()).Decode(&cart)
 	if err == mongo.ErrNoDocuments {
 		// Chưa có cart -> trả về cart rỗng
 		w.WriteHeader(http.StatusOK)
@@ -108,17 +129,23 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// ✅ FIX: Cho phép add cart cả khi chưa login (optional auth)
-	userID, ok := GetUserIDFromContext(r)
+	// ✅ Cho phép add cart cả khi chưa login: khách được cấp guest cart token
+	owner, ok := getCartOwner(r)
 	if !ok {
-		// Nếu không có userID -> trả về thông báo cần login
-		// Frontend sẽ fallback về localStorage
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Please login to sync cart"})
-		return
+		token, err := newCartToken()
+		if err != nil {
+			log.Println("❌ AddToCart token error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create cart"})
+			return
+		}
+		owner = cartOwner{GuestID: token}
+	}
+	if owner.GuestID != "" {
+		w.Header().Set(CartTokenHeader, owner.GuestID)
 	}
 
-	log.Println("📝 AddToCart - UserID:", userID)
+	log.Println("📝 AddToCart - Owner:", owner)
 
 	var newItem CartItem
 	if err := json.NewDecoder(r.Body).Decode(&newItem); err != nil {
@@ -146,12 +173,34 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	var cart Cart
-	err := cartCollection.FindOne(ctx, bson.M{"userId": userID}).Decode(&cart)
+	err := cartCollection.FindOne(ctx, owner.filter()).Decode(&cart)
+
+	// Kiểm tra phân loại tồn tại và còn hàng (tính cả số lượng đã có trong giỏ)
+	wantQty := newItem.Qty
+	for _, item := range cart.Items {
+		if item.ProductID == newItem.ProductID &&
+			item.SelectedColor == newItem.SelectedColor &&
+			item.SelectedSize == newItem.SelectedSize {
+			wantQty += item.Qty
+		}
+	}
+	if availErr := checkVariantAvailable(ctx, newItem.ProductID, newItem.SelectedColor, newItem.SelectedSize, wantQty); availErr != nil {
+		if itemErr, ok := availErr.(*ItemError); ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": itemErr.Message})
+			return
+		}
+		log.Println("❌ AddToCart product lookup error:", availErr)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		return
+	}
 
 	if err == mongo.ErrNoDocuments {
 		// Tạo cart mới
 		cart = Cart{
-			UserID:    userID,
+			UserID:    owner.UserID,
+			GuestID:   owner.GuestID,
 			Items:     []CartItem{newItem},
 			UpdatedAt: time.Now(),
 		}
@@ -164,7 +213,7 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		log.Println("✅ Created new cart for user:", userID)
+		log.Println("✅ Created new cart for:", owner)
 	} else if err != nil {
 		log.Println("❌ AddToCart find error:", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -196,7 +245,7 @@ func AddToCart(w http.ResponseWriter, r *http.Request) {
 		// Update cart
 		_, err = cartCollection.UpdateOne(
 			ctx,
-			bson.M{"userId": userID},
+			owner.filter(),
 			bson.M{
 				"$set": bson.M{
 					"items":     cart.Items,
@@ -226,7 +275,7 @@ func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, ok := GetUserIDFromContext(r)
+	owner, ok := getCartOwner(r)
 	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
@@ -250,7 +299,7 @@ func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	var cart Cart
-	err := cartCollection.FindOne(ctx, bson.M{"userId": userID}).Decode(&cart)
+	err := cartCollection.FindOne(ctx, owner.filter()).Decode(&cart)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Cart not found"})
@@ -288,7 +337,7 @@ func UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 
 	_, err = cartCollection.UpdateOne(
 		ctx,
-		bson.M{"userId": userID},
+		owner.filter(),
 		bson.M{
 			"$set": bson.M{
 				"items":     cart.Items,
@@ -318,7 +367,7 @@ func RemoveItem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, ok := GetUserIDFromContext(r)
+	owner, ok := getCartOwner(r)
 	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
@@ -341,7 +390,7 @@ func RemoveItem(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	var cart Cart
-	err := cartCollection.FindOne(ctx, bson.M{"userId": userID}).Decode(&cart)
+	err := cartCollection.FindOne(ctx, owner.filter()).Decode(&cart)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Cart not found"})
@@ -363,7 +412,7 @@ func RemoveItem(w http.ResponseWriter, r *http.Request) {
 
 	_, err = cartCollection.UpdateOne(
 		ctx,
-		bson.M{"userId": userID},
+		owner.filter(),
 		bson.M{
 			"$set": bson.M{
 				"items":     cart.Items,
@@ -393,7 +442,7 @@ func ClearCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, ok := GetUserIDFromContext(r)
+	owner, ok := getCartOwner(r)
 	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
@@ -403,7 +452,7 @@ func ClearCart(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, err := cartCollection.DeleteOne(ctx, bson.M{"userId": userID})
+	_, err := cartCollection.DeleteOne(ctx, owner.filter())
 	if err != nil {
 		log.Println("❌ ClearCart error:", err)
 		w.WriteHeader(http.StatusInternalServerError)
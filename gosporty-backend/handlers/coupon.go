@@ -0,0 +1,694 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+	"gosporty-backend/models"
+)
+
+// Loại giảm giá
+const (
+	CouponPercent = "percent" // Giảm theo %
+	CouponFixed   = "fixed"   // Giảm số tiền cố định
+)
+
+// CouponError - Mã giảm giá không dùng được, Message hiển thị cho khách
+type CouponError struct {
+	Code    string
+	Message string
+}
+
+func (e *CouponError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+var (
+	errCouponNotFound    = &CouponError{Code: "COUPON_NOT_FOUND", Message: "Mã giảm giá không tồn tại"}
+	errCouponInactive    = &CouponError{Code: "COUPON_INACTIVE", Message: "Mã giảm giá đã ngừng áp dụng"}
+	errCouponNotStarted  = &CouponError{Code: "COUPON_NOT_STARTED", Message: "Mã giảm giá chưa đến thời gian áp dụng"}
+	errCouponExpired     = &CouponError{Code: "COUPON_EXPIRED", Message: "Mã giảm giá đã hết hạn"}
+	errCouponUsedUp      = &CouponError{Code: "COUPON_USAGE_LIMIT", Message: "Mã giảm giá đã hết lượt sử dụng"}
+	errCouponUserLimit   = &CouponError{Code: "COUPON_USER_LIMIT", Message: "Bạn đã dùng hết lượt cho mã giảm giá này"}
+	errCouponNotEligible = &CouponError{Code: "COUPON_NOT_ELIGIBLE", Message: "Giỏ hàng không có sản phẩm áp dụng mã giảm giá"}
+)
+
+// Coupon - Mã giảm giá
+type Coupon struct {
+	ID            primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Code          string             `json:"code" bson:"code"` // Luôn viết hoa
+	Description   string             `json:"description,omitempty" bson:"description,omitempty"`
+	Type          string             `json:"type" bson:"type"`                                   // percent | fixed
+	Value         float64            `json:"value" bson:"value"`                                 // % hoặc số tiền
+	MaxDiscount   float64            `json:"maxDiscount,omitempty" bson:"maxDiscount,omitempty"` // Giảm tối đa (cho loại %), 0 = không giới hạn
+	MinOrderValue float64            `json:"minOrderValue,omitempty" bson:"minOrderValue,omitempty"`
+
+	// Phạm vi áp dụng, để trống = toàn bộ sản phẩm
+	Categories []string `json:"categories,omitempty" bson:"categories,omitempty"`
+	Brands     []string `json:"brands,omitempty" bson:"brands,omitempty"`
+	ProductIDs []string `json:"productIds,omitempty" bson:"productIds,omitempty"`
+
+	UsageLimit   int `json:"usageLimit" bson:"usageLimit"`     // Tổng số lượt, 0 = không giới hạn
+	UsedCount    int `json:"usedCount" bson:"usedCount"`       // Số lượt đã dùng
+	PerUserLimit int `json:"perUserLimit" bson:"perUserLimit"` // Số lượt mỗi khách, 0 = không giới hạn
+
+	StartsAt  *time.Time `json:"startsAt,omitempty" bson:"startsAt,omitempty"`
+	EndsAt    *time.Time `json:"endsAt,omitempty" bson:"endsAt,omitempty"`
+	Active    bool       `json:"active" bson:"active"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt" bson:"updatedAt"`
+}
+
+// AppliedCoupon - Mã giảm giá đã dùng cho 1 đơn hàng
+type AppliedCoupon struct {
+	CouponID  primitive.ObjectID `json:"couponId" bson:"couponId"`
+	Code      string             `json:"code" bson:"code"`
+	Discount  float64            `json:"discount" bson:"discount"`
+	Customer  string             `json:"-" bson:"customer,omitempty"`  // Khóa đếm lượt dùng (đơn cũ, chỉ 1 khóa)
+	Customers []string           `json:"-" bson:"customers,omitempty"` // Các khóa đếm lượt dùng của khách (user + email)
+}
+
+// customers - Khóa đã ghi lượt dùng cho đơn (đơn cũ chỉ có Customer)
+func (a AppliedCoupon) customers() []string {
+	if len(a.Customers) > 0 {
+		return a.Customers
+	}
+	if a.Customer != "" {
+		return []string{a.Customer}
+	}
+	return nil
+}
+
+// CouponUsage - Bộ đếm lượt dùng của 1 khách cho 1 mã (_id = couponId:customer)
+type CouponUsage struct {
+	ID        string               `bson:"_id"`
+	CouponID  primitive.ObjectID   `bson:"couponId"`
+	Customer  string               `bson:"customer"`
+	Count     int                  `bson:"count"`
+	OrderIDs  []primitive.ObjectID `bson:"orderIds,omitempty"`
+	UpdatedAt time.Time            `bson:"updatedAt"`
+}
+
+// InitCouponCollection - Tạo index cho coupons và coupon_usages
+func InitCouponCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("coupons").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "code", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err == nil {
+		_, err = db.Collection("coupon_usages").Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "couponId", Value: 1}},
+		})
+	}
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create coupon index:", err)
+	} else {
+		log.Println("✅ Coupon collection initialized with index")
+	}
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// couponCustomers - Khóa đếm lượt dùng: userId (nếu đã đăng nhập) và từng email đã chuẩn hóa.
+// Lượt dùng ghi vào mọi khóa để không lách perUserLimit bằng cách đổi giữa đăng nhập và khách vãng lai
+func couponCustomers(userID string, emails ...string) []string {
+	keys := []string{}
+	if userID != "" {
+		keys = append(keys, "user:"+userID)
+	}
+	for _, email := range emails {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if email != "" && !containsString(keys, "email:"+email) {
+			keys = append(keys, "email:"+email)
+		}
+	}
+	return keys
+}
+
+func couponUsageID(couponID primitive.ObjectID, customer string) string {
+	return couponID.Hex() + ":" + customer
+}
+
+// validate - Kiểm tra dữ liệu mã giảm giá do admin nhập
+func (c *Coupon) validate() error {
+	c.Code = normalizeCouponCode(c.Code)
+	if c.Code == "" || strings.ContainsAny(c.Code, " \t") {
+		return errors.New("Mã giảm giá không hợp lệ")
+	}
+	switch c.Type {
+	case CouponPercent:
+		if c.Value <= 0 || c.Value > 100 {
+			return errors.New("Giảm theo % phải trong khoảng 1-100")
+		}
+	case CouponFixed:
+		if c.Value <= 0 {
+			return errors.New("Số tiền giảm phải lớn hơn 0")
+		}
+	default:
+		return errors.New("Loại giảm giá phải là \"percent\" hoặc \"fixed\"")
+	}
+	if c.MaxDiscount < 0 || c.MinOrderValue < 0 || c.UsageLimit < 0 || c.PerUserLimit < 0 {
+		return errors.New("Giá trị giới hạn không được âm")
+	}
+	if c.StartsAt != nil && c.EndsAt != nil && !c.EndsAt.After(*c.StartsAt) {
+		return errors.New("Thời gian kết thúc phải sau thời gian bắt đầu")
+	}
+	return nil
+}
+
+// checkWindow - Mã còn hiệu lực và còn lượt tổng
+func (c Coupon) checkWindow(now time.Time) error {
+	if !c.Active {
+		return errCouponInactive
+	}
+	if c.StartsAt != nil && now.Before(*c.StartsAt) {
+		return errCouponNotStarted
+	}
+	if c.EndsAt != nil && !now.Before(*c.EndsAt) {
+		return errCouponExpired
+	}
+	if c.UsageLimit > 0 && c.UsedCount >= c.UsageLimit {
+		return errCouponUsedUp
+	}
+	return nil
+}
+
+func (c Coupon) scoped() bool {
+	return len(c.Categories) > 0 || len(c.Brands) > 0 || len(c.ProductIDs) > 0
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// eligibleAmount - Tổng tiền các dòng hàng thuộc phạm vi áp dụng của mã
+func (c Coupon) eligibleAmount(ctx context.Context, items []OrderItem) (float64, error) {
+	total := 0.0
+	if !c.scoped() {
+		for _, item := range items {
+			total += item.Price * float64(item.Qty)
+		}
+		return total, nil
+	}
+
+	// Cần danh mục/thương hiệu của sản phẩm để xét phạm vi
+	ids := []primitive.ObjectID{}
+	for _, item := range items {
+		if id, err := primitive.ObjectIDFromHex(item.ProductID); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	cursor, err := database.DB.Collection("products").Find(ctx, bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"category": 1, "subcategory": 1, "brand": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return 0, err
+	}
+	byID := map[string]models.Product{}
+	for _, p := range products {
+		byID[p.ID.Hex()] = p
+	}
+
+	for _, item := range items {
+		p := byID[item.ProductID]
+		if containsString(c.ProductIDs, item.ProductID) ||
+			containsString(c.Categories, p.Category) || containsString(c.Categories, p.Subcategory) ||
+			(p.Brand != "" && containsString(c.Brands, p.Brand)) {
+			total += item.Price * float64(item.Qty)
+		}
+	}
+	return total, nil
+}
+
+// discountFor - Số tiền giảm cho phần hàng hợp lệ
+func (c Coupon) discountFor(eligible float64) float64 {
+	discount := c.Value
+	if c.Type == CouponPercent {
+		discount = math.Round(eligible * c.Value / 100)
+		if c.MaxDiscount > 0 && discount > c.MaxDiscount {
+			discount = c.MaxDiscount
+		}
+	}
+	if discount > eligible {
+		discount = eligible
+	}
+	return discount
+}
+
+// evaluateCoupon - Kiểm tra mã cho các dòng hàng đã tính giá và trả về số tiền giảm.
+// Chỉ đọc, không trừ lượt - lượt dùng được ghi khi tạo đơn bằng redeemCoupon.
+func evaluateCoupon(ctx context.Context, code string, customers []string, items []OrderItem, merchandise float64) (Coupon, float64, error) {
+	var coupon Coupon
+	err := database.DB.Collection("coupons").FindOne(ctx, bson.M{"code": normalizeCouponCode(code)}).Decode(&coupon)
+	if err == mongo.ErrNoDocuments {
+		return coupon, 0, errCouponNotFound
+	}
+	if err != nil {
+		return coupon, 0, err
+	}
+
+	if err := coupon.checkWindow(time.Now()); err != nil {
+		return coupon, 0, err
+	}
+	if coupon.MinOrderValue > 0 && merchandise < coupon.MinOrderValue {
+		return coupon, 0, &CouponError{
+			Code:    "COUPON_MIN_ORDER",
+			Message: fmt.Sprintf("Đơn hàng tối thiểu %.0f₫ để dùng mã này", coupon.MinOrderValue),
+		}
+	}
+
+	if coupon.PerUserLimit > 0 {
+		for _, customer := range customers {
+			var usage CouponUsage
+			err := database.DB.Collection("coupon_usages").FindOne(ctx, bson.M{"_id": couponUsageID(coupon.ID, customer)}).Decode(&usage)
+			if err == nil && usage.Count >= coupon.PerUserLimit {
+				return coupon, 0, errCouponUserLimit
+			}
+		}
+	}
+
+	eligible, err := coupon.eligibleAmount(ctx, items)
+	if err != nil {
+		return coupon, 0, err
+	}
+	if eligible <= 0 {
+		return coupon, 0, errCouponNotEligible
+	}
+
+	return coupon, coupon.discountFor(eligible), nil
+}
+
+// redeemCoupon - Ghi nhận 1 lượt dùng (nguyên tử) cho cả giới hạn tổng và giới hạn mỗi khách
+func redeemCoupon(ctx context.Context, coupon Coupon, customers []string, orderID primitive.ObjectID) error {
+	now := time.Now()
+	coupons := database.DB.Collection("coupons")
+
+	// Giới hạn tổng: chỉ tăng khi usedCount < usageLimit
+	filter := bson.M{
+		"_id":    coupon.ID,
+		"active": true,
+		"$or": bson.A{
+			bson.M{"usageLimit": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$usedCount", "$usageLimit"}}},
+		},
+	}
+	result, err := coupons.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"usedCount": 1}, "$set": bson.M{"updatedAt": now}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errCouponUsedUp
+	}
+
+	// Giới hạn mỗi khách: upsert có điều kiện count < perUserLimit cho từng khóa.
+	// Đã đủ lượt thì filter không khớp -> upsert trùng _id -> duplicate key.
+	for i, customer := range customers {
+		usageFilter := bson.M{"_id": couponUsageID(coupon.ID, customer)}
+		if coupon.PerUserLimit > 0 {
+			usageFilter["count"] = bson.M{"$lt": coupon.PerUserLimit}
+		}
+		_, err = database.DB.Collection("coupon_usages").UpdateOne(ctx, usageFilter,
+			bson.M{
+				"$inc":         bson.M{"count": 1},
+				"$push":        bson.M{"orderIds": orderID},
+				"$set":         bson.M{"updatedAt": now},
+				"$setOnInsert": bson.M{"couponId": coupon.ID, "customer": customer},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			// Trả lại lượt đã ghi cho các khóa trước và lượt tổng
+			releaseCouponUsages(ctx, coupon.ID, customers[:i], orderID)
+			coupons.UpdateOne(ctx, bson.M{"_id": coupon.ID}, bson.M{"$inc": bson.M{"usedCount": -1}})
+			if mongo.IsDuplicateKeyError(err) {
+				return errCouponUserLimit
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseCouponUsages - Trả lượt dùng của đơn trên từng khóa khách, trả về số khóa thực sự được trả
+func releaseCouponUsages(ctx context.Context, couponID primitive.ObjectID, customers []string, orderID primitive.ObjectID) (int64, error) {
+	var released int64
+	for _, customer := range customers {
+		result, err := database.DB.Collection("coupon_usages").UpdateOne(ctx,
+			bson.M{"_id": couponUsageID(couponID, customer), "orderIds": orderID},
+			bson.M{"$inc": bson.M{"count": -1}, "$pull": bson.M{"orderIds": orderID}, "$set": bson.M{"updatedAt": time.Now()}},
+		)
+		if err != nil {
+			return released, err
+		}
+		released += result.ModifiedCount
+	}
+	return released, nil
+}
+
+// releaseCoupon - Trả lại lượt dùng khi tạo đơn thất bại hoặc đơn bị hủy
+func releaseCoupon(ctx context.Context, applied *AppliedCoupon, orderID primitive.ObjectID) {
+	if applied == nil {
+		return
+	}
+
+	released, err := releaseCouponUsages(ctx, applied.CouponID, applied.customers(), orderID)
+	if err != nil {
+		log.Println("❌ Failed to release coupon usage:", err)
+		return
+	}
+	// Lượt đã được trả trước đó (vd: hủy 2 lần) thì không giảm usedCount nữa
+	if released == 0 {
+		return
+	}
+
+	_, err = database.DB.Collection("coupons").UpdateOne(ctx,
+		bson.M{"_id": applied.CouponID, "usedCount": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"usedCount": -1}},
+	)
+	if err != nil {
+		log.Println("❌ Failed to release coupon:", err)
+	}
+}
+
+// writeCouponError - Trả lỗi mã giảm giá
+func writeCouponError(w http.ResponseWriter, err error) {
+	if couponErr, ok := err.(*CouponError); ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": couponErr.Message,
+			"code":  couponErr.Code,
+		})
+		return
+	}
+
+	log.Println("❌ Coupon error:", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Không thể áp dụng mã giảm giá"})
+}
+
+// ApplyCartCoupon - Áp dụng mã giảm giá cho giỏ hàng (code rỗng = gỡ mã)
+func ApplyCartCoupon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	owner, ok := getCartOwner(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Giỏ hàng trống"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	code := normalizeCouponCode(req.Code)
+	if code == "" {
+		removeCartCoupon(ctx, w, owner)
+		return
+	}
+
+	var cart Cart
+	if err := cartCollection.FindOne(ctx, owner.filter()).Decode(&cart); err != nil || len(cart.Items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Giỏ hàng trống"})
+		return
+	}
+
+	items := make([]OrderItem, 0, len(cart.Items))
+	for _, ci := range cart.Items {
+		items = append(items, OrderItem{
+			ProductID:     ci.ProductID,
+			Qty:           ci.Qty,
+			SelectedColor: ci.SelectedColor,
+			SelectedSize:  ci.SelectedSize,
+		})
+	}
+	applyItemDefaults(items)
+	priced, breakdown, err := priceOrderItems(ctx, items)
+	if err != nil {
+		writePricingError(w, err)
+		return
+	}
+
+	email, _ := r.Context().Value("email").(string)
+	coupon, discount, err := evaluateCoupon(ctx, code, couponCustomers(owner.UserID, email), priced, breakdown.GrandTotal)
+	if err != nil {
+		writeCouponError(w, err)
+		return
+	}
+
+	_, err = cartCollection.UpdateOne(ctx, owner.filter(), bson.M{"$set": bson.M{"couponCode": coupon.Code, "updatedAt": time.Now()}})
+	if err != nil {
+		log.Println("❌ ApplyCartCoupon error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể áp dụng mã giảm giá"})
+		return
+	}
+
+	breakdown.CouponCode = coupon.Code
+	breakdown.CouponDiscount = discount
+	breakdown.recalc()
+
+	log.Printf("✅ Coupon %s applied to cart of %s\n", coupon.Code, owner)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"couponCode":  coupon.Code,
+		"description": coupon.Description,
+		"discount":    discount,
+		"pricing":     breakdown,
+	})
+}
+
+// RemoveCartCoupon - Gỡ mã giảm giá khỏi giỏ hàng
+func RemoveCartCoupon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	owner, ok := getCartOwner(r)
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]string{"message": "Đã gỡ mã giảm giá"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	removeCartCoupon(ctx, w, owner)
+}
+
+func removeCartCoupon(ctx context.Context, w http.ResponseWriter, owner cartOwner) {
+	_, err := cartCollection.UpdateOne(ctx, owner.filter(), bson.M{"$unset": bson.M{"couponCode": ""}})
+	if err != nil {
+		log.Println("❌ RemoveCartCoupon error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể gỡ mã giảm giá"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Đã gỡ mã giảm giá"})
+}
+
+// GetCoupons - Admin xem danh sách mã giảm giá
+func GetCoupons(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := database.DB.Collection("coupons").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch coupons"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var coupons []Coupon
+	if err := cursor.All(ctx, &coupons); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to fetch coupons"})
+		return
+	}
+	if coupons == nil {
+		coupons = []Coupon{}
+	}
+
+	json.NewEncoder(w).Encode(coupons)
+}
+
+// CreateCoupon - Admin tạo mã giảm giá
+func CreateCoupon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var coupon Coupon
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+	if err := coupon.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	coupon.ID = primitive.NewObjectID()
+	coupon.UsedCount = 0
+	coupon.CreatedAt = now
+	coupon.UpdatedAt = now
+
+	if _, err := database.DB.Collection("coupons").InsertOne(ctx, coupon); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Mã giảm giá đã tồn tại"})
+			return
+		}
+		log.Println("❌ CreateCoupon error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to create coupon"})
+		return
+	}
+
+	writeAudit(ctx, r, "coupon.create", "coupon", coupon.ID.Hex(), map[string]interface{}{"code": coupon.Code})
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(coupon)
+}
+
+// UpdateCoupon - Admin sửa mã giảm giá (không đổi được usedCount)
+func UpdateCoupon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid coupon ID"})
+		return
+	}
+
+	var coupon Coupon
+	if err := json.NewDecoder(r.Body).Decode(&coupon); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+	if err := coupon.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var updated Coupon
+	err = database.DB.Collection("coupons").FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$set": bson.M{
+			"code":          coupon.Code,
+			"description":   coupon.Description,
+			"type":          coupon.Type,
+			"value":         coupon.Value,
+			"maxDiscount":   coupon.MaxDiscount,
+			"minOrderValue": coupon.MinOrderValue,
+			"categories":    coupon.Categories,
+			"brands":        coupon.Brands,
+			"productIds":    coupon.ProductIDs,
+			"usageLimit":    coupon.UsageLimit,
+			"perUserLimit":  coupon.PerUserLimit,
+			"startsAt":      coupon.StartsAt,
+			"endsAt":        coupon.EndsAt,
+			"active":        coupon.Active,
+			"updatedAt":     time.Now(),
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Coupon not found"})
+		return
+	}
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Mã giảm giá đã tồn tại"})
+			return
+		}
+		log.Println("❌ UpdateCoupon error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update coupon"})
+		return
+	}
+
+	writeAudit(ctx, r, "coupon.update", "coupon", objectID.Hex(), map[string]interface{}{"code": updated.Code})
+
+	json.NewEncoder(w).Encode(updated)
+}
+
+// DeleteCoupon - Admin xóa mã giảm giá (đơn hàng cũ vẫn giữ snapshot mã đã dùng)
+func DeleteCoupon(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid coupon ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := database.DB.Collection("coupons").DeleteOne(ctx, bson.M{"_id": objectID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to delete coupon"})
+		return
+	}
+	if result.DeletedCount == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Coupon not found"})
+		return
+	}
+
+	writeAudit(ctx, r, "coupon.delete", "coupon", objectID.Hex(), nil)
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Coupon deleted"})
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+)
+
+func TestCouponDiscountFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		coupon   Coupon
+		eligible float64
+		want     float64
+	}{
+		{"percent", Coupon{Type: CouponPercent, Value: 10}, 500000, 50000},
+		{"percent rounds", Coupon{Type: CouponPercent, Value: 15}, 99999, 15000},
+		{"percent capped", Coupon{Type: CouponPercent, Value: 50, MaxDiscount: 100000}, 500000, 100000},
+		{"percent under cap", Coupon{Type: CouponPercent, Value: 10, MaxDiscount: 100000}, 500000, 50000},
+		{"fixed", Coupon{Type: CouponFixed, Value: 30000}, 500000, 30000},
+		{"fixed above eligible", Coupon{Type: CouponFixed, Value: 30000}, 20000, 20000},
+		{"percent 100", Coupon{Type: CouponPercent, Value: 100}, 250000, 250000},
+	}
+	for _, tt := range tests {
+		if got := tt.coupon.discountFor(tt.eligible); got != tt.want {
+			t.Errorf("%s: discountFor(%v) = %v, want %v", tt.name, tt.eligible, got, tt.want)
+		}
+	}
+}
+
+func TestCouponCheckWindow(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+	tests := []struct {
+		name   string
+		coupon Coupon
+		want   error
+	}{
+		{"active", Coupon{Active: true}, nil},
+		{"inactive", Coupon{Active: false}, errCouponInactive},
+		{"not started", Coupon{Active: true, StartsAt: &after}, errCouponNotStarted},
+		{"started", Coupon{Active: true, StartsAt: &before, EndsAt: &after}, nil},
+		{"expired", Coupon{Active: true, EndsAt: &before}, errCouponExpired},
+		{"ends now", Coupon{Active: true, EndsAt: &now}, errCouponExpired},
+		{"used up", Coupon{Active: true, UsageLimit: 5, UsedCount: 5}, errCouponUsedUp},
+		{"last use", Coupon{Active: true, UsageLimit: 5, UsedCount: 4}, nil},
+		{"unlimited", Coupon{Active: true, UsageLimit: 0, UsedCount: 1000}, nil},
+	}
+	for _, tt := range tests {
+		if got := tt.coupon.checkWindow(now); got != tt.want {
+			t.Errorf("%s: checkWindow = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCouponValidate(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	tests := []struct {
+		name    string
+		coupon  Coupon
+		wantErr bool
+	}{
+		{"percent", Coupon{Code: " sale10 ", Type: CouponPercent, Value: 10}, false},
+		{"fixed", Coupon{Code: "GIAM30K", Type: CouponFixed, Value: 30000}, false},
+		{"empty code", Coupon{Code: "  ", Type: CouponFixed, Value: 1}, true},
+		{"code with space", Coupon{Code: "SALE 10", Type: CouponFixed, Value: 1}, true},
+		{"percent over 100", Coupon{Code: "X", Type: CouponPercent, Value: 101}, true},
+		{"percent zero", Coupon{Code: "X", Type: CouponPercent, Value: 0}, true},
+		{"fixed negative", Coupon{Code: "X", Type: CouponFixed, Value: -1}, true},
+		{"unknown type", Coupon{Code: "X", Type: "bogo", Value: 1}, true},
+		{"negative limit", Coupon{Code: "X", Type: CouponFixed, Value: 1, UsageLimit: -1}, true},
+		{"window", Coupon{Code: "X", Type: CouponFixed, Value: 1, StartsAt: &start, EndsAt: &end}, false},
+		{"window reversed", Coupon{Code: "X", Type: CouponFixed, Value: 1, StartsAt: &end, EndsAt: &start}, true},
+	}
+	for _, tt := range tests {
+		err := tt.coupon.validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	c := Coupon{Code: " sale10 ", Type: CouponPercent, Value: 10}
+	c.validate()
+	if c.Code != "SALE10" {
+		t.Errorf("validate() code = %q, want %q", c.Code, "SALE10")
+	}
+}
+
+func TestCouponCustomers(t *testing.T) {
+	tests := []struct {
+		userID string
+		emails []string
+		want   []string
+	}{
+		{"abc", []string{"a@x.vn"}, []string{"user:abc", "email:a@x.vn"}},
+		{"abc", []string{"a@x.vn", " A@X.vn"}, []string{"user:abc", "email:a@x.vn"}},
+		{"abc", []string{"a@x.vn", "b@x.vn"}, []string{"user:abc", "email:a@x.vn", "email:b@x.vn"}},
+		{"", []string{" A@X.VN "}, []string{"email:a@x.vn"}},
+		{"abc", []string{""}, []string{"user:abc"}},
+		{"", []string{""}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := couponCustomers(tt.userID, tt.emails...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("couponCustomers(%q, %q) = %q, want %q", tt.userID, tt.emails, got, tt.want)
+		}
+	}
+}
+
+// useTestDB - Trỏ database.DB sang 1 database tạm trên MONGO_TEST_URI, bỏ qua test nếu không có
+func useTestDB(t *testing.T) {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := database.DB
+	database.DB = client.Database(fmt.Sprintf("gosporty_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		database.DB.Drop(ctx)
+		client.Disconnect(ctx)
+		database.DB = previous
+	})
+}
+
+// redeemConcurrently - Gọi redeemCoupon song song, customer(i) là các khóa khách của lượt thứ i
+func redeemConcurrently(coupon Coupon, n int, customer func(i int) []string) []error {
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			errs[i] = redeemCoupon(ctx, coupon, customer(i), primitive.NewObjectID())
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestRedeemCouponConcurrent(t *testing.T) {
+	useTestDB(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		coupon    Coupon
+		customer  func(i int) []string
+		wantOK    int
+		wantErr   error
+		wantCount int
+	}{
+		{
+			name:      "usage limit",
+			coupon:    Coupon{Code: "LIMIT3", UsageLimit: 3},
+			customer:  func(i int) []string { return []string{fmt.Sprintf("user:%d", i)} },
+			wantOK:    3,
+			wantErr:   errCouponUsedUp,
+			wantCount: 3,
+		},
+		{
+			name:      "per user limit",
+			coupon:    Coupon{Code: "ONEEACH", PerUserLimit: 1},
+			customer:  func(int) []string { return []string{"user:same"} },
+			wantOK:    1,
+			wantErr:   errCouponUserLimit,
+			wantCount: 1,
+		},
+		{
+			// Cùng email, lúc đăng nhập lúc đặt với tư cách khách vãng lai
+			name:   "per user limit across login and guest",
+			coupon: Coupon{Code: "ONEMAIL", PerUserLimit: 1},
+			customer: func(i int) []string {
+				if i%2 == 0 {
+					return couponCustomers("u1", "a@x.vn")
+				}
+				return couponCustomers("", "A@x.vn")
+			},
+			wantOK:    1,
+			wantErr:   errCouponUserLimit,
+			wantCount: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt.coupon.ID = primitive.NewObjectID()
+		tt.coupon.Type = CouponFixed
+		tt.coupon.Value = 10000
+		tt.coupon.Active = true
+		if _, err := database.DB.Collection("coupons").InsertOne(ctx, tt.coupon); err != nil {
+			t.Fatal(err)
+		}
+
+		ok := 0
+		for _, err := range redeemConcurrently(tt.coupon, 20, tt.customer) {
+			switch {
+			case err == nil:
+				ok++
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("%s: redeemCoupon = %v, want %v", tt.name, err, tt.wantErr)
+			}
+		}
+		if ok != tt.wantOK {
+			t.Errorf("%s: %d redemptions succeeded, want %d", tt.name, ok, tt.wantOK)
+		}
+
+		var stored Coupon
+		if err := database.DB.Collection("coupons").FindOne(ctx, bson.M{"_id": tt.coupon.ID}).Decode(&stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.UsedCount != tt.wantCount {
+			t.Errorf("%s: usedCount = %d, want %d", tt.name, stored.UsedCount, tt.wantCount)
+		}
+	}
+}
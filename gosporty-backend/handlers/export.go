@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+	"gosporty-backend/export"
+	"gosporty-backend/models"
+)
+
+const (
+	// exportTimeout - File lớn stream lâu hơn request thường
+	exportTimeout = 5 * time.Minute
+
+	exportBatchSize  = 500
+	exportFlushEvery = 500
+)
+
+// exportStream - Ghi từng dòng ra response, định kỳ đẩy xuống client để không giữ cả file trong bộ nhớ
+type exportStream struct {
+	w    http.ResponseWriter
+	out  export.Writer
+	rows int
+}
+
+// exportFormat - Đọc ?format=csv|xlsx; lỗi thì trả JSON 400 (chưa gửi header file)
+func exportFormat(w http.ResponseWriter, q url.Values) (export.Format, bool) {
+	format, err := export.ParseFormat(q.Get("format"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Định dạng không hỗ trợ (csv | xlsx)",
+			"code":  "INVALID_FORMAT",
+		})
+		return "", false
+	}
+	return format, true
+}
+
+// newExportStream - Gửi header tải file và dòng tiêu đề cột
+func newExportStream(w http.ResponseWriter, format export.Format, name string, header []string) (*exportStream, error) {
+	filename := name + "-" + time.Now().In(export.Location).Format("20060102-150405") + format.Extension()
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	out, err := export.NewWriter(w, format, name, header)
+	if err != nil {
+		return nil, err
+	}
+	return &exportStream{w: w, out: out}, nil
+}
+
+func (s *exportStream) write(row ...interface{}) error {
+	if err := s.out.Write(row); err != nil {
+		return err
+	}
+	s.rows++
+	if s.rows%exportFlushEvery == 0 {
+		if err := s.out.Flush(); err != nil {
+			return err
+		}
+		if f, ok := s.w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+	return nil
+}
+
+// finish - Đóng file. Lỗi giữa chừng chỉ ghi log được vì header 200 đã gửi (client nhận file thiếu)
+func (s *exportStream) finish(name string, cursor *mongo.Cursor, err error) {
+	if err == nil {
+		err = cursor.Err()
+	}
+	if closeErr := s.out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("❌ Export %s aborted after %d rows: %v\n", name, s.rows, err)
+		return
+	}
+	log.Printf("✅ Exported %d %s rows\n", s.rows, name)
+}
+
+// exportFindError - Lỗi truy vấn trước khi bắt đầu stream
+func exportFindError(w http.ResponseWriter, name string, err error) {
+	log.Printf("❌ Export %s failed: %v\n", name, err)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{"error": "Không thể xuất dữ liệu"})
+}
+
+// ExportOrders - Xuất đơn hàng CSV/XLSX, mỗi dòng 1 sản phẩm trong đơn.
+// Nhận cùng tham số lọc/sắp xếp với GET /api/admin/orders (trừ page, limit).
+func ExportOrders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	format, ok := exportFormat(w, q)
+	if !ok {
+		return
+	}
+	filter, err := orderListFilter(q)
+	if err != nil {
+		writeFilterError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	cursor, err := database.DB.Collection("orders").Find(ctx, filter,
+		options.Find().SetSort(orderListSort(q)).SetBatchSize(exportBatchSize))
+	if err != nil {
+		exportFindError(w, "orders", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	s, err := newExportStream(w, format, "orders", []string{
+		"Mã đơn", "Ngày đặt", "Trạng thái", "Phương thức thanh toán", "Trạng thái thanh toán", "Ngày thanh toán",
+		"Khách hàng", "Email", "SĐT", "Địa chỉ", "Vận chuyển", "Mã vận đơn",
+		"STT", "Mã sản phẩm", "Sản phẩm", "Màu", "Size", "SKU", "Số lượng", "Đơn giá", "Thành tiền",
+		"Tạm tính", "Giảm giá sản phẩm", "Mã giảm giá", "Giảm giá mã", "Phí vận chuyển", "Tổng đơn", "Đã hoàn tiền",
+	})
+	if err != nil {
+		exportFindError(w, "orders", err)
+		return
+	}
+
+	for err == nil && cursor.Next(ctx) {
+		var order Order
+		if decodeErr := cursor.Decode(&order); decodeErr != nil {
+			log.Println("⚠️ Export orders: skip undecodable order:", decodeErr)
+			continue
+		}
+
+		// Cột cấp đơn hàng lặp lại trên từng dòng sản phẩm để lọc/pivot trên Excel
+		trackingCode := ""
+		if order.Shipment != nil {
+			trackingCode = order.Shipment.TrackingCode
+		}
+		var subtotal, discount, couponDiscount interface{}
+		couponCode := ""
+		if p := order.Pricing; p != nil {
+			subtotal, discount, couponDiscount, couponCode = p.Subtotal, p.Discount, p.CouponDiscount, p.CouponCode
+		}
+		if couponCode == "" && order.Coupon != nil {
+			couponCode = order.Coupon.Code
+		}
+		_, _, address := recipient(order)
+		head := []interface{}{
+			orderLabel(order), order.CreatedAt, string(order.Status.Normalize()), order.PaymentMethod,
+			labelOr(paymentStatusLabels, order.PaymentStatus), order.PaidAt,
+			order.CustomerName, order.CustomerEmail, order.CustomerPhone, address,
+			labelOr(shippingMethodLabels, order.ShippingMethod), trackingCode,
+		}
+		tail := []interface{}{
+			subtotal, discount, couponCode, couponDiscount, order.ShippingFee, order.Total, order.RefundedAmount,
+		}
+
+		if len(order.Items) == 0 {
+			err = s.write(append(append(head, nil, nil, nil, nil, nil, nil, nil, nil, nil), tail...)...)
+			continue
+		}
+		for i, item := range order.Items {
+			row := append([]interface{}{}, head...)
+			row = append(row,
+				i+1, item.ProductID, item.Name, item.SelectedColor, item.SelectedSize, item.SKU,
+				item.Qty, item.Price, item.Price*float64(item.Qty),
+			)
+			if err = s.write(append(row, tail...)...); err != nil {
+				break
+			}
+		}
+	}
+	s.finish("orders", cursor, err)
+}
+
+// ExportProducts - Xuất sản phẩm CSV/XLSX, mỗi dòng 1 phân loại (màu × size) kèm tồn kho.
+// Nhận cùng tham số lọc/sắp xếp với GET /api/products (category, subcategory, search, sort).
+func ExportProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	format, ok := exportFormat(w, q)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	cursor, err := database.DB.Collection("products").Find(ctx, productListFilter(q),
+		options.Find().SetSort(productListSort(q)).SetBatchSize(exportBatchSize))
+	if err != nil {
+		exportFindError(w, "products", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	s, err := newExportStream(w, format, "products", []string{
+		"ID", "Tên sản phẩm", "Slug", "Danh mục", "Danh mục con", "Thương hiệu",
+		"Giá", "Giá gốc", "Giảm giá (%)", "Tồn kho sản phẩm",
+		"SKU", "Màu", "Size", "Giá phân loại", "Tồn kho phân loại", "Ngày tạo",
+	})
+	if err != nil {
+		exportFindError(w, "products", err)
+		return
+	}
+
+	for err == nil && cursor.Next(ctx) {
+		var p models.Product
+		if decodeErr := cursor.Decode(&p); decodeErr != nil {
+			log.Println("⚠️ Export products: skip undecodable product:", decodeErr)
+			continue
+		}
+
+		head := []interface{}{
+			p.ID.Hex(), p.Name, p.Slug, p.Category, p.Subcategory, p.Brand,
+			p.Price, p.OriginalPrice, p.Discount, p.Stock,
+		}
+		createdAt := p.CreatedAt.Time()
+
+		// Sản phẩm chưa có ma trận variant: 1 dòng với tồn kho chung
+		if len(p.Variants) == 0 {
+			err = s.write(append(head, nil, nil, nil, p.Price, p.Stock, createdAt)...)
+			continue
+		}
+		for _, v := range p.Variants {
+			price := p.Price
+			if v.Price > 0 {
+				price = v.Price
+			}
+			row := append([]interface{}{}, head...)
+			if err = s.write(append(row, v.SKU, v.Color, v.Size, price, v.Stock, createdAt)...); err != nil {
+				break
+			}
+		}
+	}
+	s.finish("products", cursor, err)
+}
+
+// customerListFilter - Bỏ qua tài khoản đã xóa, trừ khi ?includeDeleted=true (dùng chung cho danh sách và export)
+func customerListFilter(q url.Values) bson.M {
+	if q.Get("includeDeleted") == "true" {
+		return bson.M{}
+	}
+	return activeUsersFilter()
+}
+
+// customerExportRow - User kèm số đơn và tổng chi tiêu (cùng cách tính với GetUsersWithStats:
+// khớp userId của đơn với _id dạng chuỗi hex, tổng chi tiêu theo netRevenueExpr)
+type customerExportRow struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Name          string             `bson:"name"`
+	Email         string             `bson:"email"`
+	Phone         string             `bson:"phone"`
+	IsAdmin       bool               `bson:"isAdmin"`
+	Status        string             `bson:"status"`
+	EmailVerified bool               `bson:"emailVerified"`
+	Stats         []struct {
+		TotalOrders int64   `bson:"totalOrders"`
+		TotalSpent  float64 `bson:"totalSpent"`
+	} `bson:"stats"`
+}
+
+// ExportCustomers - Xuất khách hàng CSV/XLSX kèm TotalOrders / TotalSpent.
+// Nhận cùng tham số lọc với GET /api/admin/users (includeDeleted).
+func ExportCustomers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	format, ok := exportFormat(w, q)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	// Thống kê đơn tính ngay trong aggregate ($lookup theo userId) thay vì 2 truy vấn cho mỗi user
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: customerListFilter(q)}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		{{Key: "$project", Value: bson.D{{Key: "password", Value: 0}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "orders"},
+			{Key: "let", Value: bson.D{{Key: "uid", Value: bson.D{{Key: "$toString", Value: "$_id"}}}}},
+			{Key: "pipeline", Value: mongo.Pipeline{
+				{{Key: "$match", Value: bson.D{{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$userId", "$$uid"}}}}}}},
+				{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: nil},
+					{Key: "totalOrders", Value: bson.D{{Key: "$sum", Value: 1}}},
+					{Key: "totalSpent", Value: bson.D{{Key: "$sum", Value: netRevenueExpr}}},
+				}}},
+			}},
+			{Key: "as", Value: "stats"},
+		}}},
+	}
+	cursor, err := database.DB.Collection("users").Aggregate(ctx, pipeline,
+		options.Aggregate().SetBatchSize(exportBatchSize))
+	if err != nil {
+		exportFindError(w, "customers", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	s, err := newExportStream(w, format, "customers", []string{
+		"ID", "Họ tên", "Email", "SĐT", "Vai trò", "Trạng thái", "Đã xác thực email", "Ngày tham gia",
+		"TotalOrders", "TotalSpent",
+	})
+	if err != nil {
+		exportFindError(w, "customers", err)
+		return
+	}
+
+	for err == nil && cursor.Next(ctx) {
+		var c customerExportRow
+		if decodeErr := cursor.Decode(&c); decodeErr != nil {
+			log.Println("⚠️ Export customers: skip undecodable user:", decodeErr)
+			continue
+		}
+
+		role, status := "user", c.Status
+		if c.IsAdmin {
+			role = "admin"
+		}
+		if status == "" {
+			status = "active"
+		}
+		var totalOrders int64
+		var totalSpent float64
+		if len(c.Stats) > 0 {
+			totalOrders, totalSpent = c.Stats[0].TotalOrders, c.Stats[0].TotalSpent
+		}
+
+		// User chưa lưu createdAt -> ngày tham gia lấy từ thời điểm tạo ObjectID
+		err = s.write(c.ID.Hex(), c.Name, c.Email, c.Phone, role, status, c.EmailVerified,
+			c.ID.Timestamp(), totalOrders, totalSpent)
+	}
+	s.finish("customers", cursor, err)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CartTokenHeader - Header chứa mã giỏ hàng của khách chưa đăng nhập
+const CartTokenHeader = "X-Cart-Token"
+
+// guestCartTTL - Giỏ hàng khách tự xóa sau 30 ngày không cập nhật
+const guestCartTTL = 30 * 24 * time.Hour
+
+// cartOwner - Chủ sở hữu giỏ hàng: user đã đăng nhập hoặc khách (guest token)
+type cartOwner struct {
+	UserID  string
+	GuestID string
+}
+
+// filter - Điều kiện tìm giỏ hàng của owner
+func (o cartOwner) filter() bson.M {
+	if o.UserID != "" {
+		return bson.M{"userId": o.UserID}
+	}
+	return bson.M{"guestId": o.GuestID}
+}
+
+func (o cartOwner) String() string {
+	if o.UserID != "" {
+		return "user:" + o.UserID
+	}
+	return "guest:" + o.GuestID
+}
+
+// isValidCartToken - Token khách là 32 byte ngẫu nhiên dạng hex
+func isValidCartToken(token string) bool {
+	if len(token) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// newCartToken - Sinh guest cart token ngẫu nhiên, không đoán được
+func newCartToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// getCartOwner - Ưu tiên user từ JWT, nếu không có thì dùng guest token trong header
+func getCartOwner(r *http.Request) (cartOwner, bool) {
+	if userID, ok := GetUserIDFromContext(r); ok && userID != "" {
+		return cartOwner{UserID: userID}, true
+	}
+
+	token := r.Header.Get(CartTokenHeader)
+	if isValidCartToken(token) {
+		return cartOwner{GuestID: token}, true
+	}
+
+	return cartOwner{}, false
+}
+
+// mergeCartItems - Gộp items theo khóa productId/màu/size giống AddToCart
+func mergeCartItems(items []CartItem, extra []CartItem) []CartItem {
+	for _, newItem := range extra {
+		found := false
+		for i := range items {
+			if items[i].ProductID == newItem.ProductID &&
+				items[i].SelectedColor == newItem.SelectedColor &&
+				items[i].SelectedSize == newItem.SelectedSize {
+				items[i].Qty += newItem.Qty
+				found = true
+				break
+			}
+		}
+		if !found {
+			items = append(items, newItem)
+		}
+	}
+	return items
+}
+
+// MergeGuestCart - Gộp giỏ hàng khách vào giỏ hàng của user sau khi đăng nhập
+func MergeGuestCart(ctx context.Context, guestID, userID string) error {
+	if !isValidCartToken(guestID) || userID == "" {
+		return nil
+	}
+
+	var guestCart Cart
+	err := cartCollection.FindOne(ctx, bson.M{"guestId": guestID}).Decode(&guestCart)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var userCart Cart
+	err = cartCollection.FindOne(ctx, bson.M{"userId": userID}).Decode(&userCart)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	items := mergeCartItems(userCart.Items, guestCart.Items)
+	if items == nil {
+		items = []CartItem{}
+	}
+
+	set := bson.M{
+		"items":     items,
+		"updatedAt": time.Now(),
+	}
+	// Giữ mã giảm giá khách đã áp dụng nếu giỏ của user chưa có mã (mã được kiểm tra lại khi đặt hàng)
+	if userCart.CouponCode == "" && guestCart.CouponCode != "" {
+		set["couponCode"] = guestCart.CouponCode
+	}
+
+	_, err = cartCollection.UpdateOne(ctx,
+		bson.M{"userId": userID},
+		bson.M{"$set": set},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = cartCollection.DeleteOne(ctx, bson.M{"guestId": guestID})
+	return err
+}
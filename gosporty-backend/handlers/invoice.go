@@ -0,0 +1,598 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gosporty-backend/database"
+	"gosporty-backend/notify"
+	"gosporty-backend/pdf"
+)
+
+// Bố cục trang in (point, khổ A4)
+const (
+	sheetMargin = 40.0
+	sheetRight  = pdf.PageWidth - sheetMargin
+	sheetWidth  = sheetRight - sheetMargin
+	sheetBottom = pdf.PageHeight - 60
+)
+
+var (
+	sheetBrand = pdf.Color{R: 230, G: 57, B: 70}
+	sheetMuted = pdf.Color{R: 110, G: 110, B: 110}
+	sheetLight = pdf.Color{R: 242, G: 242, B: 242}
+	sheetLine  = pdf.Color{R: 210, G: 210, B: 210}
+)
+
+// shopInfo - Thông tin shop in trên hóa đơn / phiếu đóng gói
+type shopInfo struct {
+	Name    string
+	Address string
+	Phone   string
+	Email   string
+	TaxCode string
+}
+
+// shopBranding - Thông tin shop từ env SHOP_*
+func shopBranding() shopInfo {
+	shop := shopInfo{
+		Name:    os.Getenv("SHOP_NAME"),
+		Address: os.Getenv("SHOP_ADDRESS"),
+		Phone:   os.Getenv("SHOP_PHONE"),
+		Email:   os.Getenv("SHOP_EMAIL"),
+		TaxCode: os.Getenv("SHOP_TAX_CODE"),
+	}
+	if shop.Name == "" {
+		shop.Name = "GoSporty"
+	}
+	return shop
+}
+
+// paymentStatusLabels - Tên trạng thái thanh toán in trên hóa đơn
+var paymentStatusLabels = map[string]string{
+	PaymentUnpaid:   "Chưa thanh toán",
+	PaymentPaid:     "Đã thanh toán",
+	PaymentRefunded: "Đã hoàn tiền",
+	PaymentFailed:   "Thanh toán thất bại",
+}
+
+// shippingMethodLabels - Tên phương thức vận chuyển in trên hóa đơn
+var shippingMethodLabels = map[string]string{
+	ShippingStandard: "Tiêu chuẩn",
+	ShippingExpress:  "Hỏa tốc",
+}
+
+func labelOr(labels map[string]string, key string) string {
+	if label, ok := labels[key]; ok {
+		return label
+	}
+	return key
+}
+
+// sheetColumn - 1 cột của bảng sản phẩm
+type sheetColumn struct {
+	Title string
+	Width float64
+	Right bool // Căn phải (cột số)
+}
+
+// orderSheet - Trang in của 1 đơn hàng: header shop + tiêu đề, tự sang trang khi hết chỗ
+type orderSheet struct {
+	doc     *pdf.Document
+	shop    shopInfo
+	title   string
+	order   Order
+	columns []sheetColumn
+	y       float64
+}
+
+func newOrderSheet(title string, order Order) (*orderSheet, error) {
+	doc, err := pdf.New()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &orderSheet{doc: doc, shop: shopBranding(), title: title, order: order}
+	doc.Title = title + " " + orderLabel(order)
+	doc.Author = s.shop.Name
+	s.newPage()
+	return s, nil
+}
+
+// newPage - Trang mới với header shop, tiêu đề, mã đơn và số trang
+func (s *orderSheet) newPage() {
+	d := s.doc
+	d.AddPage()
+
+	d.SetFillColor(sheetBrand)
+	d.Rect(0, 0, pdf.PageWidth, 6, true, false)
+
+	d.SetFont(pdf.Bold, 18)
+	d.Text(sheetMargin, 48, s.shop.Name)
+
+	d.SetFillColor(sheetMuted)
+	d.SetFont(pdf.Regular, 8.5)
+	y := 64.0
+	for _, line := range []string{
+		s.shop.Address,
+		joinNonEmpty(" · ", prefixed("ĐT: ", s.shop.Phone), s.shop.Email),
+		prefixed("MST: ", s.shop.TaxCode),
+	} {
+		if line != "" {
+			d.Text(sheetMargin, y, line)
+			y += 12
+		}
+	}
+
+	d.SetFillColor(pdf.Black)
+	d.SetFont(pdf.Bold, 15)
+	d.TextRight(sheetRight, 46, s.title)
+	d.SetFont(pdf.Regular, 9)
+	d.TextRight(sheetRight, 62, "Mã đơn: "+orderLabel(s.order))
+	d.TextRight(sheetRight, 74, "Ngày đặt: "+s.order.CreatedAt.In(orderNumberZone).Format("02/01/2006 15:04"))
+	d.SetFillColor(sheetMuted)
+	d.TextRight(sheetRight, 86, fmt.Sprintf("Trang %d", d.PageCount()))
+
+	s.y = math.Max(y, 86) + 14
+	d.SetStrokeColor(sheetLine)
+	d.Line(sheetMargin, s.y, sheetRight, s.y)
+	d.SetFillColor(pdf.Black)
+	s.y += 20
+}
+
+// ensure - Sang trang mới nếu không đủ chỗ cho h point (bảng đang in thì in lại tiêu đề cột)
+func (s *orderSheet) ensure(h float64) {
+	if s.y+h <= sheetBottom {
+		return
+	}
+	s.newPage()
+	if s.columns != nil {
+		s.tableHeader()
+	}
+}
+
+// infoBox - Khối thông tin có tiêu đề, các dòng tự xuống dòng trong width
+func (s *orderSheet) infoBox(x, y, width float64, title string, lines []string) float64 {
+	d := s.doc
+	d.SetFillColor(sheetMuted)
+	d.SetFont(pdf.Bold, 8)
+	d.Text(x, y, title)
+	y += 14
+
+	d.SetFillColor(pdf.Black)
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if i == 0 {
+			d.SetFont(pdf.Bold, 10)
+		} else {
+			d.SetFont(pdf.Regular, 9)
+		}
+		for _, wrapped := range d.Wrap(line, width) {
+			d.Text(x, y, wrapped)
+			y += 13
+		}
+	}
+	return y
+}
+
+// infoBoxes - 2 khối thông tin cạnh nhau
+func (s *orderSheet) infoBoxes(leftTitle string, left []string, rightTitle string, right []string) {
+	half := (sheetWidth - 20) / 2
+	bottom := math.Max(
+		s.infoBox(sheetMargin, s.y, half, leftTitle, left),
+		s.infoBox(sheetMargin+half+20, s.y, half, rightTitle, right),
+	)
+	s.y = bottom + 12
+}
+
+// startTable - Bắt đầu bảng sản phẩm (cột cuối cùng co giãn hết phần còn lại nếu Width = 0)
+func (s *orderSheet) startTable(columns []sheetColumn) {
+	fixed := 0.0
+	for _, c := range columns {
+		fixed += c.Width
+	}
+	for i := range columns {
+		if columns[i].Width == 0 {
+			columns[i].Width = sheetWidth - fixed
+		}
+	}
+	s.columns = columns
+	s.ensure(60)
+	s.tableHeader()
+}
+
+func (s *orderSheet) tableHeader() {
+	d := s.doc
+	d.SetFillColor(sheetLight)
+	d.Rect(sheetMargin, s.y, sheetWidth, 20, true, false)
+
+	d.SetFillColor(pdf.Black)
+	d.SetFont(pdf.Bold, 8.5)
+	x := sheetMargin
+	for _, c := range s.columns {
+		if c.Right {
+			d.TextRight(x+c.Width-6, s.y+13.5, c.Title)
+		} else {
+			d.Text(x+6, s.y+13.5, c.Title)
+		}
+		x += c.Width
+	}
+	s.y += 20
+}
+
+// cellLine - 1 dòng chữ trong ô; dòng phụ (phân loại, SKU) in nhỏ màu xám
+type cellLine struct {
+	text      string
+	secondary bool
+}
+
+// tableRow - 1 dòng bảng, trả về vị trí y phía trên của dòng.
+// cells[i][0] là nội dung chính (đậm nếu i == bold), các phần tử sau là dòng phụ.
+func (s *orderSheet) tableRow(cells [][]string, bold int) float64 {
+	d := s.doc
+
+	wrapped := make([][]cellLine, len(cells))
+	lines := 1
+	for i, cell := range cells {
+		for j, text := range cell {
+			secondary := j > 0
+			if secondary {
+				d.SetFont(pdf.Regular, 8)
+			} else {
+				d.SetFont(pdf.Regular, 9)
+			}
+			for _, line := range d.Wrap(text, s.columns[i].Width-12) {
+				wrapped[i] = append(wrapped[i], cellLine{text: line, secondary: secondary})
+			}
+		}
+		if len(wrapped[i]) > lines {
+			lines = len(wrapped[i])
+		}
+	}
+
+	height := float64(lines)*12 + 10
+	s.ensure(height)
+	top := s.y
+
+	x := sheetMargin
+	for i, c := range s.columns {
+		y := top + 15
+		for j, line := range wrapped[i] {
+			switch {
+			case line.secondary:
+				d.SetFillColor(sheetMuted)
+				d.SetFont(pdf.Regular, 8)
+			case j == 0 && i == bold:
+				d.SetFillColor(pdf.Black)
+				d.SetFont(pdf.Bold, 9)
+			default:
+				d.SetFillColor(pdf.Black)
+				d.SetFont(pdf.Regular, 9)
+			}
+			if c.Right {
+				d.TextRight(x+c.Width-6, y, line.text)
+			} else {
+				d.Text(x+6, y, line.text)
+			}
+			y += 12
+		}
+		x += c.Width
+	}
+	d.SetFillColor(pdf.Black)
+
+	s.y += height
+	d.SetStrokeColor(sheetLine)
+	d.Line(sheetMargin, s.y, sheetRight, s.y)
+	return top
+}
+
+func (s *orderSheet) endTable() {
+	s.columns = nil
+	s.y += 16
+}
+
+// summaryLine - Dòng "nhãn ... số tiền" căn phải dưới bảng
+func (s *orderSheet) summaryLine(label, value string, bold bool) {
+	s.ensure(18)
+	d := s.doc
+	if bold {
+		d.SetFont(pdf.Bold, 11)
+	} else {
+		d.SetFont(pdf.Regular, 9)
+	}
+	d.TextRight(sheetRight-130, s.y, label)
+	d.TextRight(sheetRight-6, s.y, value)
+	s.y += 16
+}
+
+// paragraph - Đoạn văn có tiêu đề (ghi chú, lời cảm ơn)
+func (s *orderSheet) paragraph(title, text string) {
+	if text == "" {
+		return
+	}
+	d := s.doc
+	s.ensure(30)
+	if title != "" {
+		d.SetFillColor(sheetMuted)
+		d.SetFont(pdf.Bold, 8)
+		d.Text(sheetMargin, s.y, title)
+		s.y += 13
+	}
+
+	d.SetFillColor(pdf.Black)
+	d.SetFont(pdf.Regular, 9)
+	for _, line := range d.Wrap(text, sheetWidth) {
+		s.ensure(13)
+		d.Text(sheetMargin, s.y, line)
+		s.y += 13
+	}
+	s.y += 8
+}
+
+// recipient - Tên, SĐT, địa chỉ nhận hàng (ưu tiên địa chỉ có cấu trúc)
+func recipient(order Order) (name, phone, address string) {
+	name, phone, address = order.CustomerName, order.CustomerPhone, order.Address
+	if a := order.ShippingAddress; a != nil {
+		if a.RecipientName != "" {
+			name = a.RecipientName
+		}
+		if a.Phone != "" {
+			phone = a.Phone
+		}
+		if full := a.FullAddress(); full != "" {
+			address = full
+		}
+	}
+	return name, phone, address
+}
+
+// renderInvoice - Hóa đơn bán hàng: khách hàng, địa chỉ, sản phẩm, tiền hàng, giảm giá, phí ship, tổng
+func renderInvoice(order Order) ([]byte, error) {
+	s, err := newOrderSheet("HÓA ĐƠN BÁN HÀNG", order)
+	if err != nil {
+		return nil, err
+	}
+
+	name, phone, address := recipient(order)
+	payment := joinNonEmpty(" · ", order.PaymentMethod, labelOr(paymentStatusLabels, order.PaymentStatus))
+	if order.PaidAt != nil {
+		payment += " (" + order.PaidAt.In(orderNumberZone).Format("02/01/2006 15:04") + ")"
+	}
+	s.infoBoxes(
+		"KHÁCH HÀNG", []string{order.CustomerName, order.CustomerPhone, order.CustomerEmail, "Thanh toán: " + payment},
+		"GIAO ĐẾN", []string{name, phone, address, prefixed("Vận chuyển: ", labelOr(shippingMethodLabels, order.ShippingMethod))},
+	)
+
+	s.startTable([]sheetColumn{
+		{Title: "#", Width: 28},
+		{Title: "Sản phẩm"},
+		{Title: "SL", Width: 40, Right: true},
+		{Title: "Đơn giá", Width: 90, Right: true},
+		{Title: "Thành tiền", Width: 100, Right: true},
+	})
+	subtotal := 0.0
+	for i, item := range order.Items {
+		lineTotal := item.Price * float64(item.Qty)
+		subtotal += lineTotal
+		s.tableRow([][]string{
+			{fmt.Sprint(i + 1)},
+			nonEmpty(item.Name, itemVariant(item), prefixed("SKU: ", item.SKU)),
+			{fmt.Sprint(item.Qty)},
+			{notify.FormatVND(item.Price)},
+			{notify.FormatVND(lineTotal)},
+		}, 1)
+	}
+	s.endTable()
+
+	discount, couponDiscount, couponCode := 0.0, 0.0, ""
+	if p := order.Pricing; p != nil {
+		subtotal = p.Subtotal
+		discount, couponDiscount, couponCode = p.Discount, p.CouponDiscount, p.CouponCode
+	}
+	if couponCode == "" && order.Coupon != nil {
+		couponCode = order.Coupon.Code
+	}
+
+	s.summaryLine("Tạm tính", notify.FormatVND(subtotal), false)
+	if discount > 0 {
+		s.summaryLine("Giảm giá sản phẩm", notify.FormatVND(-discount), false)
+	}
+	if couponDiscount > 0 {
+		s.summaryLine(joinNonEmpty(" ", "Mã giảm giá", couponCode), notify.FormatVND(-couponDiscount), false)
+	}
+	s.summaryLine("Phí vận chuyển", notify.FormatVND(order.ShippingFee), false)
+	s.summaryLine("Tổng cộng", notify.FormatVND(order.Total), true)
+	if order.RefundedAmount > 0 {
+		s.summaryLine("Đã hoàn tiền", notify.FormatVND(-order.RefundedAmount), false)
+	}
+	s.y += 12
+
+	s.paragraph("GHI CHÚ", order.Note)
+	s.paragraph("", "Cảm ơn quý khách đã mua sắm tại "+s.shop.Name+"!")
+
+	return s.doc.Bytes()
+}
+
+// renderPackingSlip - Phiếu đóng gói cho kho: người nhận, vận chuyển, sản phẩm + phân loại + SL (không in giá)
+func renderPackingSlip(order Order) ([]byte, error) {
+	s, err := newOrderSheet("PHIẾU ĐÓNG GÓI", order)
+	if err != nil {
+		return nil, err
+	}
+
+	name, phone, address := recipient(order)
+	shipping := []string{labelOr(shippingMethodLabels, order.ShippingMethod)}
+	if sh := order.Shipment; sh != nil {
+		shipping = append(shipping, joinNonEmpty(" · ", sh.Carrier, prefixed("Mã vận đơn: ", sh.TrackingCode)))
+	}
+	if order.PaymentStatus != PaymentPaid {
+		shipping = append(shipping, "Thu hộ (COD): "+notify.FormatVND(order.Total))
+	} else {
+		shipping = append(shipping, "Đã thanh toán - không thu tiền")
+	}
+	if shipping[0] == "" {
+		shipping[0] = "Giao hàng"
+	}
+	s.infoBoxes("NGƯỜI NHẬN", []string{name, phone, address}, "VẬN CHUYỂN", shipping)
+
+	s.startTable([]sheetColumn{
+		{Title: "", Width: 26},
+		{Title: "#", Width: 28},
+		{Title: "Sản phẩm"},
+		{Title: "Phân loại", Width: 130},
+		{Title: "SKU", Width: 90},
+		{Title: "SL", Width: 40, Right: true},
+	})
+	totalQty := 0
+	for i, item := range order.Items {
+		totalQty += item.Qty
+		top := s.tableRow([][]string{
+			{""},
+			{fmt.Sprint(i + 1)},
+			{item.Name},
+			{itemVariant(item)},
+			{item.SKU},
+			{fmt.Sprint(item.Qty)},
+		}, 2)
+
+		// Ô đánh dấu đã lấy hàng
+		s.doc.SetStrokeColor(sheetMuted)
+		s.doc.Rect(sheetMargin+8, top+6, 10, 10, false, true)
+	}
+	s.endTable()
+
+	s.summaryLine("Tổng số lượng", fmt.Sprint(totalQty), true)
+	s.y += 12
+
+	s.paragraph("GHI CHÚ CỦA KHÁCH", order.Note)
+
+	// Chữ ký
+	s.ensure(70)
+	d := s.doc
+	half := sheetWidth / 2
+	d.SetFont(pdf.Bold, 9)
+	d.TextCenter(sheetMargin+half/2, s.y+10, "Người đóng gói")
+	d.TextCenter(sheetMargin+half+half/2, s.y+10, "Người kiểm tra")
+	d.SetFillColor(sheetMuted)
+	d.SetFont(pdf.Regular, 8)
+	d.TextCenter(sheetMargin+half/2, s.y+22, "(Ký, ghi rõ họ tên)")
+	d.TextCenter(sheetMargin+half+half/2, s.y+22, "(Ký, ghi rõ họ tên)")
+
+	return d.Bytes()
+}
+
+// writePDF - Trả file PDF mở trực tiếp trên trình duyệt (in từ trình xem PDF)
+func writePDF(w http.ResponseWriter, filename string, data []byte) {
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=%q", filename))
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Write(data)
+}
+
+// GetOrderInvoice - Hóa đơn PDF của 1 order (chủ đơn, khách vãng lai có order token, admin)
+func GetOrderInvoice(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "ID đơn hàng không hợp lệ",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var order Order
+	err = database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": objectID}).Decode(&order)
+
+	// Giống GetOrderByID: không phân biệt "không tồn tại" và "không có quyền"
+	if err != nil || !canAccessOrder(r, order) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không tìm thấy đơn hàng",
+		})
+		return
+	}
+
+	data, err := renderInvoice(order)
+	if err != nil {
+		log.Printf("❌ Render invoice %s failed: %v\n", orderLabel(order), err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tạo hóa đơn"})
+		return
+	}
+
+	writePDF(w, "invoice-"+orderLabel(order)+".pdf", data)
+}
+
+// GetPackingSlip - Admin in phiếu đóng gói PDF của 1 order
+func GetPackingSlip(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	order, ok := loadOrderParam(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	data, err := renderPackingSlip(order)
+	if err != nil {
+		log.Printf("❌ Render packing slip %s failed: %v\n", orderLabel(order), err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tạo phiếu đóng gói"})
+		return
+	}
+
+	writePDF(w, "packing-slip-"+orderLabel(order)+".pdf", data)
+}
+
+// joinNonEmpty - Nối các chuỗi khác rỗng
+func joinNonEmpty(sep string, parts ...string) string {
+	out := ""
+	for _, part := range parts {
+		if part == "" {
+			continue
+		}
+		if out != "" {
+			out += sep
+		}
+		out += part
+	}
+	return out
+}
+
+// prefixed - prefix + value, rỗng nếu value rỗng
+func prefixed(prefix, value string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + value
+}
+
+// nonEmpty - Bỏ các chuỗi rỗng
+func nonEmpty(values ...string) []string {
+	out := []string{}
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
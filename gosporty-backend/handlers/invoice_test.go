@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var pageCount = regexp.MustCompile(`/Count (\d+)`)
+
+func TestRenderOrderSheets(t *testing.T) {
+	order := func(items int) Order {
+		o := Order{
+			OrderNumber:   "GS-20261001-0001",
+			CustomerName:  "Nguyễn Văn A",
+			CustomerPhone: "0900000000",
+			CustomerEmail: "a@example.com",
+			Address:       "1 Lê Lợi, Phường Bến Nghé, Quận 1, TP.HCM",
+			PaymentMethod: "COD",
+			PaymentStatus: PaymentUnpaid,
+			Note:          "Giao giờ hành chính",
+			CreatedAt:     time.Date(2026, 10, 1, 9, 30, 0, 0, time.UTC),
+		}
+		for i := 0; i < items; i++ {
+			o.Items = append(o.Items, OrderItem{
+				Name:  fmt.Sprintf("Giày chạy bộ mẫu %d có tên rất dài để phải xuống dòng trong cột sản phẩm", i+1),
+				Qty:   1 + i%3,
+				Price: 1250000,
+			})
+			o.Total += 1250000 * float64(1+i%3)
+		}
+		return o
+	}
+
+	tests := []struct {
+		name   string
+		render func(Order) ([]byte, error)
+		items  int
+		pages  int
+	}{
+		{"invoice", renderInvoice, 3, 1},
+		{"invoice many items", renderInvoice, 60, 2},
+		{"packing slip", renderPackingSlip, 3, 1},
+		{"packing slip many items", renderPackingSlip, 60, 2},
+	}
+	for _, tt := range tests {
+		data, err := tt.render(order(tt.items))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+			t.Errorf("%s: not a complete PDF", tt.name)
+		}
+		m := pageCount.FindSubmatch(data)
+		if m == nil {
+			t.Fatalf("%s: missing /Count", tt.name)
+		}
+		if pages, _ := strconv.Atoi(string(m[1])); pages < tt.pages {
+			t.Errorf("%s: %d pages, want at least %d", tt.name, pages, tt.pages)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"gosporty-backend/locations"
+)
+
+// ShippingAddress - Địa chỉ giao hàng có cấu trúc (mã tỉnh/quận/phường theo danh mục hành chính)
+type ShippingAddress struct {
+	RecipientName string `json:"recipientName" bson:"recipientName"`
+	Phone         string `json:"phone" bson:"phone"`
+	Street        string `json:"street" bson:"street"` // Số nhà, tên đường
+	WardCode      string `json:"wardCode" bson:"wardCode"`
+	WardName      string `json:"wardName" bson:"wardName"`
+	DistrictCode  string `json:"districtCode" bson:"districtCode"`
+	DistrictName  string `json:"districtName" bson:"districtName"`
+	ProvinceCode  string `json:"provinceCode" bson:"provinceCode"`
+	ProvinceName  string `json:"provinceName" bson:"provinceName"`
+}
+
+// FullAddress - Địa chỉ 1 dòng để hiển thị / in phiếu giao hàng
+func (a ShippingAddress) FullAddress() string {
+	parts := []string{}
+	for _, part := range []string{a.Street, a.WardName, a.DistrictName, a.ProvinceName} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// normalizeShippingAddress - Chuẩn hóa, kiểm tra mã hành chính và điền tên từ bộ dữ liệu
+func normalizeShippingAddress(a *ShippingAddress) error {
+	a.RecipientName = strings.TrimSpace(a.RecipientName)
+	a.Phone = strings.TrimSpace(a.Phone)
+	a.Street = strings.TrimSpace(a.Street)
+	a.WardCode = strings.TrimSpace(a.WardCode)
+	a.DistrictCode = strings.TrimSpace(a.DistrictCode)
+	a.ProvinceCode = strings.TrimSpace(a.ProvinceCode)
+
+	if a.RecipientName == "" || a.Phone == "" || a.Street == "" {
+		return errors.New("Vui lòng nhập đầy đủ tên người nhận, số điện thoại và địa chỉ")
+	}
+	if !phonePattern.MatchString(a.Phone) {
+		return errors.New("Số điện thoại không hợp lệ")
+	}
+
+	resolved, err := locations.Resolve(a.ProvinceCode, a.DistrictCode, a.WardCode)
+	switch err {
+	case nil:
+	case locations.ErrUnknownProvince:
+		return errors.New("Tỉnh/thành phố không hợp lệ")
+	case locations.ErrUnknownDistrict:
+		return errors.New("Quận/huyện không hợp lệ")
+	case locations.ErrUnknownWard:
+		return errors.New("Phường/xã không hợp lệ")
+	default:
+		return err
+	}
+
+	// Tên lấy từ bộ dữ liệu; cấp nào bộ dữ liệu chưa có thì giữ tên client gửi lên
+	a.ProvinceName = resolved.ProvinceName
+	if resolved.DistrictName != "" {
+		a.DistrictName = resolved.DistrictName
+	}
+	if resolved.WardName != "" {
+		a.WardName = resolved.WardName
+	}
+	a.DistrictName = strings.TrimSpace(a.DistrictName)
+	a.WardName = strings.TrimSpace(a.WardName)
+	if a.DistrictName == "" || a.WardName == "" {
+		return errors.New("Vui lòng chọn đầy đủ quận/huyện và phường/xã")
+	}
+
+	return nil
+}
+
+// GetProvinces - Danh sách tỉnh/thành
+func GetProvinces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+
+	json.NewEncoder(w).Encode(locations.Provinces())
+}
+
+// GetDistricts - Danh sách quận/huyện của 1 tỉnh
+func GetDistricts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	districts, err := locations.Districts(mux.Vars(r)["provinceCode"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy tỉnh/thành phố"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	json.NewEncoder(w).Encode(districts)
+}
+
+// GetWards - Danh sách phường/xã của 1 quận/huyện
+func GetWards(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	wards, err := locations.Wards(mux.Vars(r)["districtCode"])
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy quận/huyện"})
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	json.NewEncoder(w).Encode(wards)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+)
+
+// loginLimit - Ngưỡng khóa cho 1 loại khóa (theo tài khoản hoặc theo IP)
+type loginLimit struct {
+	MaxFailures int           // Số lần sai trước khi bắt đầu khóa
+	BaseLockout time.Duration // Thời gian khóa lần đầu, nhân đôi mỗi lần sai tiếp theo
+	MaxLockout  time.Duration
+}
+
+var (
+	accountLoginLimit = loginLimit{MaxFailures: 5, BaseLockout: 1 * time.Minute, MaxLockout: 1 * time.Hour}
+	ipLoginLimit      = loginLimit{MaxFailures: 20, BaseLockout: 1 * time.Minute, MaxLockout: 1 * time.Hour}
+
+	// loginAttemptTTL - Bộ đếm tự reset sau 24 giờ không có lần sai nào
+	loginAttemptTTL = 24 * time.Hour
+)
+
+// LoginAttempt - Bộ đếm đăng nhập sai, lưu trong MongoDB để dùng chung giữa các instance
+type LoginAttempt struct {
+	Key           string     `bson:"_id"` // "email:<email>" hoặc "ip:<ip>"
+	Failures      int        `bson:"failures"`
+	LockedUntil   *time.Time `bson:"lockedUntil,omitempty"`
+	LastFailureAt time.Time  `bson:"lastFailureAt"`
+	UpdatedAt     time.Time  `bson:"updatedAt"`
+}
+
+// InitLoginAttemptCollection - TTL index để bộ đếm tự hết hạn
+func InitLoginAttemptCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("login_attempts").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "updatedAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(loginAttemptTTL.Seconds())),
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create login attempt index:", err)
+	} else {
+		log.Println("✅ Login attempt collection initialized with index")
+	}
+}
+
+func accountAttemptKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// maskEmail - Che bớt email khi ghi log
+func maskEmail(email string) string {
+	at := strings.Index(email, "@")
+	if at <= 1 {
+		return "***"
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// lockoutFor - Thời gian khóa theo số lần sai (exponential backoff)
+func (l loginLimit) lockoutFor(failures int) time.Duration {
+	if failures < l.MaxFailures {
+		return 0
+	}
+	exp := failures - l.MaxFailures
+	if exp > 16 {
+		exp = 16
+	}
+	d := time.Duration(float64(l.BaseLockout) * math.Pow(2, float64(exp)))
+	if d > l.MaxLockout {
+		d = l.MaxLockout
+	}
+	return d
+}
+
+// lockedUntil - Trả về thời điểm hết khóa nếu key đang bị khóa
+func lockedUntil(ctx context.Context, key string) (time.Time, bool) {
+	var attempt LoginAttempt
+	err := database.DB.Collection("login_attempts").FindOne(ctx, bson.M{"_id": key}).Decode(&attempt)
+	if err != nil || attempt.LockedUntil == nil {
+		return time.Time{}, false
+	}
+	if time.Now().Before(*attempt.LockedUntil) {
+		return *attempt.LockedUntil, true
+	}
+	return time.Time{}, false
+}
+
+// recordLoginFailure - Tăng bộ đếm (nguyên tử) và đặt thời gian khóa nếu vượt ngưỡng
+func recordLoginFailure(ctx context.Context, key string, limit loginLimit) {
+	coll := database.DB.Collection("login_attempts")
+	now := time.Now()
+
+	var attempt LoginAttempt
+	err := coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{
+			"$inc": bson.M{"failures": 1},
+			"$set": bson.M{"lastFailureAt": now, "updatedAt": now},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempt)
+	if err != nil {
+		log.Println("❌ Failed to record login failure:", err)
+		return
+	}
+
+	if lockout := limit.lockoutFor(attempt.Failures); lockout > 0 {
+		until := now.Add(lockout)
+		_, err = coll.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"lockedUntil": until}})
+		if err != nil {
+			log.Println("❌ Failed to lock login key:", err)
+			return
+		}
+		log.Printf("🔒 Login locked for %s until %s (%d failures)\n", strings.SplitN(key, ":", 2)[0], until.Format(time.RFC3339), attempt.Failures)
+	}
+}
+
+// clearLoginFailures - Xóa bộ đếm sau khi đăng nhập thành công hoặc admin mở khóa
+func clearLoginFailures(ctx context.Context, key string) error {
+	_, err := database.DB.Collection("login_attempts").DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+// checkLoginLocked - Kiểm tra khóa theo tài khoản và theo IP, trả 429 nếu đang bị khóa
+func checkLoginLocked(ctx context.Context, w http.ResponseWriter, email, ip string) bool {
+	until, locked := lockedUntil(ctx, accountAttemptKey(email))
+	if ipUntil, ipLocked := lockedUntil(ctx, ipAttemptKey(ip)); ipLocked {
+		if !locked || ipUntil.After(until) {
+			until = ipUntil
+		}
+		locked = true
+	}
+	if !locked {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(time.Until(until).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":      "Đăng nhập sai quá nhiều lần, vui lòng thử lại sau",
+		"code":       "LOGIN_LOCKED",
+		"retryAfter": retryAfter,
+	})
+	return true
+}
+
+// UnlockUserLogin - Admin mở khóa đăng nhập cho 1 tài khoản
+func UnlockUserLogin(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid user ID"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var user User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": objectID}).Decode(&user); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	if err := clearLoginFailures(ctx, accountAttemptKey(user.Email)); err != nil {
+		log.Println("❌ Failed to unlock user:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to unlock user"})
+		return
+	}
+
+	writeAudit(ctx, r, "user.unlock_login", "user", objectID.Hex(), nil)
+
+	log.Println("🔓 Login unlocked for user:", objectID.Hex())
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "User unlocked"})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"gosporty-backend/notify"
+)
+
+var notifications *notify.Queue
+
+// SetNotificationQueue - Hàng đợi email thông báo đơn hàng (nil = tắt thông báo)
+func SetNotificationQueue(q *notify.Queue) {
+	notifications = q
+}
+
+// requestLocale - Ngôn ngữ email: "locale" client gửi, không có thì theo Accept-Language
+func requestLocale(r *http.Request, requested string) string {
+	if requested != "" {
+		return notify.NormalizeLocale(requested)
+	}
+	return notify.NormalizeLocale(r.Header.Get("Accept-Language"))
+}
+
+// itemVariant - "Màu / Size" của dòng sản phẩm, bỏ trống nếu là phân loại mặc định
+func itemVariant(item OrderItem) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(variantLabel(item)), "("), ")")
+}
+
+// orderEmail - Dữ liệu template từ đơn hàng (link đơn kèm order token để khách vãng lai xem được)
+func orderEmail(order Order) notify.OrderEmail {
+	data := notify.OrderEmail{
+		CustomerName:  order.CustomerName,
+		OrderNumber:   orderLabel(order),
+		OrderURL:      clientURL() + "/order-success/" + order.ID.Hex() + "?token=" + orderAccessToken(order.ID),
+		Total:         order.Total,
+		ShippingFee:   order.ShippingFee,
+		PaymentMethod: order.PaymentMethod,
+		PaymentURL:    order.PaymentURL,
+		Address:       order.Address,
+		StatusKey:     order.Status.Key(),
+		CancelReason:  order.CancelReason,
+		CreatedAt:     order.CreatedAt,
+	}
+	if order.Pricing != nil {
+		data.Subtotal = order.Pricing.Subtotal
+		data.Discount = order.Pricing.Discount + order.Pricing.CouponDiscount
+	}
+	if order.Shipment != nil {
+		data.TrackingCode = order.Shipment.TrackingCode
+	}
+	for _, item := range order.Items {
+		data.Items = append(data.Items, notify.OrderEmailItem{
+			Name:      item.Name,
+			Variant:   itemVariant(item),
+			Qty:       item.Qty,
+			Price:     item.Price,
+			LineTotal: item.Price * float64(item.Qty),
+		})
+	}
+	return data
+}
+
+// notifyOrder - Đưa email sự kiện đơn hàng vào hàng đợi; lỗi chỉ ghi log, không làm hỏng request
+func notifyOrder(ctx context.Context, order Order, event string) {
+	if notifications == nil || order.CustomerEmail == "" {
+		return
+	}
+
+	// 1 email cho mỗi (đơn, sự kiện, trạng thái) - request/IPN/webhook lặp lại không gửi trùng
+	dedupeKey := event + ":" + order.ID.Hex()
+	if event == notify.EventOrderStatusChanged {
+		dedupeKey += ":" + order.Status.Key()
+	}
+
+	if err := notifications.Enqueue(ctx, order.CustomerEmail, event, order.Locale, dedupeKey, orderEmail(order)); err != nil {
+		log.Printf("⚠️ Could not queue %s email for order %s: %v\n", event, orderLabel(order), err)
+	}
+}
+
+// notifyAdmin - Email cảnh báo cho admin (ADMIN_EMAIL, nhiều địa chỉ cách nhau dấu phẩy)
+func notifyAdmin(ctx context.Context, order Order, event string) {
+	if notifications == nil {
+		return
+	}
+	data := orderEmail(order)
+	data.OrderURL = clientURL() + "/admin/orders"
+
+	for _, to := range strings.Split(os.Getenv("ADMIN_EMAIL"), ",") {
+		to = strings.TrimSpace(to)
+		if to == "" {
+			continue
+		}
+		dedupeKey := event + ":" + order.ID.Hex() + ":" + to
+		if err := notifications.Enqueue(ctx, to, event, notify.LocaleVI, dedupeKey, data); err != nil {
+			log.Printf("⚠️ Could not queue %s email for order %s: %v\n", event, orderLabel(order), err)
+		}
+	}
+}
@@ -10,9 +10,12 @@ import (
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"gosporty-backend/database"
+	"gosporty-backend/middlewares"
+	"gosporty-backend/notify"
 )
 
 // OrderItem - Item trong order
@@ -24,26 +27,60 @@ type OrderItem struct {
 	Image         string  `json:"image" bson:"image"`
 	SelectedColor string  `json:"selectedColor" bson:"selectedColor"`
 	SelectedSize  string  `json:"selectedSize" bson:"selectedSize"`
+	SKU           string  `json:"sku,omitempty" bson:"sku,omitempty"`
+	Weight        int     `json:"weight,omitempty" bson:"weight,omitempty"` // gram / sản phẩm
 }
 
 // Order - Đơn hàng
 // Order - Đơn hàng
 type Order struct {
-	ID            primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	UserID        string             `json:"userId,omitempty" bson:"userId,omitempty"`
-	CustomerName  string             `json:"customerName" bson:"customerName"`
-	CustomerEmail string             `json:"customerEmail" bson:"customerEmail"`
-	CustomerPhone string             `json:"customerPhone" bson:"customerPhone"`
-	Address       string             `json:"address" bson:"address"`
-	Note          string             `json:"note,omitempty" bson:"note,omitempty"`
-	Items         []OrderItem        `json:"items" bson:"items"`
-	Total         float64            `json:"total" bson:"total"`
-	Status        string             `json:"status" bson:"status"`
-	PaymentMethod string             `json:"paymentMethod" bson:"paymentMethod"`
-	CancelReason  string             `json:"cancelReason,omitempty" bson:"cancelReason,omitempty"` // ✅ Thêm
-	CancelledAt   *time.Time         `json:"cancelledAt,omitempty" bson:"cancelledAt,omitempty"`   // ✅ Thêm
-	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
+	ID              primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	OrderNumber     string             `json:"orderNumber,omitempty" bson:"orderNumber,omitempty"` // GS-YYYYMMDD-NNNNNN
+	UserID          string             `json:"userId,omitempty" bson:"userId,omitempty"`
+	CustomerName    string             `json:"customerName" bson:"customerName"`
+	CustomerEmail   string             `json:"customerEmail" bson:"customerEmail"`
+	CustomerPhone   string             `json:"customerPhone" bson:"customerPhone"`
+	Address         string             `json:"address" bson:"address"`
+	AddressID       string             `json:"addressId,omitempty" bson:"addressId,omitempty"` // Địa chỉ lấy từ sổ địa chỉ
+	ShippingAddress *ShippingAddress   `json:"shippingAddress,omitempty" bson:"shippingAddress,omitempty"`
+	Note            string             `json:"note,omitempty" bson:"note,omitempty"`
+	Items           []OrderItem        `json:"items" bson:"items"`
+	Total           float64            `json:"total" bson:"total"`
+	Pricing         *PriceBreakdown    `json:"pricing,omitempty" bson:"pricing,omitempty"`
+	ShippingMethod  string             `json:"shippingMethod,omitempty" bson:"shippingMethod,omitempty"` // standard | express
+	ShippingFee     float64            `json:"shippingFee" bson:"shippingFee"`
+	Shipment        *ShipmentInfo      `json:"shipment,omitempty" bson:"shipment,omitempty"`
+	TrackingEvents  []TrackingEvent    `json:"trackingEvents,omitempty" bson:"trackingEvents,omitempty"`
+	CouponCode      string             `json:"couponCode,omitempty" bson:"-"` // Mã khách nhập, server kiểm tra lại
+	Coupon          *AppliedCoupon     `json:"coupon,omitempty" bson:"coupon,omitempty"`
+	Status          OrderStatus        `json:"status" bson:"status"`
+	StatusHistory   []StatusChange     `json:"statusHistory,omitempty" bson:"statusHistory,omitempty"`
+	AccessToken     string             `json:"accessToken,omitempty" bson:"-"` // Chỉ trả về khi tạo đơn
+	PaymentMethod   string             `json:"paymentMethod" bson:"paymentMethod"`
+	Locale          string             `json:"locale,omitempty" bson:"locale,omitempty"` // Ngôn ngữ email thông báo: vi | en
+	PaymentStatus   string             `json:"paymentStatus" bson:"paymentStatus"`       // unpaid | paid | refunded | failed
+	Payment         *PaymentInfo       `json:"payment,omitempty" bson:"payment,omitempty"`
+	PaidAt          *time.Time         `json:"paidAt,omitempty" bson:"paidAt,omitempty"`
+	PaymentURL      string             `json:"paymentUrl,omitempty" bson:"-"`                            // Link thanh toán online, chỉ trả về khi tạo đơn
+	RefundedAmount  float64            `json:"refundedAmount,omitempty" bson:"refundedAmount,omitempty"` // Tổng tiền đã hoàn qua đổi trả
+	RefundRequired  bool               `json:"refundRequired,omitempty" bson:"refundRequired,omitempty"` // Thanh toán xong sau khi hủy, chờ admin hoàn tay
+	ReturnSeq       int                `json:"-" bson:"returnSeq,omitempty"`                             // Chống tạo đồng thời 2 yêu cầu đổi trả
+	CancelReason    string             `json:"cancelReason,omitempty" bson:"cancelReason,omitempty"`     // ✅ Thêm
+	CancelledAt     *time.Time         `json:"cancelledAt,omitempty" bson:"cancelledAt,omitempty"`       // ✅ Thêm
+	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// applyItemDefaults - Gán màu/size mặc định cho các dòng chưa chọn
+func applyItemDefaults(items []OrderItem) {
+	for i := range items {
+		if items[i].SelectedColor == "" {
+			items[i].SelectedColor = "Mặc định"
+		}
+		if items[i].SelectedSize == "" {
+			items[i].SelectedSize = "One Size"
+		}
+	}
 }
 
 // GetOrders - Lấy orders của user (tự động từ token)
@@ -53,18 +90,28 @@ func GetOrders(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	filter := bson.M{}
+	// ✅ Chỉ trả về đơn của user trong token - bỏ fallback ?userId= không cần đăng nhập
+	userID, ok := GetUserIDFromContext(r)
+	if !ok || userID == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Vui lòng đăng nhập để xem đơn hàng",
+		})
+		return
+	}
+
+	filter := bson.M{"userId": userID}
 
-	// ✅ Ưu tiên lấy userId từ context (từ JWT token qua middleware)
-	if userID, ok := r.Context().Value("userId").(string); ok && userID != "" {
-		filter["userId"] = userID
-		log.Println("✅ Filtering orders for userId from token:", userID)
-	} else if queryUserID := r.URL.Query().Get("userId"); queryUserID != "" {
-		// Fallback: lấy từ query params nếu không có token
+	// Admin có thể xem đơn của user khác qua ?userId=
+	if queryUserID := r.URL.Query().Get("userId"); queryUserID != "" && queryUserID != userID {
+		if !middlewares.IsAdmin(ctx, userID) {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Bạn không có quyền xem đơn hàng của người khác",
+			})
+			return
+		}
 		filter["userId"] = queryUserID
-		log.Println("⚠️ Filtering orders for userId from query:", queryUserID)
-	} else {
-		log.Println("⚠️ No userId found - returning empty array")
 	}
 
 	log.Println("📋 Final filter:", filter)
@@ -101,43 +148,6 @@ func GetOrders(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(orders)
 }
 
-// GetAllOrders - Lấy TẤT CẢ orders (dành cho admin) - KHÔNG filter theo userId
-func GetAllOrders(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	// KHÔNG filter gì cả - lấy tất cả orders cho admin
-	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
-
-	cursor, err := database.DB.Collection("orders").Find(ctx, bson.M{}, opts)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Không thể lấy danh sách đơn hàng",
-		})
-		return
-	}
-	defer cursor.Close(ctx)
-
-	var orders []Order
-	if err = cursor.All(ctx, &orders); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Không thể xử lý dữ liệu",
-		})
-		return
-	}
-
-	// Nếu không có orders, trả về array rỗng thay vì null
-	if orders == nil {
-		orders = []Order{}
-	}
-
-	json.NewEncoder(w).Encode(orders)
-}
-
 // GetOrderByID - Lấy 1 order theo ID
 func GetOrderByID(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -159,7 +169,9 @@ func GetOrderByID(w http.ResponseWriter, r *http.Request) {
 
 	var order Order
 	err = database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": objectID}).Decode(&order)
-	if err != nil {
+
+	// Không phân biệt "không tồn tại" và "không có quyền" để tránh dò ID
+	if err != nil || !canAccessOrder(r, order) {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Không tìm thấy đơn hàng",
@@ -183,6 +195,53 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Dùng địa chỉ đã lưu trong sổ địa chỉ (chỉ khi đã đăng nhập)
+	if order.AddressID != "" {
+		userID, ok := GetUserIDFromContext(r)
+		if !ok || userID == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Vui lòng đăng nhập để dùng địa chỉ đã lưu",
+			})
+			return
+		}
+
+		addrCtx, addrCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		saved, err := findUserAddress(addrCtx, userID, order.AddressID)
+		addrCancel()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Không tìm thấy địa chỉ giao hàng",
+			})
+			return
+		}
+
+		order.CustomerName = saved.RecipientName
+		order.CustomerPhone = saved.Phone
+		order.Address = saved.Address
+		order.ShippingAddress = saved.shippingAddress()
+		if order.CustomerEmail == "" {
+			order.CustomerEmail, _ = r.Context().Value("email").(string)
+		}
+	}
+
+	// Địa chỉ có cấu trúc: kiểm tra mã tỉnh/quận/phường và ghép lại địa chỉ 1 dòng
+	if order.ShippingAddress != nil {
+		if err := normalizeShippingAddress(order.ShippingAddress); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		order.Address = order.ShippingAddress.FullAddress()
+		if order.CustomerName == "" {
+			order.CustomerName = order.ShippingAddress.RecipientName
+		}
+		if order.CustomerPhone == "" {
+			order.CustomerPhone = order.ShippingAddress.Phone
+		}
+	}
+
 	// Validation
 	if order.CustomerName == "" || order.CustomerEmail == "" ||
 		order.CustomerPhone == "" || order.Address == "" {
@@ -201,42 +260,134 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if order.Total <= 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Tổng tiền không hợp lệ",
-		})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Set default cho items
+	applyItemDefaults(order.Items)
+
+	// Tính lại giá từ database - không tin giá/tổng tiền client gửi lên
+	pricedItems, breakdown, err := priceOrderItems(ctx, order.Items)
+	if err != nil {
+		writePricingError(w, err)
 		return
 	}
 
-	// Lấy userId từ context (nếu user đã login)
-	if userID, ok := r.Context().Value("userId").(string); ok && userID != "" {
-		order.UserID = userID
+	// Phí vận chuyển theo tỉnh nhận hàng và phương thức khách chọn
+	provinceCode := ""
+	if order.ShippingAddress != nil {
+		provinceCode = order.ShippingAddress.ProvinceCode
 	}
+	shipping, err := quoteShipping(ctx, pricedItems, breakdown.GrandTotal, provinceCode, order.ShippingMethod)
+	if err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	applyShipping(&breakdown, shipping)
+
+	// Mã giảm giá: lấy từ request, không có thì dùng mã đã áp dụng trong giỏ hàng
+	userID, _ := GetUserIDFromContext(r)
+	couponCode := order.CouponCode
+	if couponCode == "" {
+		if owner, ok := getCartOwner(r); ok {
+			var cart Cart
+			if err := cartCollection.FindOne(ctx, owner.filter()).Decode(&cart); err == nil {
+				couponCode = cart.CouponCode
+			}
+		}
+	}
+	var coupon Coupon
+	accountEmail, _ := r.Context().Value("email").(string)
+	customers := couponCustomers(userID, order.CustomerEmail, accountEmail)
+	if couponCode != "" {
+		var discount float64
+		coupon, discount, err = evaluateCoupon(ctx, couponCode, customers, pricedItems, breakdown.Subtotal-breakdown.Discount)
+		if err != nil {
+			writeCouponError(w, err)
+			return
+		}
+		breakdown.CouponCode = coupon.Code
+		breakdown.CouponDiscount = discount
+		breakdown.recalc()
+	}
+
+	if order.Total > 0 && !totalsMatch(order.Total, breakdown.GrandTotal) {
+		log.Printf("⚠️ Order total mismatch - client: %.0f, server: %.0f\n", order.Total, breakdown.GrandTotal)
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "Giá sản phẩm đã thay đổi, vui lòng kiểm tra lại đơn hàng",
+			"items":   pricedItems,
+			"pricing": breakdown,
+		})
+		return
+	}
+
+	order.Items = pricedItems
+	order.Total = breakdown.GrandTotal
+	order.Pricing = &breakdown
+	order.ShippingMethod = shipping.Method
+	order.ShippingFee = shipping.Fee
+
+	// userId chỉ lấy từ token đăng nhập - khách không được gắn đơn vào tài khoản khác
+	order.UserID = userID
 
 	// Set default values
-	order.Status = "Chờ xác nhận"
+	order.Status = StatusPending
+	order.StatusHistory = []StatusChange{newStatusChange("", StatusPending, actorFromRequest(r), "")}
 	if order.PaymentMethod == "" {
 		order.PaymentMethod = "COD"
 	}
+	order.PaymentStatus = PaymentUnpaid
+	order.Payment = nil
+	order.Locale = requestLocale(r, order.Locale)
+	order.PaidAt = nil
+	order.RefundedAmount = 0
+	order.RefundRequired = false
+	order.Shipment = nil
+	order.TrackingEvents = nil
+	order.CancelReason = ""
+	order.CancelledAt = nil
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
 
-	// Set default cho items
-	for i := range order.Items {
-		if order.Items[i].SelectedColor == "" {
-			order.Items[i].SelectedColor = "Mặc định"
+	order.ID = primitive.NewObjectID()
+	order.OrderNumber, err = nextOrderNumber(ctx, order.CreatedAt)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "Không thể tạo mã đơn hàng",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// Giữ hàng: trừ tồn kho trước khi lưu đơn
+	if err := reserveStock(ctx, order.Items); err != nil {
+		writeStockError(w, err)
+		return
+	}
+
+	// Ghi nhận lượt dùng mã giảm giá (nguyên tử, không vượt giới hạn khi đặt đồng thời).
+	// Coupon chỉ do server gán sau khi redeem - bỏ giá trị client gửi lên
+	order.Coupon = nil
+	if couponCode != "" {
+		if err := redeemCoupon(ctx, coupon, customers, order.ID); err != nil {
+			releaseStock(ctx, order.Items)
+			writeCouponError(w, err)
+			return
 		}
-		if order.Items[i].SelectedSize == "" {
-			order.Items[i].SelectedSize = "One Size"
+		order.Coupon = &AppliedCoupon{
+			CouponID:  coupon.ID,
+			Code:      coupon.Code,
+			Discount:  breakdown.CouponDiscount,
+			Customers: customers,
 		}
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	result, err := database.DB.Collection("orders").InsertOne(ctx, order)
+	_, err = database.DB.Collection("orders").InsertOne(ctx, order)
 	if err != nil {
+		releaseStock(ctx, order.Items)
+		releaseCoupon(ctx, order.Coupon, order.ID)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error":   "Không thể tạo đơn hàng",
@@ -245,7 +396,19 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order.ID = result.InsertedID.(primitive.ObjectID)
+	order.AccessToken = orderAccessToken(order.ID)
+
+	// Thanh toán online: tạo giao dịch ngay để frontend chuyển khách sang cổng thanh toán
+	if provider, ok := providerFor(order.PaymentMethod); ok {
+		paymentURL, err := startPayment(ctx, r, order, provider)
+		if err != nil {
+			log.Println("⚠️ Failed to start payment for order", order.ID.Hex(), err)
+		}
+		order.PaymentURL = paymentURL
+	}
+
+	notifyOrder(ctx, order, notify.EventOrderCreated)
+
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(order)
 }
@@ -268,6 +431,7 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 
 	var updateData struct {
 		Status string `json:"status"`
+		Note   string `json:"note"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -285,35 +449,31 @@ func UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	status, ok := ParseOrderStatus(updateData.Status)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Trạng thái không hợp lệ",
+		})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	update := bson.M{
-		"$set": bson.M{
-			"status":    updateData.Status,
-			"updatedAt": time.Now(),
-		},
+	extraSet := bson.M{}
+	if status == StatusCancelled {
+		extraSet["cancelReason"] = updateData.Note
+		extraSet["cancelledAt"] = time.Now()
 	}
 
-	result, err := database.DB.Collection("orders").UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	updatedOrder, err := transitionOrder(ctx, objectID, status, actorFromRequest(r), updateData.Note, nil, extraSet)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Không thể cập nhật đơn hàng",
-		})
-		return
-	}
-
-	if result.MatchedCount == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Không tìm thấy đơn hàng",
-		})
+		writeTransitionError(w, updatedOrder, status, err)
 		return
 	}
 
-	var updatedOrder Order
-	database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": objectID}).Decode(&updatedOrder)
+	log.Printf("✅ Order %s status -> %s\n", id, status)
 	json.NewEncoder(w).Encode(updatedOrder)
 }
 
@@ -336,7 +496,15 @@ func DeleteOrder(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	result, err := database.DB.Collection("orders").DeleteOne(ctx, bson.M{"_id": objectID})
+	var deleted Order
+	err = database.DB.Collection("orders").FindOneAndDelete(ctx, bson.M{"_id": objectID}).Decode(&deleted)
+	if err == mongo.ErrNoDocuments {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không tìm thấy đơn hàng",
+		})
+		return
+	}
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -345,12 +513,12 @@ func DeleteOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if result.DeletedCount == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Không tìm thấy đơn hàng",
-		})
-		return
+	// Đơn chưa giao vẫn đang giữ hàng và lượt dùng mã giảm giá -> trả lại như khi hủy.
+	// Đơn đã hủy thì đã hoàn khi hủy, đơn đã giao thì hàng đã xuất kho.
+	if deleted.Status.CanTransitionTo(StatusCancelled) {
+		releaseStock(ctx, deleted.Items)
+		releaseCoupon(ctx, deleted.Coupon, deleted.ID)
+		log.Printf("✅ Stock released for deleted order %s\n", id)
 	}
 
 	json.NewEncoder(w).Encode(map[string]string{
@@ -397,10 +565,11 @@ func CancelOrder(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Kiểm tra đơn hàng tồn tại
+	// Kiểm tra đơn hàng tồn tại và quyền (chủ đơn, admin hoặc khách có order token).
+	// Không phân biệt "không tồn tại" và "không có quyền" để tránh dò ID / trạng thái đơn
 	var existingOrder Order
 	err = database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": objectID}).Decode(&existingOrder)
-	if err != nil {
+	if err != nil || !canAccessOrder(r, existingOrder) {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Không tìm thấy đơn hàng",
@@ -408,8 +577,8 @@ func CancelOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Kiểm tra trạng thái hiện tại - chỉ cho phép hủy đơn "Chờ xác nhận"
-	if existingOrder.Status != "Chờ xác nhận" {
+	// Kiểm tra trạng thái hiện tại - khách chỉ được hủy đơn "Chờ xác nhận"
+	if existingOrder.Status != StatusPending {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Không thể hủy đơn hàng đã được xác nhận hoặc đang giao",
@@ -417,50 +586,21 @@ func CancelOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Kiểm tra quyền: user chỉ được hủy đơn của mình
-	if userID, ok := r.Context().Value("userId").(string); ok && userID != "" {
-		if existingOrder.UserID != userID {
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Bạn không có quyền hủy đơn hàng này",
-			})
-			return
-		}
-	}
-
-	// Cập nhật trạng thái và lý do hủy
+	// Cập nhật trạng thái và lý do hủy (hoàn kho được xử lý trong transitionOrder)
 	now := time.Now()
-	update := bson.M{
-		"$set": bson.M{
-			"status":       "Đã hủy",
-			"cancelReason": cancelData.CancelReason,
-			"cancelledAt":  now,
-			"updatedAt":    now,
-		},
+	extraSet := bson.M{
+		"cancelReason": cancelData.CancelReason,
+		"cancelledAt":  now,
 	}
 
-	result, err := database.DB.Collection("orders").UpdateOne(ctx, bson.M{"_id": objectID}, update)
+	// Chỉ hủy nếu đơn vẫn đang "Chờ xác nhận" để tránh hoàn kho 2 lần
+	cancelledOrder, err := transitionOrder(ctx, objectID, StatusCancelled, actorFromRequest(r), cancelData.CancelReason,
+		bson.M{"status": StatusPending}, extraSet)
 	if err != nil {
-		log.Println("❌ Error cancelling order:", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Không thể hủy đơn hàng",
-		})
+		writeTransitionError(w, cancelledOrder, StatusCancelled, err)
 		return
 	}
 
-	if result.MatchedCount == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Không tìm thấy đơn hàng",
-		})
-		return
-	}
-
-	// Lấy đơn hàng đã cập nhật
-	var cancelledOrder Order
-	database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": objectID}).Decode(&cancelledOrder)
-
 	log.Printf("✅ Order cancelled successfully - ID: %s, Reason: %s\n", id, cancelData.CancelReason)
 
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gosporty-backend/middlewares"
+)
+
+// OrderTokenHeader - Header chứa order access token (dành cho khách không đăng nhập)
+const OrderTokenHeader = "X-Order-Token"
+
+// orderAccessToken - Token ký HMAC cho 1 đơn hàng, trả về khi tạo đơn
+func orderAccessToken(orderID primitive.ObjectID) string {
+	mac := hmac.New(sha256.New, jwtSecret)
+	mac.Write([]byte("order-access:" + orderID.Hex()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validOrderAccessToken - So sánh token theo thời gian hằng số
+func validOrderAccessToken(orderID primitive.ObjectID, token string) bool {
+	if token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(token), []byte(orderAccessToken(orderID)))
+}
+
+// requestOrderToken - Lấy order token từ header hoặc query ?token=
+func requestOrderToken(r *http.Request) string {
+	if token := r.Header.Get(OrderTokenHeader); token != "" {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// canAccessOrder - Chủ đơn hàng, admin hoặc người có order token hợp lệ
+func canAccessOrder(r *http.Request, order Order) bool {
+	userID, _ := GetUserIDFromContext(r)
+	if userID != "" && order.UserID == userID {
+		return true
+	}
+
+	if validOrderAccessToken(order.ID, requestOrderToken(r)) {
+		return true
+	}
+
+	return userID != "" && middlewares.IsAdmin(r.Context(), userID)
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+)
+
+// expiredOrderReason - Lý do hủy ghi vào đơn khi hệ thống tự hủy
+const expiredOrderReason = "Hệ thống tự hủy: quá hạn thanh toán"
+
+// maxExpiredPerRun - Số đơn tối đa xử lý mỗi lượt, phần còn lại để lượt sau
+const maxExpiredPerRun = 100
+
+// OrderPaymentTimeout - Đơn thanh toán online chưa trả tiền sau khoảng này bị tự hủy (ORDER_PAYMENT_TIMEOUT, mặc định 30m)
+var OrderPaymentTimeout = durationFromEnv("ORDER_PAYMENT_TIMEOUT", 30*time.Minute)
+
+// OrderExpiryInterval - Chu kỳ quét đơn quá hạn (ORDER_EXPIRY_INTERVAL, mặc định 1m)
+var OrderExpiryInterval = durationFromEnv("ORDER_EXPIRY_INTERVAL", time.Minute)
+
+// onlinePaymentMethodFilter - Điều kiện paymentMethod thuộc các cổng online đã đăng ký (không phân biệt hoa thường)
+func onlinePaymentMethodFilter() (bson.M, bool) {
+	if len(paymentProviders) == 0 {
+		return nil, false
+	}
+	names := make([]string, 0, len(paymentProviders))
+	for name := range paymentProviders {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+	return bson.M{"$regex": "^\\s*(" + strings.Join(names, "|") + ")\\s*$", "$options": "i"}, true
+}
+
+// ExpireUnpaidOrders - Job hủy đơn thanh toán online còn "Chờ xác nhận" mà chưa trả tiền quá OrderPaymentTimeout.
+// Hủy qua transitionOrder nên tồn kho và mã giảm giá được hoàn lại như khi khách tự hủy.
+func ExpireUnpaidOrders(ctx context.Context) error {
+	methods, ok := onlinePaymentMethodFilter()
+	if !ok {
+		return nil
+	}
+
+	filter := bson.M{
+		"status":        StatusPending,
+		"paymentStatus": bson.M{"$in": []string{PaymentUnpaid, PaymentFailed}},
+		"paymentMethod": methods,
+		"createdAt":     bson.M{"$lt": time.Now().Add(-OrderPaymentTimeout)},
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "createdAt", Value: 1}}).
+		SetLimit(maxExpiredPerRun).
+		SetProjection(bson.M{"_id": 1, "orderNumber": 1})
+
+	cursor, err := database.DB.Collection("orders").Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	var expired []Order
+	if err := cursor.All(ctx, &expired); err != nil {
+		return err
+	}
+
+	cancelled := 0
+	for _, order := range expired {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Điều kiện paymentStatus: IPN báo đã trả tiền ngay trước lúc hủy thì bỏ qua đơn này
+		_, err := transitionOrder(ctx, order.ID, StatusCancelled, SystemActor, expiredOrderReason,
+			bson.M{"status": StatusPending, "paymentStatus": bson.M{"$ne": PaymentPaid}},
+			bson.M{"cancelReason": expiredOrderReason, "cancelledAt": time.Now()},
+		)
+		if err == ErrInvalidTransition {
+			continue
+		}
+		if err != nil {
+			log.Printf("❌ Could not auto-cancel order %s: %v\n", orderLabel(order), err)
+			continue
+		}
+		cancelled++
+		log.Printf("✅ Auto-cancelled unpaid order %s\n", orderLabel(order))
+	}
+
+	if cancelled > 0 {
+		log.Printf("✅ Auto-cancelled %d unpaid order(s)\n", cancelled)
+	}
+	return nil
+}
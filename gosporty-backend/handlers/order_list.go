@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+)
+
+const (
+	defaultOrderPageSize = 20
+	maxOrderPageSize     = 100
+	maxRecentOrders      = 50
+)
+
+// PagedOrders - 1 trang danh sách đơn hàng (cùng dạng với PagedProducts)
+type PagedOrders struct {
+	Total  int64   `json:"total"`
+	Page   int     `json:"page"`
+	Limit  int     `json:"limit"`
+	Pages  int     `json:"pages"`
+	Orders []Order `json:"orders"`
+}
+
+// filterError - Tham số lọc sai, trả về cho admin biết tham số nào
+type filterError struct {
+	param string
+}
+
+func (e *filterError) Error() string {
+	return "invalid filter: " + e.param
+}
+
+// parseFilterDate - "YYYY-MM-DD" (giờ Việt Nam) hoặc RFC3339. endOfDay = lấy hết ngày đó (cho "to")
+func parseFilterDate(value string, endOfDay bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, orderNumberZone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, true
+}
+
+// splitParam - "a, b,,c" -> [a b c]
+func splitParam(value string) []string {
+	parts := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// orderListFilter - Điều kiện lọc đơn hàng từ query string (dùng chung cho danh sách và export):
+//
+//	status=pending,confirmed     trạng thái (mã tiếng Anh hoặc tiếng Việt)
+//	paymentMethod=COD,mock       phương thức thanh toán (không phân biệt hoa thường)
+//	paymentStatus=paid           trạng thái thanh toán
+//	from=2026-10-01&to=...       ngày đặt (YYYY-MM-DD theo giờ VN, hoặc RFC3339)
+//	minTotal=100000&maxTotal=... tổng tiền
+//	search=...                   tên, email, SĐT khách hoặc mã đơn
+func orderListFilter(q url.Values) (bson.M, error) {
+	filter := bson.M{}
+
+	if values := splitParam(q.Get("status")); len(values) > 0 {
+		statuses := []OrderStatus{}
+		for _, value := range values {
+			status, ok := ParseOrderStatus(value)
+			if !ok {
+				return nil, &filterError{param: "status"}
+			}
+			statuses = append(statuses, statusValues(status)...)
+		}
+		filter["status"] = bson.M{"$in": statuses}
+	}
+
+	if values := splitParam(q.Get("paymentMethod")); len(values) > 0 {
+		names := make([]string, len(values))
+		for i, value := range values {
+			names[i] = regexp.QuoteMeta(value)
+		}
+		filter["paymentMethod"] = bson.M{"$regex": "^\\s*(" + strings.Join(names, "|") + ")\\s*$", "$options": "i"}
+	}
+
+	if values := splitParam(q.Get("paymentStatus")); len(values) > 0 {
+		for _, value := range values {
+			if _, ok := paymentStatusLabels[value]; !ok {
+				return nil, &filterError{param: "paymentStatus"}
+			}
+		}
+		filter["paymentStatus"] = bson.M{"$in": values}
+	}
+
+	createdAt := bson.M{}
+	if value := strings.TrimSpace(q.Get("from")); value != "" {
+		from, ok := parseFilterDate(value, false)
+		if !ok {
+			return nil, &filterError{param: "from"}
+		}
+		createdAt["$gte"] = from
+	}
+	if value := strings.TrimSpace(q.Get("to")); value != "" {
+		to, ok := parseFilterDate(value, true)
+		if !ok {
+			return nil, &filterError{param: "to"}
+		}
+		createdAt["$lte"] = to
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+
+	total := bson.M{}
+	for param, op := range map[string]string{"minTotal": "$gte", "maxTotal": "$lte"} {
+		value := strings.TrimSpace(q.Get(param))
+		if value == "" {
+			continue
+		}
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil || amount < 0 {
+			return nil, &filterError{param: param}
+		}
+		total[op] = amount
+	}
+	if len(total) > 0 {
+		filter["total"] = total
+	}
+
+	if search := strings.TrimSpace(q.Get("search")); search != "" {
+		pattern := bson.M{"$regex": regexp.QuoteMeta(search), "$options": "i"}
+		or := bson.A{
+			bson.M{"customerName": pattern},
+			bson.M{"customerEmail": pattern},
+			bson.M{"customerPhone": pattern},
+			bson.M{"orderNumber": pattern},
+		}
+		// Đơn cũ chưa có mã đơn: tìm theo ID
+		if id, err := primitive.ObjectIDFromHex(search); err == nil {
+			or = append(or, bson.M{"_id": id})
+		}
+		filter["$or"] = or
+	}
+
+	return filter, nil
+}
+
+// orderListSort - sort=newest (mặc định) | oldest | total_desc | total_asc
+func orderListSort(q url.Values) bson.D {
+	switch strings.TrimSpace(q.Get("sort")) {
+	case "oldest":
+		return bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}
+	case "total_desc":
+		return bson.D{{Key: "total", Value: -1}, {Key: "_id", Value: -1}}
+	case "total_asc":
+		return bson.D{{Key: "total", Value: 1}, {Key: "_id", Value: 1}}
+	}
+	return bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}
+}
+
+// writeFilterError - 400 cho tham số lọc sai
+func writeFilterError(w http.ResponseWriter, err error) {
+	param := ""
+	var fe *filterError
+	if errors.As(err, &fe) {
+		param = fe.param
+	}
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Tham số lọc không hợp lệ: " + param,
+		"code":  "INVALID_FILTER",
+	})
+}
+
+// GetAllOrders - Admin xem danh sách đơn hàng có phân trang, lọc, tìm kiếm và sắp xếp
+// (page, limit, sort + các tham số của orderListFilter)
+func GetAllOrders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = defaultOrderPageSize
+	}
+	if limit > maxOrderPageSize {
+		limit = maxOrderPageSize
+	}
+
+	filter, err := orderListFilter(q)
+	if err != nil {
+		writeFilterError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	coll := database.DB.Collection("orders")
+	total, err := coll.CountDocuments(ctx, filter)
+	if err != nil {
+		log.Println("❌ Error counting orders:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không thể lấy danh sách đơn hàng",
+		})
+		return
+	}
+
+	opts := options.Find().
+		SetSort(orderListSort(q)).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+
+	cursor, err := coll.Find(ctx, filter, opts)
+	if err != nil {
+		log.Println("❌ Error fetching orders:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không thể lấy danh sách đơn hàng",
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	orders := []Order{}
+	if err = cursor.All(ctx, &orders); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không thể xử lý dữ liệu",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(PagedOrders{
+		Total:  total,
+		Page:   page,
+		Limit:  limit,
+		Pages:  int((total + int64(limit) - 1) / int64(limit)),
+		Orders: orders,
+	})
+}
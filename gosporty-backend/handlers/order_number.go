@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+)
+
+// orderNumberPrefix - Tiền tố mã đơn hàng, VD: GS-20261017-000123
+const orderNumberPrefix = "GS"
+
+// orderNumberZone - Ngày trong mã đơn tính theo giờ Việt Nam (UTC+7)
+var orderNumberZone = time.FixedZone("ICT", 7*60*60)
+
+var orderNumberPattern = regexp.MustCompile(`^GS-\d{8}-\d{6,}$`)
+
+// orderCounter - Bộ đếm đơn hàng theo ngày trong collection counters
+type orderCounter struct {
+	ID  string `bson:"_id"` // order:YYYYMMDD
+	Seq int64  `bson:"seq"`
+}
+
+// InitOrderCollection - Unique index cho mã đơn hàng và index cho danh sách đơn của admin
+func InitOrderCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("orders").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// Đơn cũ chưa có mã -> chỉ ràng buộc unique với đơn đã có orderNumber
+			Keys: bson.D{{Key: "orderNumber", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"orderNumber": bson.M{"$type": "string"},
+			}),
+		},
+		// Danh sách đơn cho admin: mặc định mới nhất trước, hay lọc theo trạng thái
+		{Keys: bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+		// Đơn của 1 khách (GET /orders, thống kê khách hàng)
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create order indexes:", err)
+	} else {
+		log.Println("✅ Order collection initialized with indexes")
+	}
+}
+
+// nextOrderNumber - Cấp mã đơn tiếp theo trong ngày bằng $inc nguyên tử (không trùng khi đặt đồng thời)
+func nextOrderNumber(ctx context.Context, now time.Time) (string, error) {
+	day := now.In(orderNumberZone).Format("20060102")
+
+	var counter orderCounter
+	err := database.DB.Collection("counters").FindOneAndUpdate(ctx,
+		bson.M{"_id": "order:" + day},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if mongo.IsDuplicateKeyError(err) {
+		// 2 request cùng upsert bộ đếm mới trong ngày -> thử lại, lần này document đã tồn tại
+		err = database.DB.Collection("counters").FindOneAndUpdate(ctx,
+			bson.M{"_id": "order:" + day},
+			bson.M{"$inc": bson.M{"seq": 1}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&counter)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s-%06d", orderNumberPrefix, day, counter.Seq), nil
+}
+
+// GetOrderByNumber - Lấy 1 order theo mã đơn hàng (GS-YYYYMMDD-NNNNNN)
+func GetOrderByNumber(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	number := strings.ToUpper(strings.TrimSpace(mux.Vars(r)["number"]))
+	if !orderNumberPattern.MatchString(number) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Mã đơn hàng không hợp lệ",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var order Order
+	err := database.DB.Collection("orders").FindOne(ctx, bson.M{"orderNumber": number}).Decode(&order)
+
+	// Giống GetOrderByID: không phân biệt "không tồn tại" và "không có quyền"
+	if err != nil || !canAccessOrder(r, order) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không tìm thấy đơn hàng",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(order)
+}
+
+// orderLabel - Mã hiển thị của đơn: mã đơn hàng, đơn cũ chưa có mã thì dùng ID
+func orderLabel(order Order) string {
+	if order.OrderNumber != "" {
+		return order.OrderNumber
+	}
+	return order.ID.Hex()
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/carrier"
+	"gosporty-backend/database"
+	"gosporty-backend/notify"
+)
+
+// OrderStatus - Trạng thái đơn hàng (giá trị lưu trong DB giữ nguyên tiếng Việt)
+type OrderStatus string
+
+const (
+	StatusPending   OrderStatus = "Chờ xác nhận"
+	StatusConfirmed OrderStatus = "Đã xác nhận"
+	StatusShipping  OrderStatus = "Đang giao"
+	StatusDelivered OrderStatus = "Đã giao"
+	StatusCompleted OrderStatus = "Hoàn thành"
+	StatusCancelled OrderStatus = "Đã hủy"
+
+	// statusLegacyProcessing - Giá trị cũ, được coi như "Đã xác nhận"
+	statusLegacyProcessing OrderStatus = "Đang xử lý"
+)
+
+// orderStatuses - Thứ tự hiển thị các trạng thái
+var orderStatuses = []OrderStatus{
+	StatusPending,
+	StatusConfirmed,
+	StatusShipping,
+	StatusDelivered,
+	StatusCompleted,
+	StatusCancelled,
+}
+
+// statusKeys - Mã tiếng Anh cho từng trạng thái (dùng cho API/query)
+var statusKeys = map[OrderStatus]string{
+	StatusPending:   "pending",
+	StatusConfirmed: "confirmed",
+	StatusShipping:  "shipping",
+	StatusDelivered: "delivered",
+	StatusCompleted: "completed",
+	StatusCancelled: "cancelled",
+}
+
+// orderTransitions - Đồ thị chuyển trạng thái hợp lệ
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusConfirmed, StatusCancelled},
+	StatusConfirmed: {StatusShipping, StatusCancelled},
+	StatusShipping:  {StatusDelivered},
+	StatusDelivered: {StatusCompleted},
+	StatusCompleted: {},
+	StatusCancelled: {},
+}
+
+var (
+	ErrOrderNotFound     = errors.New("order not found")
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrShipmentNotCancellable - Hãng đã lấy hàng, không hủy được vận đơn nên đơn không bị hủy
+	ErrShipmentNotCancellable = errors.New("shipment can no longer be cancelled")
+)
+
+// StatusChange - 1 lần đổi trạng thái trong lịch sử đơn hàng
+type StatusChange struct {
+	From      OrderStatus `json:"from,omitempty" bson:"from,omitempty"`
+	To        OrderStatus `json:"to" bson:"to"`
+	ChangedBy string      `json:"changedBy" bson:"changedBy"` // userId, "guest" hoặc "system"
+	Role      string      `json:"role,omitempty" bson:"role,omitempty"`
+	Note      string      `json:"note,omitempty" bson:"note,omitempty"`
+	At        time.Time   `json:"at" bson:"at"`
+}
+
+// Actor - Người thực hiện thay đổi trạng thái
+type Actor struct {
+	ID   string
+	Role string
+}
+
+// SystemActor - Thay đổi do hệ thống tự thực hiện
+var SystemActor = Actor{ID: "system", Role: "system"}
+
+// actorFromRequest - Lấy người thực hiện từ JWT context
+func actorFromRequest(r *http.Request) Actor {
+	userID, _ := r.Context().Value("userId").(string)
+	if userID == "" {
+		return Actor{ID: "guest", Role: "guest"}
+	}
+
+	role := "user"
+	if roles, ok := r.Context().Value("roles").([]string); ok {
+		for _, ro := range roles {
+			if ro == "admin" {
+				role = "admin"
+			}
+		}
+	}
+	return Actor{ID: userID, Role: role}
+}
+
+// ParseOrderStatus - Nhận cả giá trị tiếng Việt lẫn mã tiếng Anh
+func ParseOrderStatus(value string) (OrderStatus, bool) {
+	status := OrderStatus(value)
+	if status == statusLegacyProcessing {
+		return StatusConfirmed, true
+	}
+	if _, ok := orderTransitions[status]; ok {
+		return status, true
+	}
+	for s, key := range statusKeys {
+		if key == value {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// Normalize - Quy đổi giá trị cũ về trạng thái chuẩn
+func (s OrderStatus) Normalize() OrderStatus {
+	if s == statusLegacyProcessing {
+		return StatusConfirmed
+	}
+	return s
+}
+
+// CanTransitionTo - Kiểm tra có được chuyển từ s sang next không
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderTransitions[s.Normalize()] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Key - Mã tiếng Anh của trạng thái
+func (s OrderStatus) Key() string {
+	return statusKeys[s.Normalize()]
+}
+
+// statusValues - Các giá trị lưu trong DB ứng với 1 trạng thái (gồm cả giá trị cũ)
+func statusValues(s OrderStatus) []OrderStatus {
+	if s == StatusConfirmed {
+		return []OrderStatus{StatusConfirmed, statusLegacyProcessing}
+	}
+	return []OrderStatus{s}
+}
+
+// predecessors - Các trạng thái (kể cả giá trị cũ) được phép chuyển sang next
+func predecessors(next OrderStatus) []OrderStatus {
+	from := []OrderStatus{}
+	for _, s := range orderStatuses {
+		if s.CanTransitionTo(next) {
+			from = append(from, statusValues(s)...)
+		}
+	}
+	return from
+}
+
+// newStatusChange - Tạo bản ghi lịch sử trạng thái
+func newStatusChange(from, to OrderStatus, actor Actor, note string) StatusChange {
+	return StatusChange{
+		From:      from,
+		To:        to,
+		ChangedBy: actor.ID,
+		Role:      actor.Role,
+		Note:      note,
+		At:        time.Now(),
+	}
+}
+
+// transitionOrder - Đổi trạng thái đơn hàng một cách nguyên tử theo đồ thị chuyển trạng thái.
+// extraFilter/extraSet cho phép caller thêm điều kiện/trường cập nhật (vd: lý do hủy).
+// Khi chuyển sang "Đã hủy" thì tồn kho được hoàn lại.
+// Trả về đơn hàng sau khi cập nhật.
+func transitionOrder(ctx context.Context, orderID primitive.ObjectID, to OrderStatus, actor Actor, note string, extraFilter, extraSet bson.M) (Order, error) {
+	coll := database.DB.Collection("orders")
+
+	var current Order
+	if err := coll.FindOne(ctx, bson.M{"_id": orderID}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return Order{}, ErrOrderNotFound
+		}
+		return Order{}, err
+	}
+
+	if !current.Status.CanTransitionTo(to) {
+		return current, ErrInvalidTransition
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"status":    to,
+		"updatedAt": now,
+	}
+	for k, v := range extraSet {
+		set[k] = v
+	}
+
+	// Điều kiện status giúp tránh 2 request đổi trạng thái cùng lúc
+	filter := bson.M{"_id": orderID, "status": bson.M{"$in": predecessors(to)}}
+	for k, v := range extraFilter {
+		filter[k] = v
+	}
+
+	update := bson.M{
+		"$set":  set,
+		"$push": bson.M{"statusHistory": newStatusChange(current.Status, to, actor, note)},
+	}
+
+	// Hủy vận đơn trước: hãng không cho hủy thì giữ nguyên đơn (chưa trả hàng về kho, chưa trả mã giảm giá)
+	if to == StatusCancelled && current.Shipment != nil {
+		// Chỉ gọi hãng khi đơn vẫn khớp điều kiện hủy
+		if n, err := coll.CountDocuments(ctx, filter); err != nil {
+			return current, err
+		} else if n == 0 {
+			return current, ErrInvalidTransition
+		}
+		if err := cancelCarrierShipment(ctx, current); err != nil {
+			if errors.Is(err, carrier.ErrNotCancellable) {
+				return current, ErrShipmentNotCancellable
+			}
+			return current, fmt.Errorf("cancel shipment: %w", err)
+		}
+	}
+
+	var updated Order
+	err := coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return current, ErrInvalidTransition
+	}
+	if err != nil {
+		return current, err
+	}
+
+	if to == StatusCancelled {
+		releaseStock(ctx, updated.Items)
+		releaseCoupon(ctx, updated.Coupon, updated.ID)
+		log.Printf("✅ Stock released for cancelled order %s\n", orderID.Hex())
+	}
+
+	event := notify.EventOrderStatusChanged
+	if to == StatusCancelled {
+		event = notify.EventOrderCancelled
+	}
+	notifyOrder(ctx, updated, event)
+
+	return updated, nil
+}
+
+// writeTransitionError - Trả lỗi khi đổi trạng thái thất bại
+func writeTransitionError(w http.ResponseWriter, current Order, to OrderStatus, err error) {
+	switch err {
+	case ErrOrderNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không tìm thấy đơn hàng",
+		})
+	case ErrInvalidTransition:
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   "Không thể chuyển trạng thái từ \"" + string(current.Status) + "\" sang \"" + string(to) + "\"",
+			"code":    "INVALID_STATUS_TRANSITION",
+			"allowed": orderTransitions[current.Status.Normalize()],
+		})
+	case ErrShipmentNotCancellable:
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Hãng vận chuyển đã lấy hàng, không thể hủy vận đơn. Vui lòng liên hệ hãng để hoàn hàng",
+			"code":  "SHIPMENT_NOT_CANCELLABLE",
+		})
+	default:
+		log.Println("❌ Order status transition error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không thể cập nhật đơn hàng",
+		})
+	}
+}
+
+// GetOrderStatuses - Danh sách trạng thái và các bước chuyển hợp lệ (cho admin SPA)
+func GetOrderStatuses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	type statusInfo struct {
+		Key         string        `json:"key"`
+		Label       OrderStatus   `json:"label"`
+		Transitions []OrderStatus `json:"transitions"`
+	}
+
+	result := make([]statusInfo, 0, len(orderStatuses))
+	for _, s := range orderStatuses {
+		result = append(result, statusInfo{
+			Key:         s.Key(),
+			Label:       s,
+			Transitions: orderTransitions[s],
+		})
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
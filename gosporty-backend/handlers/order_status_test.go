@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to OrderStatus
+		want     bool
+	}{
+		{StatusPending, StatusConfirmed, true},
+		{StatusPending, StatusCancelled, true},
+		{StatusPending, StatusShipping, false},
+		{StatusPending, StatusDelivered, false},
+		{StatusConfirmed, StatusShipping, true},
+		{StatusConfirmed, StatusCancelled, true},
+		{StatusConfirmed, StatusPending, false},
+		{statusLegacyProcessing, StatusShipping, true},
+		{statusLegacyProcessing, StatusCancelled, true},
+		{StatusShipping, StatusDelivered, true},
+		{StatusShipping, StatusCancelled, false},
+		{StatusDelivered, StatusCompleted, true},
+		{StatusDelivered, StatusCancelled, false},
+		{StatusCompleted, StatusCancelled, false},
+		{StatusCancelled, StatusPending, false},
+		{StatusCancelled, StatusCancelled, false},
+		{OrderStatus("unknown"), StatusConfirmed, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%q -> %q = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestParseOrderStatus(t *testing.T) {
+	tests := []struct {
+		value string
+		want  OrderStatus
+		ok    bool
+	}{
+		{"Chờ xác nhận", StatusPending, true},
+		{"pending", StatusPending, true},
+		{"confirmed", StatusConfirmed, true},
+		{"Đang xử lý", StatusConfirmed, true},
+		{"cancelled", StatusCancelled, true},
+		{"Hoàn thành", StatusCompleted, true},
+		{"", "", false},
+		{"shipped", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseOrderStatus(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseOrderStatus(%q) = %q, %v, want %q, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPredecessors(t *testing.T) {
+	tests := []struct {
+		next OrderStatus
+		want []OrderStatus
+	}{
+		{StatusPending, []OrderStatus{}},
+		{StatusConfirmed, []OrderStatus{StatusPending}},
+		{StatusShipping, []OrderStatus{StatusConfirmed, statusLegacyProcessing}},
+		{StatusCancelled, []OrderStatus{StatusPending, StatusConfirmed, statusLegacyProcessing}},
+		{StatusCompleted, []OrderStatus{StatusDelivered}},
+	}
+	for _, tt := range tests {
+		if got := predecessors(tt.next); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("predecessors(%q) = %v, want %v", tt.next, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+	"gosporty-backend/notify"
+	"gosporty-backend/payment"
+)
+
+// Trạng thái thanh toán - tách riêng khỏi trạng thái giao hàng (OrderStatus)
+const (
+	PaymentUnpaid   = "unpaid"
+	PaymentPaid     = "paid"
+	PaymentRefunded = "refunded"
+	PaymentFailed   = "failed"
+)
+
+// paymentTransitions - Admin cập nhật tay trạng thái thanh toán (COD, chuyển khoản, hoàn tiền)
+var paymentTransitions = map[string][]string{
+	PaymentUnpaid: {PaymentPaid, PaymentFailed},
+	PaymentFailed: {PaymentUnpaid, PaymentPaid},
+	PaymentPaid:   {PaymentRefunded},
+}
+
+var (
+	errPaymentOrderNotFound = errors.New("payment order not found")
+	errPaymentMismatch      = errors.New("payment amount or reference mismatch")
+	errPaymentAlreadyPaid   = errors.New("order already paid")
+)
+
+// PaymentInfo - Thông tin giao dịch online gần nhất của đơn hàng
+type PaymentInfo struct {
+	Provider       string    `json:"provider" bson:"provider"`
+	TransactionRef string    `json:"transactionRef" bson:"transactionRef"`
+	ProviderTxnID  string    `json:"providerTxnId,omitempty" bson:"providerTxnId,omitempty"`
+	Amount         int64     `json:"amount" bson:"amount"`
+	ResponseCode   string    `json:"responseCode,omitempty" bson:"responseCode,omitempty"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+var paymentProviders = map[string]payment.Provider{}
+
+// SetPaymentProviders - Đăng ký các cổng thanh toán online (key = tên provider, không phân biệt hoa thường)
+func SetPaymentProviders(providers []payment.Provider) {
+	paymentProviders = map[string]payment.Provider{}
+	for _, p := range providers {
+		paymentProviders[strings.ToLower(p.Name())] = p
+	}
+}
+
+// providerFor - Cổng thanh toán ứng với PaymentMethod của đơn (COD/Banking -> không có)
+func providerFor(method string) (payment.Provider, bool) {
+	p, ok := paymentProviders[strings.ToLower(strings.TrimSpace(method))]
+	return p, ok
+}
+
+// PaymentProviders - Danh sách cổng thanh toán online đang bật để trang checkout hiển thị
+func PaymentProviders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	names := make([]string, 0, len(paymentProviders))
+	for name := range paymentProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	json.NewEncoder(w).Encode(map[string]interface{}{"providers": names})
+}
+
+// orderAmount - Số tiền thanh toán (VND, số nguyên)
+func orderAmount(order Order) int64 {
+	return int64(math.Round(order.Total))
+}
+
+// startPayment - Tạo giao dịch mới trên cổng thanh toán và lưu mã giao dịch vào đơn
+func startPayment(ctx context.Context, r *http.Request, order Order, provider payment.Provider) (string, error) {
+	now := time.Now()
+	// Hậu tố ngẫu nhiên: 2 lần bấm thanh toán trong cùng 1 giây vẫn ra 2 mã giao dịch khác nhau
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	ref := fmt.Sprintf("%s-%d-%s", order.ID.Hex(), now.Unix(), hex.EncodeToString(suffix))
+
+	result, err := provider.CreatePayment(ctx, payment.Request{
+		OrderID:        order.ID.Hex(),
+		TransactionRef: ref,
+		Amount:         orderAmount(order),
+		Description:    "Thanh toan don hang " + orderLabel(order),
+		ReturnURL:      clientURL() + "/payment/result/" + provider.Name(),
+		ClientIP:       clientIP(r),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	info := PaymentInfo{
+		Provider:       provider.Name(),
+		TransactionRef: result.TransactionRef,
+		Amount:         orderAmount(order),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	_, err = database.DB.Collection("orders").UpdateOne(ctx,
+		bson.M{"_id": order.ID, "paymentStatus": bson.M{"$ne": PaymentPaid}},
+		bson.M{"$set": bson.M{"payment": info, "updatedAt": now}},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	return result.PaymentURL, nil
+}
+
+// applyPaymentCallback - Cập nhật trạng thái thanh toán từ callback đã kiểm tra chữ ký.
+// Gọi lại nhiều lần với cùng callback không đổi kết quả.
+func applyPaymentCallback(ctx context.Context, providerName string, cb payment.Callback) (Order, error) {
+	var order Order
+
+	objectID, err := primitive.ObjectIDFromHex(cb.OrderID)
+	if err != nil {
+		return order, errPaymentOrderNotFound
+	}
+
+	coll := database.DB.Collection("orders")
+	if err := coll.FindOne(ctx, bson.M{"_id": objectID}).Decode(&order); err != nil {
+		return order, errPaymentOrderNotFound
+	}
+
+	if order.Payment == nil || order.Payment.Provider != providerName ||
+		order.Payment.TransactionRef != cb.TransactionRef || cb.Amount != orderAmount(order) {
+		log.Printf("⚠️ Payment callback mismatch for order %s (ref %s, amount %d)\n", cb.OrderID, cb.TransactionRef, cb.Amount)
+		return order, errPaymentMismatch
+	}
+	if order.PaymentStatus == PaymentPaid {
+		return order, errPaymentAlreadyPaid
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"payment.providerTxnId": cb.ProviderTxnID,
+		"payment.responseCode":  cb.ResponseCode,
+		"payment.updatedAt":     now,
+		"updatedAt":             now,
+	}
+	if cb.Success {
+		set["paymentStatus"] = PaymentPaid
+		set["paidAt"] = now
+	} else {
+		set["paymentStatus"] = PaymentFailed
+	}
+
+	err = coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": objectID, "paymentStatus": bson.M{"$ne": PaymentPaid}, "payment.transactionRef": cb.TransactionRef},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		return order, errPaymentAlreadyPaid
+	}
+	if err != nil {
+		return order, err
+	}
+
+	if cb.Success {
+		log.Printf("✅ Order %s paid via %s (%s)\n", order.ID.Hex(), providerName, cb.ProviderTxnID)
+		if order.Status.Normalize() == StatusCancelled {
+			order = refundCancelledPayment(ctx, order)
+		}
+	} else {
+		log.Printf("⚠️ Payment failed for order %s: %s\n", order.ID.Hex(), cb.ResponseCode)
+	}
+	return order, nil
+}
+
+// refundCancelledPayment - Đơn đã hủy nhưng khách vẫn thanh toán xong (IPN đến sau khi hủy):
+// hoàn tiền qua cổng nếu hỗ trợ, ngược lại đánh dấu refundRequired và báo admin hoàn tay
+func refundCancelledPayment(ctx context.Context, order Order) Order {
+	coll := database.DB.Collection("orders")
+	amount := orderAmount(order)
+
+	if provider, ok := providerFor(order.Payment.Provider); ok {
+		if refunder, ok := provider.(payment.Refunder); ok {
+			result, err := refunder.Refund(ctx, payment.RefundRequest{
+				OrderID:        order.ID.Hex(),
+				TransactionRef: order.Payment.TransactionRef,
+				ProviderTxnID:  order.Payment.ProviderTxnID,
+				RefundRef:      "RF-" + order.Payment.TransactionRef,
+				Amount:         amount,
+				Reason:         "Đơn hàng đã bị hủy",
+			})
+			if err == nil {
+				now := time.Now()
+				err = coll.FindOneAndUpdate(ctx,
+					bson.M{"_id": order.ID, "paymentStatus": PaymentPaid},
+					bson.M{"$set": bson.M{
+						"paymentStatus":  PaymentRefunded,
+						"refundedAmount": float64(amount),
+						"updatedAt":      now,
+					}},
+					options.FindOneAndUpdate().SetReturnDocument(options.After),
+				).Decode(&order)
+				if err == nil {
+					log.Printf("✅ Refunded cancelled order %s via %s (%s)\n", order.ID.Hex(), provider.Name(), result.ProviderRefundID)
+					return order
+				}
+				// Cổng đã hoàn nhưng không ghi được vào đơn -> vẫn để admin kiểm tra
+				log.Printf("❌ Refunded cancelled order %s (%s) but could not update it: %v\n", order.ID.Hex(), result.ProviderRefundID, err)
+			} else {
+				log.Printf("❌ Auto refund failed for cancelled order %s: %v\n", order.ID.Hex(), err)
+			}
+		}
+	}
+
+	log.Printf("⚠️ Order %s was paid after being cancelled - needs manual refund\n", order.ID.Hex())
+	if _, err := coll.UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{"$set": bson.M{"refundRequired": true}}); err != nil {
+		log.Println("❌ Failed to flag refund for order", order.ID.Hex(), err)
+	}
+	order.RefundRequired = true
+	notifyAdmin(ctx, order, notify.EventRefundRequired)
+	return order
+}
+
+// PayOrder - Tạo link thanh toán online cho đơn hàng (thanh toán lại nếu lần trước thất bại)
+func PayOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ID đơn hàng không hợp lệ"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var order Order
+	if err := database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": objectID}).Decode(&order); err != nil || !canAccessOrder(r, order) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy đơn hàng"})
+		return
+	}
+
+	provider, ok := providerFor(order.PaymentMethod)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Đơn hàng không dùng thanh toán online"})
+		return
+	}
+	if order.PaymentStatus == PaymentPaid || order.PaymentStatus == PaymentRefunded {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Đơn hàng đã được thanh toán", "code": "ALREADY_PAID"})
+		return
+	}
+	if order.Status.Normalize() == StatusCancelled {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Đơn hàng đã bị hủy"})
+		return
+	}
+
+	paymentURL, err := startPayment(ctx, r, order, provider)
+	if err != nil {
+		log.Println("❌ PayOrder error:", err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tạo giao dịch thanh toán"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"paymentUrl": paymentURL})
+}
+
+// PaymentReturn - Frontend gửi lại query string cổng thanh toán redirect về để kiểm tra kết quả
+func PaymentReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	provider, ok := paymentProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown payment provider"})
+		return
+	}
+
+	cb, err := provider.VerifyReturn(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Chữ ký thanh toán không hợp lệ"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// IPN có thể đến sau redirect - cập nhật luôn ở đây (an toàn vì chữ ký đã được kiểm tra)
+	order, err := applyPaymentCallback(ctx, provider.Name(), cb)
+	if err != nil && err != errPaymentAlreadyPaid {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Giao dịch không khớp với đơn hàng"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"orderId":       cb.OrderID,
+		"success":       cb.Success,
+		"paymentStatus": order.PaymentStatus,
+		"message":       cb.Message,
+	})
+}
+
+// PaymentIPN - Cổng thanh toán gọi server-to-server báo kết quả (mã phản hồi kiểu VNPay)
+func PaymentIPN(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	respond := func(code, message string) {
+		json.NewEncoder(w).Encode(map[string]string{"RspCode": code, "Message": message})
+	}
+
+	provider, ok := paymentProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		respond("99", "Unknown provider")
+		return
+	}
+
+	cb, err := provider.HandleIPN(r)
+	if err == payment.ErrInvalidSignature {
+		respond("97", "Invalid signature")
+		return
+	}
+	if err != nil {
+		respond("99", "Invalid request")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = applyPaymentCallback(ctx, provider.Name(), cb)
+	switch err {
+	case nil:
+		respond("00", "Confirm Success")
+	case errPaymentOrderNotFound:
+		respond("01", "Order not found")
+	case errPaymentAlreadyPaid:
+		respond("02", "Order already confirmed")
+	case errPaymentMismatch:
+		respond("04", "Invalid amount")
+	default:
+		log.Println("❌ PaymentIPN error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		respond("99", "Unknown error")
+	}
+}
+
+var mockCheckoutPage = template.Must(template.New("mock").Parse(`<!DOCTYPE html>
+<html lang="vi"><head><meta charset="utf-8"><title>Mock Payment</title></head>
+<body style="font-family:sans-serif;max-width:420px;margin:40px auto">
+<h2>Cổng thanh toán giả lập</h2>
+<p>Đơn hàng: <b>{{.OrderID}}</b></p>
+<p>Số tiền: <b>{{.Amount}} ₫</b></p>
+<form method="POST" action="/api/payments/mock/complete">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+{{end}}<button name="result" value="success">Thanh toán</button>
+<button name="result" value="cancel">Hủy</button>
+</form>
+</body></html>`))
+
+// mockProvider - Mock provider đã đăng ký (nil nếu không bật)
+func mockProvider() *payment.MockProvider {
+	p, _ := paymentProviders["mock"].(*payment.MockProvider)
+	return p
+}
+
+// MockCheckout - Trang thanh toán giả của mock provider
+func MockCheckout(w http.ResponseWriter, r *http.Request) {
+	provider := mockProvider()
+	if provider == nil || !payment.Verify(provider.Secret, r.URL.Query()) {
+		http.Error(w, "Invalid payment link", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	mockCheckoutPage.Execute(w, map[string]interface{}{
+		"OrderID": r.URL.Query().Get("orderId"),
+		"Amount":  r.URL.Query().Get("amount"),
+		"Params":  r.URL.Query(),
+	})
+}
+
+// MockComplete - Khách bấm thanh toán/hủy trên trang giả: gửi IPN rồi redirect về frontend
+func MockComplete(w http.ResponseWriter, r *http.Request) {
+	provider := mockProvider()
+	if provider == nil || r.ParseForm() != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	checkout := url.Values{}
+	for k, v := range r.PostForm {
+		if k != "result" {
+			checkout[k] = v
+		}
+	}
+
+	params, err := provider.Complete(checkout, r.PostForm.Get("result") == "success")
+	if err != nil {
+		http.Error(w, "Invalid payment link", http.StatusBadRequest)
+		return
+	}
+
+	// Giả lập IPN server-to-server trước khi redirect khách
+	cb, err := provider.VerifyReturn(params)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_, err = applyPaymentCallback(ctx, provider.Name(), cb)
+		cancel()
+	}
+	if err != nil && err != errPaymentAlreadyPaid {
+		log.Println("⚠️ Mock IPN error:", err)
+	}
+
+	returnURL := checkout.Get("returnUrl")
+	if returnURL == "" {
+		returnURL = clientURL() + "/payment/result/" + provider.Name()
+	}
+	http.Redirect(w, r, returnURL+"?"+params.Encode(), http.StatusSeeOther)
+}
+
+// UpdatePaymentStatus - Admin cập nhật tay trạng thái thanh toán (COD đã thu tiền, hoàn tiền...)
+func UpdatePaymentStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ID đơn hàng không hợp lệ"})
+		return
+	}
+
+	var req struct {
+		Status string `json:"status"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var order Order
+	if err := database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": objectID}).Decode(&order); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy đơn hàng"})
+		return
+	}
+
+	from := order.PaymentStatus
+	if from == "" {
+		from = PaymentUnpaid
+	}
+	allowed := false
+	for _, next := range paymentTransitions[from] {
+		if next == req.Status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Không thể chuyển trạng thái thanh toán từ %q sang %q", from, req.Status),
+			"code":  "INVALID_PAYMENT_TRANSITION",
+		})
+		return
+	}
+
+	// Điều kiện trên trạng thái cũ để 2 admin/IPN cập nhật cùng lúc không ghi đè nhau
+	filter := bson.M{"_id": objectID, "paymentStatus": order.PaymentStatus}
+	if order.PaymentStatus == "" {
+		filter["paymentStatus"] = bson.M{"$in": bson.A{"", nil}}
+	}
+	now := time.Now()
+	set := bson.M{"paymentStatus": req.Status, "updatedAt": now}
+	if req.Status == PaymentPaid {
+		set["paidAt"] = now
+	}
+
+	update := bson.M{"$set": set}
+	if req.Status == PaymentRefunded {
+		// Admin đã hoàn tay -> bỏ cờ cần hoàn tiền
+		update["$unset"] = bson.M{"refundRequired": ""}
+	}
+
+	result, err := database.DB.Collection("orders").UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Println("❌ UpdatePaymentStatus error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể cập nhật thanh toán"})
+		return
+	}
+	if result.MatchedCount == 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Trạng thái thanh toán vừa thay đổi, vui lòng tải lại"})
+		return
+	}
+
+	writeAudit(ctx, r, "order.payment_status", "order", objectID.Hex(), map[string]interface{}{
+		"from": from,
+		"to":   req.Status,
+		"note": strings.TrimSpace(req.Note),
+	})
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"_id":           objectID.Hex(),
+		"paymentStatus": req.Status,
+	})
+}
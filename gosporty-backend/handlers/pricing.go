@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"gosporty-backend/database"
+	"gosporty-backend/models"
+)
+
+// PriceBreakdown - Chi tiết giá do server tính
+type PriceBreakdown struct {
+	Subtotal    float64 `json:"subtotal" bson:"subtotal"`       // Tổng theo giá gốc
+	Discount    float64 `json:"discount" bson:"discount"`       // Giảm giá sản phẩm
+	ShippingFee float64 `json:"shippingFee" bson:"shippingFee"` // Phí vận chuyển
+	GrandTotal  float64 `json:"grandTotal" bson:"grandTotal"`   // Khách phải trả
+
+	CouponCode     string  `json:"couponCode,omitempty" bson:"couponCode,omitempty"`
+	CouponDiscount float64 `json:"couponDiscount,omitempty" bson:"couponDiscount,omitempty"` // Giảm giá từ mã giảm giá
+}
+
+// recalc - Tính lại tổng tiền khách phải trả
+func (b *PriceBreakdown) recalc() {
+	b.GrandTotal = b.Subtotal - b.Discount - b.CouponDiscount + b.ShippingFee
+}
+
+// ItemError - Lỗi gắn với 1 dòng sản phẩm trong đơn hàng
+type ItemError struct {
+	ProductID string `json:"productId"`
+	Message   string `json:"message"`
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("%s: %s", e.ProductID, e.Message)
+}
+
+// unitPrice - Giá bán 1 sản phẩm sau giảm giá
+func unitPrice(p models.Product) float64 {
+	if p.Price > 0 {
+		return float64(p.Price)
+	}
+	if p.OriginalPrice > 0 && p.Discount > 0 {
+		return math.Round(float64(p.OriginalPrice) * float64(100-p.Discount) / 100)
+	}
+	return float64(p.OriginalPrice)
+}
+
+// listPrice - Giá gốc (trước giảm giá) của 1 sản phẩm
+func listPrice(p models.Product) float64 {
+	price := unitPrice(p)
+	if float64(p.OriginalPrice) > price {
+		return float64(p.OriginalPrice)
+	}
+	return price
+}
+
+// priceOrderItems - Load sản phẩm từ database và tính lại giá từng dòng.
+// Name/Image/Price do client gửi lên đều bị ghi đè. Phí vận chuyển tính riêng bằng quoteShipping.
+func priceOrderItems(ctx context.Context, items []OrderItem) ([]OrderItem, PriceBreakdown, error) {
+	var breakdown PriceBreakdown
+	priced := make([]OrderItem, 0, len(items))
+	coll := database.DB.Collection("products")
+
+	for _, item := range items {
+		if item.Qty < 1 {
+			return nil, breakdown, &ItemError{ProductID: item.ProductID, Message: "Số lượng không hợp lệ"}
+		}
+
+		objectID, err := primitive.ObjectIDFromHex(item.ProductID)
+		if err != nil {
+			return nil, breakdown, &ItemError{ProductID: item.ProductID, Message: "ID sản phẩm không hợp lệ"}
+		}
+
+		var product models.Product
+		err = coll.FindOne(ctx, bson.M{"_id": objectID}).Decode(&product)
+		if err == mongo.ErrNoDocuments {
+			return nil, breakdown, &ItemError{ProductID: item.ProductID, Message: "Sản phẩm không tồn tại"}
+		}
+		if err != nil {
+			return nil, breakdown, err
+		}
+
+		price := unitPrice(product)
+		list := listPrice(product)
+		image := product.Image
+
+		// Sản phẩm có variant: phân loại phải tồn tại, giá variant ghi đè giá sản phẩm
+		if len(product.Variants) > 0 {
+			variant := product.FindVariant(item.SelectedColor, item.SelectedSize)
+			if variant == nil {
+				return nil, breakdown, &ItemError{ProductID: item.ProductID, Message: fmt.Sprintf("Phân loại %s / %s không tồn tại", item.SelectedColor, item.SelectedSize)}
+			}
+			if variant.Price > 0 {
+				price = float64(variant.Price)
+				if list < price {
+					list = price
+				}
+			}
+			if variant.Image != "" {
+				image = variant.Image
+			}
+			item.SKU = variant.SKU
+		}
+
+		item.Name = product.Name
+		item.Image = image
+		item.Price = price
+		item.Weight = product.Weight
+		priced = append(priced, item)
+
+		breakdown.Subtotal += list * float64(item.Qty)
+		breakdown.Discount += (list - price) * float64(item.Qty)
+	}
+
+	breakdown.GrandTotal = breakdown.Subtotal - breakdown.Discount
+
+	return priced, breakdown, nil
+}
+
+// totalsMatch - So sánh tổng tiền client gửi với server (cho phép sai số làm tròn)
+func totalsMatch(clientTotal, serverTotal float64) bool {
+	return math.Abs(clientTotal-serverTotal) < 1
+}
+
+// writePricingError - Trả lỗi khi tính giá thất bại
+func writePricingError(w http.ResponseWriter, err error) {
+	if itemErr, ok := err.(*ItemError); ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": itemErr.Message,
+			"items": []*ItemError{itemErr},
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Không thể tính giá đơn hàng",
+	})
+}
+
+// QuoteOrder - Tính giá đơn hàng trước khi đặt (dùng cho trang checkout)
+func QuoteOrder(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Items           []OrderItem      `json:"items"`
+		ShippingAddress *ShippingAddress `json:"shippingAddress"`
+		ProvinceCode    string           `json:"provinceCode"`
+		ShippingMethod  string           `json:"shippingMethod"`
+		CouponCode      string           `json:"couponCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Dữ liệu không hợp lệ",
+		})
+		return
+	}
+
+	if len(req.Items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Đơn hàng phải có ít nhất 1 sản phẩm",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	applyItemDefaults(req.Items)
+	items, breakdown, err := priceOrderItems(ctx, req.Items)
+	if err != nil {
+		writePricingError(w, err)
+		return
+	}
+
+	provinceCode := req.ProvinceCode
+	if req.ShippingAddress != nil {
+		provinceCode = req.ShippingAddress.ProvinceCode
+	}
+	shipping, err := quoteShipping(ctx, items, breakdown.GrandTotal, provinceCode, req.ShippingMethod)
+	if err != nil {
+		writeShippingError(w, err)
+		return
+	}
+	applyShipping(&breakdown, shipping)
+
+	if req.CouponCode != "" {
+		coupon, discount, err := evaluateCoupon(ctx, req.CouponCode, nil, items, breakdown.Subtotal-breakdown.Discount)
+		if err != nil {
+			writeCouponError(w, err)
+			return
+		}
+		breakdown.CouponCode = coupon.Code
+		breakdown.CouponDiscount = discount
+		breakdown.recalc()
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":    items,
+		"pricing":  breakdown,
+		"shipping": shipping,
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -24,8 +25,15 @@ func CreateProduct(w http.ResponseWriter, r *http.Request) {
 	var p models.Product
 	json.NewDecoder(r.Body).Decode(&p)
 
+	// Kiểm tra ma trận variant (màu × size) và đồng bộ tổng tồn kho
+	if err := normalizeVariants(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	p.ID = primitive.NewObjectID()
 	p.CreatedAt = primitive.NewDateTimeFromTime(time.Now())
+	p.StockVersion = 0
 	p.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
 
 	coll := database.DB.Collection("products")
@@ -45,25 +53,12 @@ type PagedProducts struct {
 	Products []models.Product `json:"products"`
 }
 
-func GetProducts(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	q := r.URL.Query()
-
-	// pagination
-	page, _ := strconv.Atoi(q.Get("page"))
-	if page < 1 {
-		page = 1
-	}
-	limit, _ := strconv.Atoi(q.Get("limit"))
-	if limit <= 0 {
-		limit = 12
-	}
-
-	// filters
+// productListFilter - Điều kiện lọc sản phẩm từ query string (dùng chung cho danh sách và export):
+// category, subcategory, search
+func productListFilter(q url.Values) bson.M {
 	category := strings.TrimSpace(q.Get("category"))
 	subcategory := strings.TrimSpace(q.Get("subcategory"))
 	search := strings.TrimSpace(q.Get("search"))
-	sortParam := strings.TrimSpace(q.Get("sort"))
 
 	filter := bson.M{}
 	if category != "" {
@@ -78,18 +73,37 @@ func GetProducts(w http.ResponseWriter, r *http.Request) {
 			bson.M{"description": bson.M{"$regex": search, "$options": "i"}},
 		}
 	}
+	return filter
+}
 
-	// sort
-	sort := bson.D{{Key: "createdAt", Value: -1}}
-	switch sortParam {
+// productListSort - sort=newest (mặc định) | price_asc | price_desc
+func productListSort(q url.Values) bson.D {
+	switch strings.TrimSpace(q.Get("sort")) {
 	case "price_asc":
-		sort = bson.D{{Key: "price", Value: 1}}
+		return bson.D{{Key: "price", Value: 1}}
 	case "price_desc":
-		sort = bson.D{{Key: "price", Value: -1}}
-	case "newest":
-		sort = bson.D{{Key: "createdAt", Value: -1}}
+		return bson.D{{Key: "price", Value: -1}}
+	}
+	return bson.D{{Key: "createdAt", Value: -1}}
+}
+
+func GetProducts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	q := r.URL.Query()
+
+	// pagination
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = 12
 	}
 
+	filter := productListFilter(q)
+	sort := productListSort(q)
+
 	coll := database.DB.Collection("products")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -191,6 +205,8 @@ func GetProductBySlug(w http.ResponseWriter, r *http.Request) {
 }
 
 // 🔹 THÊM MỚI: Cập nhật sản phẩm
+// Tồn kho và ma trận variant không sửa ở đây (đơn hàng đang giữ hàng trên đó):
+// dùng PUT /admin/products/{id}/variants. Weight chỉ cập nhật khi có gửi lên.
 func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -204,60 +220,72 @@ func UpdateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var product models.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
+	var req struct {
+		models.Product
+		Weight *int `json:"weight"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
 		return
 	}
+	product := req.Product
 
-	product.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+	if req.Weight != nil && *req.Weight < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Khối lượng không hợp lệ"})
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	coll := database.DB.Collection("products")
 
-	update := bson.M{
-		"$set": bson.M{
-			"name":          product.Name,
-			"description":   product.Description,
-			"price":         product.Price,
-			"originalPrice": product.OriginalPrice,
-			"discount":      product.Discount,
-			"image":         product.Image,
-			"images":        product.Images,
-			"category":      product.Category,
-			"subcategory":   product.Subcategory,
-			"brand":         product.Brand,
-			"slug":          product.Slug,
-			"stock":         product.Stock,
-			"colors":        product.Colors,
-			"sizes":         product.Sizes,
-			"features":      product.Features,
-			"updatedAt":     product.UpdatedAt,
-		},
-	}
-
-	result, err := coll.UpdateOne(ctx, bson.M{"_id": objectID}, update)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
-		return
+	set := bson.M{
+		"name":          product.Name,
+		"description":   product.Description,
+		"price":         product.Price,
+		"originalPrice": product.OriginalPrice,
+		"discount":      product.Discount,
+		"image":         product.Image,
+		"images":        product.Images,
+		"category":      product.Category,
+		"subcategory":   product.Subcategory,
+		"brand":         product.Brand,
+		"slug":          product.Slug,
+		"features":      product.Features,
+		"updatedAt":     primitive.NewDateTimeFromTime(time.Now()),
+	}
+	if req.Weight != nil {
+		set["weight"] = *req.Weight
+	}
+
+	// Màu/size của sản phẩm có variant được đồng bộ từ ma trận variant, không lấy từ body
+	withVariants, err := coll.CountDocuments(ctx, bson.M{"_id": objectID, "variants.0": bson.M{"$exists": true}})
+	if err == nil && withVariants == 0 {
+		set["colors"] = product.Colors
+		set["sizes"] = product.Sizes
 	}
 
-	if result.MatchedCount == 0 {
+	var updated models.Product
+	err = coll.FindOneAndUpdate(ctx, bson.M{"_id": objectID}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy sản phẩm"})
 		return
 	}
-
-	product.ID = objectID
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"message": "Cập nhật sản phẩm thành công",
-		"product": product,
+		"product": updated,
 	})
 }
 
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+
+	"gosporty-backend/database"
+	"gosporty-backend/middlewares"
+)
+
+// phonePattern - Số điện thoại Việt Nam (0xxxxxxxxx hoặc +84xxxxxxxxx)
+var phonePattern = regexp.MustCompile(`^(0|\+84)[0-9]{9,10}$`)
+
+// profileResponse - Thông tin profile trả về cho client (không có password)
+func profileResponse(user User) map[string]interface{} {
+	return map[string]interface{}{
+		"_id":           user.ID,
+		"email":         user.Email,
+		"name":          user.Name,
+		"phone":         user.Phone,
+		"avatar":        user.Avatar,
+		"isAdmin":       user.IsAdmin,
+		"roles":         middlewares.UserRoles(user.IsAdmin),
+		"emailVerified": user.EmailVerified,
+	}
+}
+
+// currentUser - Load user đang đăng nhập từ database
+func currentUser(ctx context.Context, r *http.Request) (User, error) {
+	var user User
+
+	userID, _ := GetUserIDFromContext(r)
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return user, err
+	}
+
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	return user, err
+}
+
+// GetProfile - Lấy profile của user đang đăng nhập
+func GetProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(profileResponse(user))
+}
+
+// UpdateProfile - Cập nhật tên, số điện thoại, avatar
+func UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Name   *string `json:"name"`
+		Phone  *string `json:"phone"`
+		Avatar *string `json:"avatar"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	set := bson.M{}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Tên không được để trống"})
+			return
+		}
+		set["name"] = name
+	}
+	if req.Phone != nil {
+		phone := strings.TrimSpace(*req.Phone)
+		if phone != "" && !phonePattern.MatchString(phone) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Số điện thoại không hợp lệ"})
+			return
+		}
+		set["phone"] = phone
+	}
+	if req.Avatar != nil {
+		avatar := strings.TrimSpace(*req.Avatar)
+		if avatar != "" && !strings.HasPrefix(avatar, "http://") && !strings.HasPrefix(avatar, "https://") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Avatar phải là URL hợp lệ"})
+			return
+		}
+		set["avatar"] = avatar
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	if len(set) > 0 {
+		set["updatedAt"] = time.Now()
+		_, err = database.DB.Collection("users").UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$set": set})
+		if err != nil {
+			log.Println("❌ UpdateProfile error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update profile"})
+			return
+		}
+		user, _ = currentUser(ctx, r)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(profileResponse(user))
+}
+
+// ChangePassword - Đổi mật khẩu (phải nhập đúng mật khẩu hiện tại)
+// Các phiên khác bị đăng xuất, phiên hiện tại nhận token mới
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request"})
+		return
+	}
+
+	if len(req.NewPassword) < minPasswordLen {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Password must be at least %d characters", minPasswordLen)})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, err := currentUser(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Mật khẩu hiện tại không đúng"})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to hash password"})
+		return
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"password": string(hashedPassword), "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		log.Println("❌ ChangePassword error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to change password"})
+		return
+	}
+
+	if err := revokeAllSessions(ctx, user.ID.Hex()); err != nil {
+		log.Println("⚠️ Failed to revoke sessions after password change:", err)
+	}
+
+	// Cấp phiên mới cho thiết bị hiện tại
+	user, err = currentUser(ctx, r)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate token"})
+		return
+	}
+	session, err := issueSession(ctx, r, user)
+	if err != nil {
+		log.Println("❌ Failed to generate token:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate token"})
+		return
+	}
+
+	log.Println("✅ Password changed for user:", user.ID.Hex())
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      "Đổi mật khẩu thành công",
+		"token":        session.Token,
+		"refreshToken": session.RefreshToken,
+		"expiresIn":    session.ExpiresIn,
+	})
+}
@@ -0,0 +1,829 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+	"gosporty-backend/payment"
+)
+
+// ReturnStatus - Trạng thái yêu cầu đổi trả (RMA), tách riêng khỏi trạng thái đơn hàng
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested" // Khách vừa gửi yêu cầu
+	ReturnApproved  ReturnStatus = "approved"  // Admin chấp nhận, chờ khách gửi hàng về
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received" // Shop đã nhận lại hàng
+	ReturnRefunded  ReturnStatus = "refunded"
+)
+
+// returnTransitions - Đồ thị chuyển trạng thái của yêu cầu đổi trả
+var returnTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnReceived:  {ReturnRefunded},
+	ReturnRejected:  {},
+	ReturnRefunded:  {},
+}
+
+// returnableStatuses - Chỉ đơn đã giao mới được đổi trả
+var returnableStatuses = []OrderStatus{StatusDelivered, StatusCompleted}
+
+const maxReturnPhotos = 5
+
+// returnWindow - Thời hạn gửi yêu cầu đổi trả kể từ lúc giao hàng (RETURN_WINDOW, mặc định 7 ngày)
+var returnWindow = durationFromEnv("RETURN_WINDOW", 7*24*time.Hour)
+
+var (
+	ErrReturnNotFound          = errors.New("return request not found")
+	ErrInvalidReturnTransition = errors.New("invalid return status transition")
+)
+
+// ReturnItem - 1 dòng sản phẩm khách trả lại
+type ReturnItem struct {
+	ProductID     string  `json:"productId" bson:"productId"`
+	Name          string  `json:"name" bson:"name"`
+	Image         string  `json:"image,omitempty" bson:"image,omitempty"`
+	SelectedColor string  `json:"selectedColor" bson:"selectedColor"`
+	SelectedSize  string  `json:"selectedSize" bson:"selectedSize"`
+	SKU           string  `json:"sku,omitempty" bson:"sku,omitempty"`
+	Price         float64 `json:"price" bson:"price"`
+	Qty           int     `json:"qty" bson:"qty"`
+}
+
+// ReturnStatusChange - 1 lần đổi trạng thái của yêu cầu đổi trả
+type ReturnStatusChange struct {
+	From      ReturnStatus `json:"from,omitempty" bson:"from,omitempty"`
+	To        ReturnStatus `json:"to" bson:"to"`
+	ChangedBy string       `json:"changedBy" bson:"changedBy"`
+	Role      string       `json:"role,omitempty" bson:"role,omitempty"`
+	Note      string       `json:"note,omitempty" bson:"note,omitempty"`
+	At        time.Time    `json:"at" bson:"at"`
+}
+
+// RefundInfo - Thông tin hoàn tiền của yêu cầu đổi trả
+type RefundInfo struct {
+	Method           string    `json:"method" bson:"method"` // Tên cổng thanh toán hoặc "manual" (COD/chuyển khoản)
+	RefundRef        string    `json:"refundRef" bson:"refundRef"`
+	ProviderRefundID string    `json:"providerRefundId,omitempty" bson:"providerRefundId,omitempty"`
+	Amount           float64   `json:"amount" bson:"amount"`
+	Note             string    `json:"note,omitempty" bson:"note,omitempty"`
+	RefundedAt       time.Time `json:"refundedAt" bson:"refundedAt"`
+}
+
+// ReturnRequest - Yêu cầu đổi trả cho 1 đơn hàng
+type ReturnRequest struct {
+	ID            primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
+	OrderID       primitive.ObjectID   `json:"orderId" bson:"orderId"`
+	OrderNumber   string               `json:"orderNumber,omitempty" bson:"orderNumber,omitempty"`
+	UserID        string               `json:"userId,omitempty" bson:"userId,omitempty"`
+	CustomerEmail string               `json:"customerEmail" bson:"customerEmail"`
+	Items         []ReturnItem         `json:"items" bson:"items"`
+	Reason        string               `json:"reason" bson:"reason"`
+	Photos        []string             `json:"photos,omitempty" bson:"photos,omitempty"`
+	Status        ReturnStatus         `json:"status" bson:"status"`
+	StatusHistory []ReturnStatusChange `json:"statusHistory" bson:"statusHistory"`
+	RefundAmount  float64              `json:"refundAmount" bson:"refundAmount"` // Số tiền dự kiến hoàn (đã trừ giảm giá theo tỉ lệ)
+	Restocked     bool                 `json:"restocked" bson:"restocked"`
+	Refund        *RefundInfo          `json:"refund,omitempty" bson:"refund,omitempty"`
+	RejectReason  string               `json:"rejectReason,omitempty" bson:"rejectReason,omitempty"`
+	CreatedAt     time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// InitReturnCollection - Index cho returns
+func InitReturnCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("returns").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "orderId", Value: 1}}},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create return indexes:", err)
+	} else {
+		log.Println("✅ Return collection initialized with indexes")
+	}
+}
+
+// returnLineKey - Khóa xác định 1 dòng sản phẩm trong đơn
+func returnLineKey(productID, color, size string) string {
+	return productID + "|" + color + "|" + size
+}
+
+// deliveredAt - Thời điểm đơn được giao (theo lịch sử trạng thái), đơn cũ không có lịch sử thì dùng updatedAt
+func deliveredAt(order Order) time.Time {
+	for _, change := range order.StatusHistory {
+		if change.To == StatusDelivered || change.To == StatusCompleted {
+			return change.At
+		}
+	}
+	return order.UpdatedAt
+}
+
+// returnedQuantities - Số lượng đã yêu cầu trả theo từng dòng (bỏ qua yêu cầu bị từ chối)
+func returnedQuantities(ctx context.Context, orderID primitive.ObjectID) (map[string]int, error) {
+	cursor, err := database.DB.Collection("returns").Find(ctx, bson.M{
+		"orderId": orderID,
+		"status":  bson.M{"$ne": ReturnRejected},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var returns []ReturnRequest
+	if err := cursor.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+
+	qty := map[string]int{}
+	for _, ret := range returns {
+		for _, item := range ret.Items {
+			qty[returnLineKey(item.ProductID, item.SelectedColor, item.SelectedSize)] += item.Qty
+		}
+	}
+	return qty, nil
+}
+
+// returnRefundAmount - Tiền hàng trả lại, chia theo tỉ lệ phần giảm giá/mã giảm giá của đơn (không hoàn phí ship)
+func returnRefundAmount(order Order, items []ReturnItem) float64 {
+	returned := 0.0
+	for _, item := range items {
+		returned += item.Price * float64(item.Qty)
+	}
+
+	lines := 0.0
+	for _, item := range order.Items {
+		lines += item.Price * float64(item.Qty)
+	}
+	paid := order.Total - order.ShippingFee
+	if lines > 0 && paid < lines {
+		returned = returned * paid / lines
+	}
+	return math.Round(returned)
+}
+
+// returnOrderItems - Chuyển dòng trả hàng sang OrderItem để hoàn kho
+func returnOrderItems(ret ReturnRequest) []OrderItem {
+	items := make([]OrderItem, 0, len(ret.Items))
+	for _, item := range ret.Items {
+		items = append(items, OrderItem{
+			ProductID:     item.ProductID,
+			Name:          item.Name,
+			SelectedColor: item.SelectedColor,
+			SelectedSize:  item.SelectedSize,
+			Qty:           item.Qty,
+		})
+	}
+	return items
+}
+
+// validPhotoURL - Ảnh minh chứng là link http(s) (ảnh upload lên storage phía frontend)
+func validPhotoURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func writeReturnError(w http.ResponseWriter, status int, message, code string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message, "code": code})
+}
+
+// CreateReturn - Khách gửi yêu cầu đổi trả cho các dòng/số lượng cụ thể của đơn đã giao
+func CreateReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	orderID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ID đơn hàng không hợp lệ"})
+		return
+	}
+
+	var req struct {
+		Items []struct {
+			ProductID     string `json:"productId"`
+			SelectedColor string `json:"selectedColor"`
+			SelectedSize  string `json:"selectedSize"`
+			Qty           int    `json:"qty"`
+		} `json:"items"`
+		Reason string   `json:"reason"`
+		Photos []string `json:"photos"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		writeReturnError(w, http.StatusBadRequest, "Vui lòng nhập lý do đổi trả", "RETURN_REASON_REQUIRED")
+		return
+	}
+	if len(req.Items) == 0 {
+		writeReturnError(w, http.StatusBadRequest, "Vui lòng chọn sản phẩm cần trả", "RETURN_ITEMS_REQUIRED")
+		return
+	}
+	if len(req.Photos) > maxReturnPhotos {
+		writeReturnError(w, http.StatusBadRequest, fmt.Sprintf("Tối đa %d ảnh", maxReturnPhotos), "RETURN_TOO_MANY_PHOTOS")
+		return
+	}
+	for _, photo := range req.Photos {
+		if !validPhotoURL(photo) {
+			writeReturnError(w, http.StatusBadRequest, "Link ảnh không hợp lệ", "RETURN_INVALID_PHOTO")
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var order Order
+	err = database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": orderID}).Decode(&order)
+	if err != nil || !canAccessOrder(r, order) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy đơn hàng"})
+		return
+	}
+
+	returnable := false
+	for _, s := range returnableStatuses {
+		if order.Status.Normalize() == s {
+			returnable = true
+		}
+	}
+	if !returnable {
+		writeReturnError(w, http.StatusConflict, "Chỉ có thể đổi trả đơn hàng đã giao", "ORDER_NOT_RETURNABLE")
+		return
+	}
+	if time.Since(deliveredAt(order)) > returnWindow {
+		writeReturnError(w, http.StatusConflict, "Đơn hàng đã quá thời hạn đổi trả", "RETURN_WINDOW_EXPIRED")
+		return
+	}
+
+	returned, err := returnedQuantities(ctx, orderID)
+	if err != nil {
+		log.Println("❌ Error loading returns:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tạo yêu cầu đổi trả"})
+		return
+	}
+
+	lines := map[string]OrderItem{}
+	for _, item := range order.Items {
+		key := returnLineKey(item.ProductID, item.SelectedColor, item.SelectedSize)
+		line := lines[key]
+		if line.ProductID == "" {
+			line = item
+		} else {
+			line.Qty += item.Qty
+		}
+		lines[key] = line
+	}
+
+	// Gộp các dòng trùng trong request rồi kiểm tra không vượt số lượng đã mua
+	requested := map[string]int{}
+	keys := []string{}
+	for _, item := range req.Items {
+		applied := []OrderItem{{SelectedColor: item.SelectedColor, SelectedSize: item.SelectedSize}}
+		applyItemDefaults(applied)
+		key := returnLineKey(item.ProductID, applied[0].SelectedColor, applied[0].SelectedSize)
+		if _, ok := lines[key]; !ok || item.Qty <= 0 {
+			writeReturnError(w, http.StatusBadRequest, "Sản phẩm trả lại không có trong đơn hàng", "RETURN_INVALID_ITEM")
+			return
+		}
+		if _, seen := requested[key]; !seen {
+			keys = append(keys, key)
+		}
+		requested[key] += item.Qty
+	}
+
+	items := make([]ReturnItem, 0, len(requested))
+	for _, key := range keys {
+		line := lines[key]
+		if remaining := line.Qty - returned[key]; requested[key] > remaining {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":     fmt.Sprintf("%s chỉ còn có thể trả %d sản phẩm", line.Name, max(remaining, 0)),
+				"code":      "RETURN_QTY_EXCEEDED",
+				"productId": line.ProductID,
+				"remaining": max(remaining, 0),
+			})
+			return
+		}
+		items = append(items, ReturnItem{
+			ProductID:     line.ProductID,
+			Name:          line.Name,
+			Image:         line.Image,
+			SelectedColor: line.SelectedColor,
+			SelectedSize:  line.SelectedSize,
+			SKU:           line.SKU,
+			Price:         line.Price,
+			Qty:           requested[key],
+		})
+	}
+
+	actor := actorFromRequest(r)
+	now := time.Now()
+	ret := ReturnRequest{
+		ID:            primitive.NewObjectID(),
+		OrderID:       order.ID,
+		OrderNumber:   order.OrderNumber,
+		UserID:        order.UserID,
+		CustomerEmail: order.CustomerEmail,
+		Items:         items,
+		Reason:        req.Reason,
+		Photos:        req.Photos,
+		Status:        ReturnRequested,
+		StatusHistory: []ReturnStatusChange{{To: ReturnRequested, ChangedBy: actor.ID, Role: actor.Role, At: now}},
+		RefundAmount:  returnRefundAmount(order, items),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	if _, err := database.DB.Collection("returns").InsertOne(ctx, ret); err != nil {
+		log.Println("❌ Error creating return:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tạo yêu cầu đổi trả"})
+		return
+	}
+
+	// Tăng returnSeq theo giá trị đã đọc: 2 yêu cầu đồng thời cho cùng đơn thì 1 bên thất bại
+	// và bị xóa, tránh trả vượt số lượng đã mua
+	result, err := database.DB.Collection("orders").UpdateOne(ctx,
+		bson.M{"_id": order.ID, "returnSeq": bson.M{"$in": bson.A{order.ReturnSeq, nil}}},
+		bson.M{"$set": bson.M{"returnSeq": order.ReturnSeq + 1}},
+	)
+	if err != nil || result.MatchedCount == 0 {
+		database.DB.Collection("returns").DeleteOne(ctx, bson.M{"_id": ret.ID})
+		writeReturnError(w, http.StatusConflict, "Đơn hàng vừa có yêu cầu đổi trả khác, vui lòng thử lại", "RETURN_CONFLICT")
+		return
+	}
+
+	log.Printf("✅ Return requested for order %s - ID: %s\n", orderLabel(order), ret.ID.Hex())
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ret)
+}
+
+// findReturns - Danh sách yêu cầu đổi trả mới nhất trước
+func findReturns(ctx context.Context, filter bson.M) ([]ReturnRequest, error) {
+	cursor, err := database.DB.Collection("returns").Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	returns := []ReturnRequest{}
+	if err := cursor.All(ctx, &returns); err != nil {
+		return nil, err
+	}
+	return returns, nil
+}
+
+// GetOrderReturns - Các yêu cầu đổi trả của 1 đơn (chủ đơn, admin hoặc khách có order token)
+func GetOrderReturns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	orderID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ID đơn hàng không hợp lệ"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var order Order
+	err = database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": orderID}).Decode(&order)
+	if err != nil || !canAccessOrder(r, order) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy đơn hàng"})
+		return
+	}
+
+	returns, err := findReturns(ctx, bson.M{"orderId": orderID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tải yêu cầu đổi trả"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(returns)
+}
+
+// GetMyReturns - Các yêu cầu đổi trả của user đang đăng nhập
+func GetMyReturns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := GetUserIDFromContext(r)
+	if !ok || userID == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	returns, err := findReturns(ctx, bson.M{"userId": userID})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tải yêu cầu đổi trả"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(returns)
+}
+
+// GetAllReturns - Admin xem yêu cầu đổi trả (?status=requested&orderId=...)
+func GetAllReturns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	filter := bson.M{}
+	if status := r.URL.Query().Get("status"); status != "" {
+		if _, ok := returnTransitions[ReturnStatus(status)]; !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Trạng thái không hợp lệ"})
+			return
+		}
+		filter["status"] = status
+	}
+	if orderID := r.URL.Query().Get("orderId"); orderID != "" {
+		objectID, err := primitive.ObjectIDFromHex(orderID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "ID đơn hàng không hợp lệ"})
+			return
+		}
+		filter["orderId"] = objectID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	returns, err := findReturns(ctx, filter)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tải yêu cầu đổi trả"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(returns)
+}
+
+// transitionReturn - Đổi trạng thái yêu cầu đổi trả một cách nguyên tử (điều kiện trên trạng thái hiện tại)
+func transitionReturn(ctx context.Context, id primitive.ObjectID, to ReturnStatus, actor Actor, note string, extraSet bson.M) (ReturnRequest, error) {
+	coll := database.DB.Collection("returns")
+
+	var current ReturnRequest
+	if err := coll.FindOne(ctx, bson.M{"_id": id}).Decode(&current); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return ReturnRequest{}, ErrReturnNotFound
+		}
+		return ReturnRequest{}, err
+	}
+
+	allowed := false
+	for _, next := range returnTransitions[current.Status] {
+		if next == to {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return current, ErrInvalidReturnTransition
+	}
+
+	now := time.Now()
+	set := bson.M{"status": to, "updatedAt": now}
+	for k, v := range extraSet {
+		set[k] = v
+	}
+
+	var updated ReturnRequest
+	err := coll.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": current.Status},
+		bson.M{
+			"$set": set,
+			"$push": bson.M{"statusHistory": ReturnStatusChange{
+				From: current.Status, To: to, ChangedBy: actor.ID, Role: actor.Role, Note: note, At: now,
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return current, ErrInvalidReturnTransition
+	}
+	if err != nil {
+		return current, err
+	}
+	return updated, nil
+}
+
+// writeReturnTransitionError - Trả lỗi khi đổi trạng thái yêu cầu đổi trả thất bại
+func writeReturnTransitionError(w http.ResponseWriter, current ReturnRequest, to ReturnStatus, err error) {
+	switch err {
+	case ErrReturnNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy yêu cầu đổi trả"})
+	case ErrInvalidReturnTransition:
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":   fmt.Sprintf("Không thể chuyển yêu cầu đổi trả từ %q sang %q", current.Status, to),
+			"code":    "INVALID_RETURN_TRANSITION",
+			"allowed": returnTransitions[current.Status],
+		})
+	default:
+		log.Println("❌ Return transition error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể cập nhật yêu cầu đổi trả"})
+	}
+}
+
+// returnActionRequest - Body chung cho các thao tác admin trên yêu cầu đổi trả
+type returnActionRequest struct {
+	Note    string   `json:"note"`
+	Reason  string   `json:"reason"`  // Lý do từ chối
+	Restock *bool    `json:"restock"` // Nhận hàng: cộng lại tồn kho (mặc định true)
+	Amount  *float64 `json:"amount"`  // Hoàn tiền: số tiền khác số dự kiến
+}
+
+// decodeReturnAction - Đọc ID và body (body rỗng được chấp nhận)
+func decodeReturnAction(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, returnActionRequest, bool) {
+	var req returnActionRequest
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ID yêu cầu đổi trả không hợp lệ"})
+		return id, req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return id, req, false
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	req.Reason = strings.TrimSpace(req.Reason)
+	return id, req, true
+}
+
+// ApproveReturn - Admin chấp nhận yêu cầu đổi trả
+func ApproveReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, req, ok := decodeReturnAction(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ret, err := transitionReturn(ctx, id, ReturnApproved, actorFromRequest(r), req.Note, nil)
+	if err != nil {
+		writeReturnTransitionError(w, ret, ReturnApproved, err)
+		return
+	}
+
+	writeAudit(ctx, r, "return.approve", "return", id.Hex(), map[string]interface{}{"orderId": ret.OrderID.Hex()})
+	json.NewEncoder(w).Encode(ret)
+}
+
+// RejectReturn - Admin từ chối yêu cầu đổi trả (bắt buộc có lý do)
+func RejectReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, req, ok := decodeReturnAction(w, r)
+	if !ok {
+		return
+	}
+	if req.Reason == "" {
+		writeReturnError(w, http.StatusBadRequest, "Vui lòng nhập lý do từ chối", "RETURN_REASON_REQUIRED")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ret, err := transitionReturn(ctx, id, ReturnRejected, actorFromRequest(r), req.Reason, bson.M{"rejectReason": req.Reason})
+	if err != nil {
+		writeReturnTransitionError(w, ret, ReturnRejected, err)
+		return
+	}
+
+	writeAudit(ctx, r, "return.reject", "return", id.Hex(), map[string]interface{}{
+		"orderId": ret.OrderID.Hex(),
+		"reason":  req.Reason,
+	})
+	json.NewEncoder(w).Encode(ret)
+}
+
+// ReceiveReturn - Admin xác nhận đã nhận lại hàng, mặc định cộng lại tồn kho
+func ReceiveReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, req, ok := decodeReturnAction(w, r)
+	if !ok {
+		return
+	}
+	restock := req.Restock == nil || *req.Restock
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Chuyển trạng thái trước (nguyên tử) rồi mới hoàn kho -> bấm 2 lần không cộng kho 2 lần
+	ret, err := transitionReturn(ctx, id, ReturnReceived, actorFromRequest(r), req.Note, bson.M{"restocked": restock})
+	if err != nil {
+		writeReturnTransitionError(w, ret, ReturnReceived, err)
+		return
+	}
+	if restock {
+		releaseStock(ctx, returnOrderItems(ret))
+		log.Printf("✅ Stock restored for return %s\n", id.Hex())
+	}
+
+	writeAudit(ctx, r, "return.receive", "return", id.Hex(), map[string]interface{}{
+		"orderId": ret.OrderID.Hex(),
+		"restock": restock,
+	})
+	json.NewEncoder(w).Encode(ret)
+}
+
+// refundPayment - Hoàn tiền qua cổng thanh toán của đơn nếu cổng hỗ trợ, ngược lại ghi nhận hoàn tay
+func refundPayment(ctx context.Context, order Order, ret ReturnRequest, amount float64, note string) (RefundInfo, error) {
+	refund := RefundInfo{
+		Method:     "manual",
+		RefundRef:  fmt.Sprintf("RF-%s-%d", ret.ID.Hex(), time.Now().Unix()),
+		Amount:     amount,
+		Note:       note,
+		RefundedAt: time.Now(),
+	}
+
+	if order.Payment == nil || order.PaymentStatus != PaymentPaid {
+		return refund, nil
+	}
+	provider, ok := providerFor(order.Payment.Provider)
+	if !ok {
+		return refund, nil
+	}
+	refunder, ok := provider.(payment.Refunder)
+	if !ok {
+		return refund, nil
+	}
+
+	result, err := refunder.Refund(ctx, payment.RefundRequest{
+		OrderID:        order.ID.Hex(),
+		TransactionRef: order.Payment.TransactionRef,
+		ProviderTxnID:  order.Payment.ProviderTxnID,
+		RefundRef:      refund.RefundRef,
+		Amount:         int64(math.Round(amount)),
+		Reason:         ret.Reason,
+	})
+	if err != nil {
+		return refund, err
+	}
+	refund.Method = provider.Name()
+	refund.ProviderRefundID = result.ProviderRefundID
+	return refund, nil
+}
+
+// RefundReturn - Admin hoàn tiền cho yêu cầu đổi trả đã nhận hàng
+func RefundReturn(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, req, ok := decodeReturnAction(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var ret ReturnRequest
+	if err := database.DB.Collection("returns").FindOne(ctx, bson.M{"_id": id}).Decode(&ret); err != nil {
+		writeReturnTransitionError(w, ret, ReturnRefunded, ErrReturnNotFound)
+		return
+	}
+	if ret.Status != ReturnReceived {
+		writeReturnTransitionError(w, ret, ReturnRefunded, ErrInvalidReturnTransition)
+		return
+	}
+
+	var order Order
+	if err := database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": ret.OrderID}).Decode(&order); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy đơn hàng"})
+		return
+	}
+
+	amount := ret.RefundAmount
+	if req.Amount != nil {
+		amount = math.Round(*req.Amount)
+	}
+	if remaining := order.Total - order.RefundedAmount; amount > remaining {
+		amount = math.Max(remaining, 0)
+	}
+	if amount <= 0 {
+		writeReturnError(w, http.StatusConflict, "Không còn số tiền nào để hoàn cho đơn hàng này", "NOTHING_TO_REFUND")
+		return
+	}
+
+	// Giữ yêu cầu ở trạng thái "refunded" trước khi gọi cổng thanh toán để không hoàn tiền 2 lần
+	actor := actorFromRequest(r)
+	claimed, err := transitionReturn(ctx, id, ReturnRefunded, actor, req.Note, nil)
+	if err != nil {
+		writeReturnTransitionError(w, claimed, ReturnRefunded, err)
+		return
+	}
+
+	// Giữ trước số tiền hoàn trên đơn; điều kiện nằm trong filter để 2 lần hoàn song song không vượt tổng tiền
+	orders := database.DB.Collection("orders")
+	reserved, err := orders.UpdateOne(ctx, bson.M{
+		"_id":   order.ID,
+		"$expr": bson.M{"$lte": bson.A{bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$refundedAmount", 0}}, amount}}, "$total"}},
+	}, bson.M{
+		"$inc": bson.M{"refundedAmount": amount},
+		"$set": bson.M{"updatedAt": time.Now()},
+	})
+	if err != nil || reserved.MatchedCount == 0 {
+		if err != nil {
+			log.Printf("❌ Could not reserve refund on order %s: %v\n", orderLabel(order), err)
+		}
+		releaseRefundClaim(ctx, id, "Số tiền hoàn vượt quá số tiền còn lại của đơn hàng")
+		writeReturnError(w, http.StatusConflict, "Không còn số tiền nào để hoàn cho đơn hàng này", "NOTHING_TO_REFUND")
+		return
+	}
+
+	refund, err := refundPayment(ctx, order, ret, amount, req.Note)
+	if err != nil {
+		log.Printf("❌ Refund failed for return %s: %v\n", id.Hex(), err)
+		if _, err := orders.UpdateOne(ctx, bson.M{"_id": order.ID}, bson.M{
+			"$inc": bson.M{"refundedAmount": -amount},
+			"$set": bson.M{"updatedAt": time.Now()},
+		}); err != nil {
+			log.Printf("❌ Could not release refund reservation on order %s: %v\n", orderLabel(order), err)
+		}
+		releaseRefundClaim(ctx, id, "Hoàn tiền thất bại: "+err.Error())
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Cổng thanh toán từ chối hoàn tiền, vui lòng thử lại",
+			"code":  "REFUND_FAILED",
+		})
+		return
+	}
+
+	if err := database.DB.Collection("returns").FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"refund": refund, "updatedAt": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&claimed); err != nil {
+		log.Printf("❌ Refund %s succeeded but could not be saved on return %s: %v\n", refund.RefundRef, id.Hex(), err)
+	}
+
+	// Hoàn đủ tổng tiền thì trạng thái thanh toán -> refunded
+	orders.UpdateOne(ctx,
+		bson.M{"_id": order.ID, "paymentStatus": PaymentPaid, "$expr": bson.M{"$gte": bson.A{"$refundedAmount", "$total"}}},
+		bson.M{"$set": bson.M{"paymentStatus": PaymentRefunded}},
+	)
+
+	writeAudit(ctx, r, "return.refund", "return", id.Hex(), map[string]interface{}{
+		"orderId":   order.ID.Hex(),
+		"amount":    amount,
+		"method":    refund.Method,
+		"refundRef": refund.RefundRef,
+	})
+	log.Printf("✅ Refunded %.0f for return %s (order %s, %s)\n", amount, id.Hex(), orderLabel(order), refund.Method)
+
+	json.NewEncoder(w).Encode(claimed)
+}
+
+// releaseRefundClaim - Trả yêu cầu đang giữ ở "refunded" về "received" khi không hoàn được tiền
+func releaseRefundClaim(ctx context.Context, id primitive.ObjectID, note string) {
+	database.DB.Collection("returns").UpdateOne(ctx, bson.M{"_id": id, "status": ReturnRefunded}, bson.M{
+		"$set": bson.M{"status": ReturnReceived, "updatedAt": time.Now()},
+		"$push": bson.M{"statusHistory": ReturnStatusChange{
+			From: ReturnRefunded, To: ReturnReceived, ChangedBy: SystemActor.ID, Role: SystemActor.Role,
+			Note: note, At: time.Now(),
+		}},
+	})
+}
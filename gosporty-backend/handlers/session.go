@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+	"gosporty-backend/middlewares"
+)
+
+// RefreshToken - Phiên đăng nhập, chỉ lưu hash của refresh token
+type RefreshToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	UserID     string             `bson:"userId"`
+	TokenHash  string             `bson:"tokenHash"`
+	FamilyID   string             `bson:"familyId"` // Các token xoay vòng từ cùng 1 lần đăng nhập
+	UserAgent  string             `bson:"userAgent,omitempty"`
+	IP         string             `bson:"ip,omitempty"`
+	CreatedAt  time.Time          `bson:"createdAt"`
+	ExpiresAt  time.Time          `bson:"expiresAt"`
+	RevokedAt  *time.Time         `bson:"revokedAt,omitempty"`
+	ReplacedBy string             `bson:"replacedBy,omitempty"`
+}
+
+// TokenPair - Access token + refresh token trả về cho client
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"` // giây
+}
+
+var (
+	// Access token ngắn hạn: SPA gọi mọi request qua services/api, interceptor tự refresh khi nhận 401
+	accessTokenTTL  = durationFromEnv("JWT_ACCESS_TTL", 15*time.Minute)
+	refreshTokenTTL = durationFromEnv("JWT_REFRESH_TTL", 30*24*time.Hour)
+
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+// durationFromEnv - Đọc time.Duration từ biến môi trường (vd: "15m", "720h")
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("⚠️ Invalid %s=%q, using %s\n", key, value, fallback)
+	}
+	return fallback
+}
+
+// InitSessionCollection - Tạo index cho refresh_tokens
+func InitSessionCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("refresh_tokens").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tokenHash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "familyId", Value: 1}}},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create refresh token index:", err)
+	} else {
+		log.Println("✅ Refresh token collection initialized with index")
+	}
+}
+
+// hashToken - SHA-256 của token ngẫu nhiên (token có entropy cao nên không cần bcrypt)
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken - Sinh chuỗi ngẫu nhiên 32 byte dạng hex
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// signAccessToken - Tạo access token ngắn hạn có kèm tokenVersion của user
+func signAccessToken(user User) (string, error) {
+	userIDStr := user.ID.Hex()
+	now := time.Now()
+
+	claims := &Claims{
+		UserID:       userIDStr,
+		Email:        user.Email,
+		Roles:        middlewares.UserRoles(user.IsAdmin),
+		TokenVersion: user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   userIDStr,
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// storeRefreshToken - Sinh refresh token mới trong family và lưu hash vào DB
+func storeRefreshToken(ctx context.Context, r *http.Request, userID, familyID string) (string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	doc := RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(token),
+		FamilyID:  familyID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+		CreatedAt: now,
+		ExpiresAt: now.Add(refreshTokenTTL),
+	}
+
+	if _, err := database.DB.Collection("refresh_tokens").InsertOne(ctx, doc); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// issueSession - Tạo access token + refresh token cho phiên đăng nhập mới
+func issueSession(ctx context.Context, r *http.Request, user User) (TokenPair, error) {
+	access, err := signAccessToken(user)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	familyID := primitive.NewObjectID().Hex()
+	refresh, err := storeRefreshToken(ctx, r, user.ID.Hex(), familyID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{Token: access, RefreshToken: refresh, ExpiresIn: int64(accessTokenTTL.Seconds())}, nil
+}
+
+// revokeFamily - Thu hồi toàn bộ refresh token của 1 phiên
+func revokeFamily(ctx context.Context, familyID string) error {
+	_, err := database.DB.Collection("refresh_tokens").UpdateMany(ctx,
+		bson.M{"familyId": familyID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	return err
+}
+
+// rotateRefreshToken - Đổi refresh token cũ lấy cặp token mới.
+// Nếu token đã bị thu hồi mà vẫn được dùng lại -> nghi bị lộ, thu hồi cả family.
+func rotateRefreshToken(ctx context.Context, r *http.Request, token string) (TokenPair, error) {
+	coll := database.DB.Collection("refresh_tokens")
+
+	var current RefreshToken
+	if err := coll.FindOne(ctx, bson.M{"tokenHash": hashToken(token)}).Decode(&current); err != nil {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+
+	if current.RevokedAt != nil {
+		log.Printf("⚠️ Refresh token reuse detected for user %s - revoking session\n", current.UserID)
+		revokeFamily(ctx, current.FamilyID)
+		return TokenPair{}, errInvalidRefreshToken
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+
+	userObjectID, err := primitive.ObjectIDFromHex(current.UserID)
+	if err != nil {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+	var user User
+	if err := database.DB.Collection("users").FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user); err != nil {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+	if isBlockedStatus(user.Status) {
+		revokeFamily(ctx, current.FamilyID)
+		return TokenPair{}, errInvalidRefreshToken
+	}
+
+	newToken, err := storeRefreshToken(ctx, r, current.UserID, current.FamilyID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	// Đánh dấu token cũ đã dùng - điều kiện revokedAt giúp 2 request song song không cùng rotate
+	result, err := coll.UpdateOne(ctx,
+		bson.M{"_id": current.ID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now(), "replacedBy": hashToken(newToken)}},
+	)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if result.ModifiedCount == 0 {
+		revokeFamily(ctx, current.FamilyID)
+		return TokenPair{}, errInvalidRefreshToken
+	}
+
+	access, err := signAccessToken(user)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{Token: access, RefreshToken: newToken, ExpiresIn: int64(accessTokenTTL.Seconds())}, nil
+}
+
+// trustedProxies - Proxy được tin X-Forwarded-For (TRUSTED_PROXIES="10.0.0.0/8,127.0.0.1")
+var trustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+
+// parseTrustedProxies - Danh sách IP hoặc CIDR, cách nhau bởi dấu phẩy
+func parseTrustedProxies(value string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			if ip := net.ParseIP(part); ip != nil && ip.To4() != nil {
+				part += "/32"
+			} else {
+				part += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(part)
+		if err != nil {
+			log.Printf("⚠️ Invalid TRUSTED_PROXIES entry %q\n", part)
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func isTrustedProxy(proxies []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range proxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP - IP của client. X-Forwarded-For chỉ được dùng khi request đến từ proxy tin cậy,
+// nếu không client tự đặt header để né giới hạn đăng nhập theo IP hoặc khóa IP của người khác
+func clientIP(r *http.Request) string {
+	return forwardedClientIP(trustedProxies, r.RemoteAddr, r.Header.Get("X-Forwarded-For"))
+}
+
+// forwardedClientIP - Đi từ phải sang trái trong X-Forwarded-For, bỏ qua các proxy tin cậy
+func forwardedClientIP(proxies []*net.IPNet, remoteAddr, forwarded string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	if forwarded == "" || !isTrustedProxy(proxies, host) {
+		return host
+	}
+
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !isTrustedProxy(proxies, hop) {
+			break
+		}
+	}
+	return host
+}
+
+// RefreshTokenHandler - Đổi refresh token lấy access token mới
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Refresh token required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pair, err := rotateRefreshToken(ctx, r, req.RefreshToken)
+	if err == errInvalidRefreshToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid or expired refresh token"})
+		return
+	}
+	if err != nil {
+		log.Println("❌ Failed to refresh token:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to generate token"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(pair)
+}
+
+// LogoutHandler - Thu hồi phiên đăng nhập hiện tại (theo refresh token)
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Refresh token required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var current RefreshToken
+	err := database.DB.Collection("refresh_tokens").FindOne(ctx, bson.M{"tokenHash": hashToken(req.RefreshToken)}).Decode(&current)
+	if err == nil {
+		if err := revokeFamily(ctx, current.FamilyID); err != nil {
+			log.Println("❌ Failed to revoke session:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to logout"})
+			return
+		}
+	}
+
+	// Token không tồn tại cũng coi như đã đăng xuất
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
+}
+
+// LogoutAllHandler - Đăng xuất mọi thiết bị: tăng tokenVersion và thu hồi mọi refresh token
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	userID, ok := GetUserIDFromContext(r)
+	if !ok || userID == "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unauthorized"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := revokeAllSessions(ctx, userID); err != nil {
+		log.Println("❌ Failed to logout all devices:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to logout"})
+		return
+	}
+
+	log.Println("✅ Logged out all devices for user:", userID)
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out from all devices"})
+}
+
+// revokeAllSessions - Vô hiệu hóa mọi access token (qua tokenVersion) và refresh token của user
+func revokeAllSessions(ctx context.Context, userID string) error {
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": objectID},
+		bson.M{"$inc": bson.M{"tokenVersion": 1}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = database.DB.Collection("refresh_tokens").UpdateMany(ctx,
+		bson.M{"userId": userID, "revokedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revokedAt": time.Now()}},
+	)
+	return err
+}
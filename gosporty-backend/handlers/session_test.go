@@ -0,0 +1,45 @@
+package handlers
+
+import "testing"
+
+func TestForwardedClientIP(t *testing.T) {
+	proxies := parseTrustedProxies("10.0.0.0/8, 192.168.1.1, ::1")
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"no header", "203.0.113.7:5000", "", "203.0.113.7"},
+		{"untrusted peer ignores header", "203.0.113.7:5000", "1.2.3.4", "203.0.113.7"},
+		{"trusted peer", "10.1.2.3:5000", "1.2.3.4", "1.2.3.4"},
+		{"bare IP proxy", "192.168.1.1:5000", "1.2.3.4", "1.2.3.4"},
+		{"IPv6 proxy", "[::1]:5000", "1.2.3.4", "1.2.3.4"},
+		{"spoofed left hops", "10.1.2.3:5000", "6.6.6.6, 1.2.3.4", "1.2.3.4"},
+		{"proxy chain", "10.1.2.3:5000", "1.2.3.4, 10.9.9.9", "1.2.3.4"},
+		{"garbage hop", "10.1.2.3:5000", "junk", "10.1.2.3"},
+		{"remote addr without port", "203.0.113.7", "1.2.3.4", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		if got := forwardedClientIP(proxies, tt.remoteAddr, tt.forwarded); got != tt.want {
+			t.Errorf("%s: forwardedClientIP(%q, %q) = %q, want %q", tt.name, tt.remoteAddr, tt.forwarded, got, tt.want)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 0},
+		{"10.0.0.1", 1},
+		{"10.0.0.0/8,::1", 2},
+		{"not-an-ip, 10.0.0.1", 1},
+	}
+	for _, tt := range tests {
+		if got := len(parseTrustedProxies(tt.value)); got != tt.want {
+			t.Errorf("parseTrustedProxies(%q) = %d entries, want %d", tt.value, got, tt.want)
+		}
+	}
+}
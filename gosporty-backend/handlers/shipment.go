@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/carrier"
+	"gosporty-backend/database"
+)
+
+// shipmentCreating - Trạng thái tạm trong lúc gọi API hãng, chống tạo 2 vận đơn cho 1 đơn
+const shipmentCreating = "creating"
+
+// ShipmentInfo - Vận đơn hiện tại của đơn hàng
+type ShipmentInfo struct {
+	Carrier      string    `json:"carrier" bson:"carrier"`
+	TrackingCode string    `json:"trackingCode,omitempty" bson:"trackingCode,omitempty"`
+	Status       string    `json:"status" bson:"status"` // created | picked_up | in_transit | delivered | ...
+	Fee          float64   `json:"fee" bson:"fee"`       // Phí hãng tính cho shop
+	CODAmount    float64   `json:"codAmount" bson:"codAmount"`
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// TrackingEvent - Sự kiện hành trình lưu trên đơn hàng
+type TrackingEvent struct {
+	EventID      string    `json:"eventId" bson:"eventId"`
+	Carrier      string    `json:"carrier" bson:"carrier"`
+	TrackingCode string    `json:"trackingCode" bson:"trackingCode"`
+	Status       string    `json:"status" bson:"status"`
+	RawStatus    string    `json:"rawStatus,omitempty" bson:"rawStatus,omitempty"`
+	Description  string    `json:"description,omitempty" bson:"description,omitempty"`
+	Location     string    `json:"location,omitempty" bson:"location,omitempty"`
+	At           time.Time `json:"at" bson:"at"`
+	ReceivedAt   time.Time `json:"receivedAt" bson:"receivedAt"`
+}
+
+// shipmentOrderPath - Trạng thái đơn hàng cần đi qua khi hãng báo 1 trạng thái vận đơn
+var shipmentOrderPath = map[string][]OrderStatus{
+	carrier.StatusPickedUp:  {StatusShipping},
+	carrier.StatusInTransit: {StatusShipping},
+	carrier.StatusDelivered: {StatusShipping, StatusDelivered},
+}
+
+var carriers = map[string]carrier.Carrier{}
+
+// SetCarriers - Đăng ký các hãng vận chuyển (key = tên hãng, không phân biệt hoa thường)
+func SetCarriers(list []carrier.Carrier) {
+	carriers = map[string]carrier.Carrier{}
+	for _, c := range list {
+		carriers[strings.ToLower(c.Name())] = c
+	}
+}
+
+func carrierFor(name string) (carrier.Carrier, bool) {
+	c, ok := carriers[strings.ToLower(strings.TrimSpace(name))]
+	return c, ok
+}
+
+// InitShipmentIndexes - Index tra đơn theo mã vận đơn (webhook)
+func InitShipmentIndexes(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("orders").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "shipment.carrier", Value: 1}, {Key: "shipment.trackingCode", Value: 1}},
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create shipment index:", err)
+	} else {
+		log.Println("✅ Shipment index initialized")
+	}
+}
+
+// shipmentRequest - Dữ liệu vận đơn từ đơn hàng (đơn chưa thanh toán thì hãng thu hộ tổng tiền)
+func shipmentRequest(ctx context.Context, order Order) carrier.ShipmentRequest {
+	req := carrier.ShipmentRequest{
+		OrderID:       order.ID.Hex(),
+		OrderNumber:   orderLabel(order),
+		RecipientName: order.CustomerName,
+		Phone:         order.CustomerPhone,
+		Address:       order.Address,
+		Note:          order.Note,
+	}
+	if a := order.ShippingAddress; a != nil {
+		req.ProvinceCode = a.ProvinceCode
+		req.DistrictCode = a.DistrictCode
+		req.WardCode = a.WardCode
+	}
+	if order.PaymentStatus != PaymentPaid {
+		req.CODAmount = int64(math.Round(order.Total))
+	}
+
+	config, err := loadShippingConfig(ctx)
+	if err != nil {
+		config = defaultShippingConfig()
+	}
+	req.WeightGrams = orderWeight(config, order.Items)
+
+	for _, item := range order.Items {
+		name := item.Name
+		if variant := itemVariant(item); variant != "" {
+			name += " (" + variant + ")"
+		}
+		req.Items = append(req.Items, carrier.ShipmentItem{Name: name, Qty: item.Qty})
+	}
+	return req
+}
+
+// loadOrderParam - Đọc đơn hàng theo {id} trên URL, tự trả lỗi nếu không có
+func loadOrderParam(ctx context.Context, w http.ResponseWriter, r *http.Request) (Order, bool) {
+	var order Order
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ID đơn hàng không hợp lệ"})
+		return order, false
+	}
+	if err := database.DB.Collection("orders").FindOne(ctx, bson.M{"_id": objectID}).Decode(&order); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy đơn hàng"})
+		return order, false
+	}
+	return order, true
+}
+
+// CreateShipment - Admin tạo vận đơn trên hãng cho đơn đã xác nhận ({"carrier":"fake"})
+func CreateShipment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Carrier string `json:"carrier"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	c, ok := carrierFor(req.Carrier)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Hãng vận chuyển không được hỗ trợ", "code": "UNKNOWN_CARRIER"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	order, ok := loadOrderParam(ctx, w, r)
+	if !ok {
+		return
+	}
+	if order.Status.Normalize() != StatusConfirmed {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Chỉ tạo vận đơn cho đơn hàng đã xác nhận",
+			"code":  "ORDER_NOT_READY_TO_SHIP",
+		})
+		return
+	}
+
+	// Giữ chỗ vận đơn trước khi gọi hãng: 2 admin bấm cùng lúc thì chỉ 1 người tạo được
+	now := time.Now()
+	orders := database.DB.Collection("orders")
+	result, err := orders.UpdateOne(ctx,
+		bson.M{"_id": order.ID, "shipment": nil},
+		bson.M{"$set": bson.M{"shipment": ShipmentInfo{
+			Carrier: c.Name(), Status: shipmentCreating, CreatedAt: now, UpdatedAt: now,
+		}}},
+	)
+	if err != nil || result.MatchedCount == 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Đơn hàng đã có vận đơn",
+			"code":  "SHIPMENT_EXISTS",
+		})
+		return
+	}
+
+	shipmentReq := shipmentRequest(ctx, order)
+	created, err := c.CreateShipment(ctx, shipmentReq)
+	if err != nil {
+		log.Printf("❌ Create shipment for order %s failed: %v\n", orderLabel(order), err)
+		orders.UpdateOne(ctx, bson.M{"_id": order.ID, "shipment.status": shipmentCreating}, bson.M{"$unset": bson.M{"shipment": ""}})
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Không thể tạo vận đơn trên hãng vận chuyển",
+			"code":  "CARRIER_ERROR",
+		})
+		return
+	}
+
+	info := ShipmentInfo{
+		Carrier:      c.Name(),
+		TrackingCode: created.TrackingCode,
+		Status:       carrier.StatusCreated,
+		Fee:          float64(created.Fee),
+		CODAmount:    float64(shipmentReq.CODAmount),
+		CreatedAt:    now,
+		UpdatedAt:    time.Now(),
+	}
+	var updated Order
+	err = orders.FindOneAndUpdate(ctx,
+		bson.M{"_id": order.ID},
+		bson.M{"$set": bson.M{"shipment": info, "updatedAt": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		log.Printf("❌ Shipment %s created but not saved on order %s: %v\n", created.TrackingCode, orderLabel(order), err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể lưu vận đơn"})
+		return
+	}
+
+	writeAudit(ctx, r, "order.shipment_create", "order", order.ID.Hex(), map[string]interface{}{
+		"carrier":      info.Carrier,
+		"trackingCode": info.TrackingCode,
+	})
+	log.Printf("✅ Shipment %s (%s) created for order %s\n", info.TrackingCode, info.Carrier, orderLabel(order))
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(updated)
+}
+
+// GetShipmentLabel - Admin tải nhãn vận đơn để in
+func GetShipmentLabel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	order, ok := loadOrderParam(ctx, w, r)
+	if !ok {
+		return
+	}
+
+	s := order.Shipment
+	if s == nil || s.TrackingCode == "" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Đơn hàng chưa có vận đơn"})
+		return
+	}
+	c, ok := carrierFor(s.Carrier)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Hãng vận chuyển không được hỗ trợ", "code": "UNKNOWN_CARRIER"})
+		return
+	}
+
+	label, err := c.GetLabel(ctx, s.TrackingCode)
+	if err != nil {
+		log.Printf("❌ Get label %s failed: %v\n", s.TrackingCode, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể lấy nhãn vận đơn", "code": "CARRIER_ERROR"})
+		return
+	}
+
+	if label.ContentType == "" {
+		label.ContentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", label.ContentType)
+	w.Write(label.Data)
+}
+
+// cancelCarrierShipment - Hủy vận đơn trên hãng và gỡ khỏi đơn hàng
+func cancelCarrierShipment(ctx context.Context, order Order) error {
+	s := order.Shipment
+	if s == nil || s.TrackingCode == "" {
+		return nil
+	}
+	if c, ok := carrierFor(s.Carrier); ok {
+		if err := c.CancelShipment(ctx, s.TrackingCode); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	_, err := database.DB.Collection("orders").UpdateOne(ctx,
+		bson.M{"_id": order.ID, "shipment.trackingCode": s.TrackingCode},
+		bson.M{
+			"$unset": bson.M{"shipment": ""},
+			"$set":   bson.M{"updatedAt": now},
+			"$push": bson.M{"trackingEvents": TrackingEvent{
+				EventID:      s.TrackingCode + "-cancelled",
+				Carrier:      s.Carrier,
+				TrackingCode: s.TrackingCode,
+				Status:       carrier.StatusCancelled,
+				Description:  "Shop hủy vận đơn",
+				At:           now,
+				ReceivedAt:   now,
+			}},
+		},
+	)
+	return err
+}
+
+// CancelShipment - Admin hủy vận đơn (chỉ khi hãng chưa lấy hàng)
+func CancelShipment(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	order, ok := loadOrderParam(ctx, w, r)
+	if !ok {
+		return
+	}
+	if order.Shipment == nil || order.Shipment.TrackingCode == "" {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Đơn hàng chưa có vận đơn"})
+		return
+	}
+
+	if err := cancelCarrierShipment(ctx, order); err != nil {
+		if err == carrier.ErrNotCancellable {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Hãng đã lấy hàng, không thể hủy vận đơn",
+				"code":  "SHIPMENT_NOT_CANCELLABLE",
+			})
+			return
+		}
+		log.Printf("❌ Cancel shipment %s failed: %v\n", order.Shipment.TrackingCode, err)
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể hủy vận đơn", "code": "CARRIER_ERROR"})
+		return
+	}
+
+	writeAudit(ctx, r, "order.shipment_cancel", "order", order.ID.Hex(), map[string]interface{}{
+		"carrier":      order.Shipment.Carrier,
+		"trackingCode": order.Shipment.TrackingCode,
+	})
+	log.Printf("✅ Shipment %s cancelled for order %s\n", order.Shipment.TrackingCode, orderLabel(order))
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Đã hủy vận đơn",
+	})
+}
+
+// applyTrackingEvent - Lưu sự kiện hành trình lên đơn (bỏ qua sự kiện trùng) và đẩy trạng thái đơn theo vận đơn
+func applyTrackingEvent(ctx context.Context, carrierName string, e carrier.TrackingEvent) error {
+	orders := database.DB.Collection("orders")
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	var order Order
+	err := orders.FindOneAndUpdate(ctx,
+		bson.M{
+			"shipment.carrier":       carrierName,
+			"shipment.trackingCode":  e.TrackingCode,
+			"trackingEvents.eventId": bson.M{"$ne": e.EventID},
+		},
+		bson.M{
+			"$set": bson.M{
+				"shipment.status":    e.Status,
+				"shipment.updatedAt": time.Now(),
+				"updatedAt":          time.Now(),
+			},
+			"$push": bson.M{"trackingEvents": TrackingEvent{
+				EventID:      e.EventID,
+				Carrier:      carrierName,
+				TrackingCode: e.TrackingCode,
+				Status:       e.Status,
+				RawStatus:    e.RawStatus,
+				Description:  e.Description,
+				Location:     e.Location,
+				At:           e.At,
+				ReceivedAt:   time.Now(),
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		// Sự kiện đã nhận trước đó: vẫn đẩy trạng thái (lần trước có thể lỗi giữa chừng), chỉ đi tới nên chạy lại an toàn
+		err = orders.FindOne(ctx, bson.M{
+			"shipment.carrier":      carrierName,
+			"shipment.trackingCode": e.TrackingCode,
+		}).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			log.Printf("⚠️ Tracking event for unknown shipment %s/%s\n", carrierName, e.TrackingCode)
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	actor := Actor{ID: "carrier:" + carrierName, Role: "system"}
+	note := e.Description
+	if note == "" {
+		note = e.RawStatus
+	}
+	for _, next := range shipmentOrderPath[e.Status] {
+		if order.Status.Normalize() == next || !order.Status.CanTransitionTo(next) {
+			continue
+		}
+		order, err = transitionOrder(ctx, order.ID, next, actor, note, nil, nil)
+		if err == ErrInvalidTransition {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("✅ Order %s -> %s (tracking %s)\n", orderLabel(order), next, e.TrackingCode)
+	}
+
+	if e.Status == carrier.StatusDeliveryFailed || e.Status == carrier.StatusReturned {
+		log.Printf("⚠️ Shipment %s for order %s: %s\n", e.TrackingCode, orderLabel(order), e.Status)
+	}
+	return nil
+}
+
+// CarrierWebhook - Hãng gửi cập nhật hành trình vận đơn (chữ ký được kiểm tra bởi từng carrier)
+func CarrierWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	c, ok := carrierFor(mux.Vars(r)["carrier"])
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown carrier"})
+		return
+	}
+
+	events, err := c.ParseWebhook(r)
+	if err == carrier.ErrInvalidSignature {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid signature"})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid webhook"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, e := range events {
+		if err := applyTrackingEvent(ctx, c.Name(), e); err != nil {
+			// 5xx để hãng gửi lại; sự kiện đã lưu sẽ được bỏ qua theo eventId
+			log.Println("❌ CarrierWebhook error:", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Could not process event"})
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "received": len(events)})
+}
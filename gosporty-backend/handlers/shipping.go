@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+	"gosporty-backend/locations"
+)
+
+// Phương thức vận chuyển
+const (
+	ShippingStandard = "standard"
+	ShippingExpress  = "express"
+)
+
+// Kiểu tính phí của 1 bảng giá
+const (
+	RateFlat   = "flat"   // Đồng giá
+	RateWeight = "weight" // Theo khối lượng: giá cơ bản + phí mỗi nấc vượt
+)
+
+const shippingConfigID = "default"
+
+var (
+	errUnknownShippingMethod = errors.New("unknown shipping method")
+	errShippingUnavailable   = errors.New("shipping method not available for this address")
+)
+
+// ShippingZone - Vùng giao hàng gồm nhiều tỉnh/thành
+type ShippingZone struct {
+	Code      string   `json:"code" bson:"code"`
+	Name      string   `json:"name" bson:"name"`
+	Provinces []string `json:"provinces" bson:"provinces"` // Mã tỉnh, rỗng với vùng mặc định
+	Default   bool     `json:"default" bson:"default"`     // Vùng dùng cho tỉnh không thuộc vùng nào / địa chỉ cũ
+}
+
+// ShippingRate - Bảng giá của 1 phương thức cho 1 vùng
+type ShippingRate struct {
+	Zone       string  `json:"zone" bson:"zone"`
+	Type       string  `json:"type" bson:"type"`                                 // flat | weight
+	BaseFee    float64 `json:"baseFee" bson:"baseFee"`                           // Phí cho BaseWeight gram đầu
+	BaseWeight int     `json:"baseWeight,omitempty" bson:"baseWeight,omitempty"` // gram
+	StepWeight int     `json:"stepWeight,omitempty" bson:"stepWeight,omitempty"` // gram mỗi nấc vượt
+	StepFee    float64 `json:"stepFee,omitempty" bson:"stepFee,omitempty"`
+	Days       string  `json:"days,omitempty" bson:"days,omitempty"` // Thời gian giao dự kiến, vd: "2-3"
+}
+
+// ShippingMethod - Phương thức vận chuyển (tiêu chuẩn / hỏa tốc)
+type ShippingMethod struct {
+	Code         string         `json:"code" bson:"code"`
+	Name         string         `json:"name" bson:"name"`
+	Enabled      bool           `json:"enabled" bson:"enabled"`
+	FreeShipping bool           `json:"freeShipping" bson:"freeShipping"` // Áp dụng ngưỡng miễn phí vận chuyển
+	Rates        []ShippingRate `json:"rates" bson:"rates"`
+}
+
+// ShippingConfig - Cấu hình phí vận chuyển, admin chỉnh sửa được
+type ShippingConfig struct {
+	ID                    string           `json:"-" bson:"_id"`
+	FreeShippingThreshold float64          `json:"freeShippingThreshold" bson:"freeShippingThreshold"` // 0 = tắt
+	DefaultItemWeight     int              `json:"defaultItemWeight" bson:"defaultItemWeight"`         // gram, cho sản phẩm chưa nhập khối lượng
+	Zones                 []ShippingZone   `json:"zones" bson:"zones"`
+	Methods               []ShippingMethod `json:"methods" bson:"methods"`
+	UpdatedAt             time.Time        `json:"updatedAt" bson:"updatedAt"`
+}
+
+// ShippingOption - Kết quả tính phí cho 1 phương thức
+type ShippingOption struct {
+	Method       string  `json:"method"`
+	Name         string  `json:"name"`
+	Zone         string  `json:"zone"`
+	Fee          float64 `json:"fee"`
+	FreeShipping bool    `json:"freeShipping"` // Được miễn phí nhờ đạt ngưỡng
+	Days         string  `json:"days,omitempty"`
+	Weight       int     `json:"weight"` // Tổng khối lượng (gram)
+}
+
+// defaultShippingConfig - Cấu hình mặc định khi admin chưa cấu hình
+func defaultShippingConfig() ShippingConfig {
+	return ShippingConfig{
+		ID:                    shippingConfigID,
+		FreeShippingThreshold: 500000, // Miễn phí vận chuyển từ 500.000₫
+		DefaultItemWeight:     500,
+		Zones: []ShippingZone{
+			{Code: "metro", Name: "Hà Nội & TP.HCM", Provinces: []string{"01", "79"}},
+			{Code: "city", Name: "Thành phố trực thuộc TW khác", Provinces: []string{"31", "48", "92"}},
+			{Code: "other", Name: "Tỉnh/thành khác", Default: true},
+		},
+		Methods: []ShippingMethod{
+			{
+				Code: ShippingStandard, Name: "Giao hàng tiêu chuẩn", Enabled: true, FreeShipping: true,
+				Rates: []ShippingRate{
+					{Zone: "metro", Type: RateWeight, BaseFee: 20000, BaseWeight: 1000, StepWeight: 500, StepFee: 3000, Days: "1-2"},
+					{Zone: "city", Type: RateWeight, BaseFee: 30000, BaseWeight: 1000, StepWeight: 500, StepFee: 5000, Days: "2-4"},
+					{Zone: "other", Type: RateWeight, BaseFee: 35000, BaseWeight: 1000, StepWeight: 500, StepFee: 5000, Days: "3-5"},
+				},
+			},
+			{
+				Code: ShippingExpress, Name: "Giao hàng hỏa tốc", Enabled: true,
+				Rates: []ShippingRate{
+					{Zone: "metro", Type: RateFlat, BaseFee: 45000, Days: "0-1"},
+					{Zone: "city", Type: RateWeight, BaseFee: 60000, BaseWeight: 1000, StepWeight: 500, StepFee: 8000, Days: "1-2"},
+				},
+			},
+		},
+	}
+}
+
+// validate - Kiểm tra cấu hình do admin gửi lên
+func (c *ShippingConfig) validate() error {
+	if c.FreeShippingThreshold < 0 || c.DefaultItemWeight < 0 {
+		return errors.New("Ngưỡng miễn phí và khối lượng mặc định không được âm")
+	}
+
+	zones := map[string]bool{}
+	provinces := map[string]string{}
+	defaults := 0
+	for i := range c.Zones {
+		z := &c.Zones[i]
+		z.Code = strings.TrimSpace(z.Code)
+		if z.Code == "" || zones[z.Code] {
+			return fmt.Errorf("Mã vùng %q bị trống hoặc trùng", z.Code)
+		}
+		zones[z.Code] = true
+		if z.Default {
+			defaults++
+		}
+		for _, code := range z.Provinces {
+			if _, err := locations.Resolve(code, "", ""); err == locations.ErrUnknownProvince {
+				return fmt.Errorf("Vùng %s: mã tỉnh %q không hợp lệ", z.Code, code)
+			}
+			if other, ok := provinces[code]; ok {
+				return fmt.Errorf("Tỉnh %s thuộc cả vùng %s và %s", code, other, z.Code)
+			}
+			provinces[code] = z.Code
+		}
+	}
+	if defaults != 1 {
+		return errors.New("Phải có đúng 1 vùng mặc định")
+	}
+
+	methods := map[string]bool{}
+	for _, m := range c.Methods {
+		if m.Code == "" || methods[m.Code] {
+			return fmt.Errorf("Mã phương thức %q bị trống hoặc trùng", m.Code)
+		}
+		methods[m.Code] = true
+		for _, rate := range m.Rates {
+			if !zones[rate.Zone] {
+				return fmt.Errorf("Phương thức %s: vùng %q không tồn tại", m.Code, rate.Zone)
+			}
+			if rate.Type != RateFlat && rate.Type != RateWeight {
+				return fmt.Errorf("Phương thức %s: kiểu tính phí %q không hợp lệ", m.Code, rate.Type)
+			}
+			if rate.BaseFee < 0 || rate.StepFee < 0 || rate.BaseWeight < 0 || rate.StepWeight < 0 {
+				return fmt.Errorf("Phương thức %s: phí và khối lượng không được âm", m.Code)
+			}
+		}
+	}
+	if len(methods) == 0 {
+		return errors.New("Phải có ít nhất 1 phương thức vận chuyển")
+	}
+
+	return nil
+}
+
+// zoneFor - Vùng giao hàng của 1 tỉnh (tỉnh rỗng -> vùng mặc định)
+func (c ShippingConfig) zoneFor(provinceCode string) string {
+	fallback := ""
+	for _, z := range c.Zones {
+		if z.Default {
+			fallback = z.Code
+		}
+		for _, code := range z.Provinces {
+			if code == provinceCode {
+				return z.Code
+			}
+		}
+	}
+	return fallback
+}
+
+// fee - Phí theo bảng giá cho tổng khối lượng (gram)
+func (rate ShippingRate) fee(weight int) float64 {
+	fee := rate.BaseFee
+	if rate.Type == RateWeight && rate.StepWeight > 0 && weight > rate.BaseWeight {
+		steps := math.Ceil(float64(weight-rate.BaseWeight) / float64(rate.StepWeight))
+		fee += steps * rate.StepFee
+	}
+	return fee
+}
+
+// loadShippingConfig - Đọc cấu hình từ database, chưa có thì dùng mặc định
+func loadShippingConfig(ctx context.Context) (ShippingConfig, error) {
+	var config ShippingConfig
+	err := database.DB.Collection("shipping_config").FindOne(ctx, bson.M{"_id": shippingConfigID}).Decode(&config)
+	if err == mongo.ErrNoDocuments {
+		return defaultShippingConfig(), nil
+	}
+	return config, err
+}
+
+// orderWeight - Tổng khối lượng các dòng hàng (gram)
+func orderWeight(config ShippingConfig, items []OrderItem) int {
+	total := 0
+	for _, item := range items {
+		weight := item.Weight
+		if weight <= 0 {
+			weight = config.DefaultItemWeight
+		}
+		total += weight * item.Qty
+	}
+	return total
+}
+
+// shippingOptions - Tính phí cho mọi phương thức khả dụng tới tỉnh provinceCode
+func shippingOptions(config ShippingConfig, items []OrderItem, merchandise float64, provinceCode string) []ShippingOption {
+	zone := config.zoneFor(provinceCode)
+	weight := orderWeight(config, items)
+	free := config.FreeShippingThreshold > 0 && merchandise >= config.FreeShippingThreshold
+
+	options := []ShippingOption{}
+	for _, m := range config.Methods {
+		if !m.Enabled {
+			continue
+		}
+		for _, rate := range m.Rates {
+			if rate.Zone != zone {
+				continue
+			}
+			option := ShippingOption{
+				Method: m.Code,
+				Name:   m.Name,
+				Zone:   zone,
+				Fee:    rate.fee(weight),
+				Days:   rate.Days,
+				Weight: weight,
+			}
+			if m.FreeShipping && free {
+				option.Fee = 0
+				option.FreeShipping = true
+			}
+			options = append(options, option)
+			break
+		}
+	}
+	return options
+}
+
+// quoteShipping - Tính phí cho phương thức đã chọn (mặc định: tiêu chuẩn)
+func quoteShipping(ctx context.Context, items []OrderItem, merchandise float64, provinceCode, method string) (ShippingOption, error) {
+	config, err := loadShippingConfig(ctx)
+	if err != nil {
+		return ShippingOption{}, err
+	}
+
+	if method == "" {
+		method = ShippingStandard
+	}
+	known := false
+	for _, m := range config.Methods {
+		if m.Code == method && m.Enabled {
+			known = true
+		}
+	}
+	if !known {
+		return ShippingOption{}, errUnknownShippingMethod
+	}
+
+	for _, option := range shippingOptions(config, items, merchandise, provinceCode) {
+		if option.Method == method {
+			return option, nil
+		}
+	}
+	return ShippingOption{}, errShippingUnavailable
+}
+
+// applyShipping - Cộng phí vận chuyển vào bảng giá
+func applyShipping(breakdown *PriceBreakdown, option ShippingOption) {
+	breakdown.ShippingFee = option.Fee
+	breakdown.recalc()
+}
+
+// writeShippingError - Trả lỗi khi không tính được phí vận chuyển
+func writeShippingError(w http.ResponseWriter, err error) {
+	switch err {
+	case errUnknownShippingMethod:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Phương thức vận chuyển không hợp lệ", "code": "INVALID_SHIPPING_METHOD"})
+	case errShippingUnavailable:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Phương thức vận chuyển không hỗ trợ địa chỉ này", "code": "SHIPPING_UNAVAILABLE"})
+	default:
+		log.Println("❌ Shipping quote error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không thể tính phí vận chuyển"})
+	}
+}
+
+// QuoteShipping - Báo phí vận chuyển theo giỏ hàng và địa chỉ
+// Body: { items?, shippingAddress?, provinceCode?, addressId? } - không gửi items thì dùng giỏ hàng hiện tại
+func QuoteShipping(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req struct {
+		Items           []OrderItem      `json:"items"`
+		ShippingAddress *ShippingAddress `json:"shippingAddress"`
+		ProvinceCode    string           `json:"provinceCode"`
+		AddressID       string           `json:"addressId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Tỉnh nhận hàng: ưu tiên địa chỉ trong sổ địa chỉ, rồi địa chỉ gửi kèm
+	provinceCode := req.ProvinceCode
+	if req.ShippingAddress != nil {
+		provinceCode = req.ShippingAddress.ProvinceCode
+	}
+	if req.AddressID != "" {
+		userID, _ := GetUserIDFromContext(r)
+		saved, err := findUserAddress(ctx, userID, req.AddressID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy địa chỉ giao hàng"})
+			return
+		}
+		provinceCode = saved.ProvinceCode
+	}
+	if provinceCode != "" {
+		if _, err := locations.Resolve(provinceCode, "", ""); err == locations.ErrUnknownProvince {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Tỉnh/thành phố không hợp lệ"})
+			return
+		}
+	}
+
+	// Không gửi items -> lấy từ giỏ hàng hiện tại
+	items := req.Items
+	if len(items) == 0 {
+		if owner, ok := getCartOwner(r); ok {
+			var cart Cart
+			if err := cartCollection.FindOne(ctx, owner.filter()).Decode(&cart); err == nil {
+				for _, ci := range cart.Items {
+					items = append(items, OrderItem{
+						ProductID:     ci.ProductID,
+						Qty:           ci.Qty,
+						SelectedColor: ci.SelectedColor,
+						SelectedSize:  ci.SelectedSize,
+					})
+				}
+			}
+		}
+	}
+	if len(items) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Giỏ hàng trống"})
+		return
+	}
+
+	applyItemDefaults(items)
+	priced, breakdown, err := priceOrderItems(ctx, items)
+	if err != nil {
+		writePricingError(w, err)
+		return
+	}
+
+	config, err := loadShippingConfig(ctx)
+	if err != nil {
+		writeShippingError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"provinceCode":          provinceCode,
+		"merchandise":           breakdown.Subtotal - breakdown.Discount,
+		"freeShippingThreshold": config.FreeShippingThreshold,
+		"options":               shippingOptions(config, priced, breakdown.Subtotal-breakdown.Discount, provinceCode),
+	})
+}
+
+// GetShippingConfig - Admin xem cấu hình phí vận chuyển
+func GetShippingConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	config, err := loadShippingConfig(ctx)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to load shipping config"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(config)
+}
+
+// UpdateShippingConfig - Admin thay toàn bộ cấu hình phí vận chuyển
+func UpdateShippingConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var config ShippingConfig
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+	if err := config.validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	config.ID = shippingConfigID
+	config.UpdatedAt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := database.DB.Collection("shipping_config").ReplaceOne(ctx,
+		bson.M{"_id": shippingConfigID}, config, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Println("❌ UpdateShippingConfig error:", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to update shipping config"})
+		return
+	}
+
+	writeAudit(ctx, r, "shipping.update_config", "shipping_config", shippingConfigID, nil)
+
+	log.Println("✅ Shipping config updated")
+	json.NewEncoder(w).Encode(config)
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import "testing"
+
+func TestShippingRateFee(t *testing.T) {
+	weightRate := ShippingRate{Type: RateWeight, BaseFee: 20000, BaseWeight: 1000, StepWeight: 500, StepFee: 3000}
+	tests := []struct {
+		name   string
+		rate   ShippingRate
+		weight int
+		want   float64
+	}{
+		{"flat", ShippingRate{Type: RateFlat, BaseFee: 45000}, 5000, 45000},
+		{"empty cart", weightRate, 0, 20000},
+		{"within base", weightRate, 1000, 20000},
+		{"one gram over", weightRate, 1001, 23000},
+		{"one step", weightRate, 1500, 23000},
+		{"partial second step", weightRate, 1501, 26000},
+		{"several steps", weightRate, 3000, 32000},
+		{"no step weight", ShippingRate{Type: RateWeight, BaseFee: 30000, BaseWeight: 1000}, 5000, 30000},
+	}
+	for _, tt := range tests {
+		if got := tt.rate.fee(tt.weight); got != tt.want {
+			t.Errorf("%s: fee(%d) = %v, want %v", tt.name, tt.weight, got, tt.want)
+		}
+	}
+}
+
+func TestShippingZoneFor(t *testing.T) {
+	config := defaultShippingConfig()
+	tests := []struct {
+		province, want string
+	}{
+		{"01", "metro"},
+		{"79", "metro"},
+		{"48", "city"},
+		{"38", "other"},
+		{"", "other"},
+	}
+	for _, tt := range tests {
+		if got := config.zoneFor(tt.province); got != tt.want {
+			t.Errorf("zoneFor(%q) = %q, want %q", tt.province, got, tt.want)
+		}
+	}
+}
+
+func TestOrderWeight(t *testing.T) {
+	config := ShippingConfig{DefaultItemWeight: 500}
+	tests := []struct {
+		name  string
+		items []OrderItem
+		want  int
+	}{
+		{"empty", nil, 0},
+		{"with weight", []OrderItem{{Weight: 300, Qty: 2}}, 600},
+		{"default weight", []OrderItem{{Qty: 3}}, 1500},
+		{"mixed", []OrderItem{{Weight: 1200, Qty: 1}, {Qty: 2}}, 2200},
+	}
+	for _, tt := range tests {
+		if got := orderWeight(config, tt.items); got != tt.want {
+			t.Errorf("%s: orderWeight = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShippingOptions(t *testing.T) {
+	config := defaultShippingConfig()
+	oneKilo := []OrderItem{{Weight: 1000, Qty: 1}}
+	twoKilo := []OrderItem{{Weight: 1000, Qty: 2}}
+
+	type want struct {
+		method string
+		fee    float64
+		free   bool
+	}
+	tests := []struct {
+		name        string
+		items       []OrderItem
+		merchandise float64
+		province    string
+		want        []want
+	}{
+		{"metro", oneKilo, 200000, "01", []want{{ShippingStandard, 20000, false}, {ShippingExpress, 45000, false}}},
+		{"metro heavy", twoKilo, 200000, "79", []want{{ShippingStandard, 26000, false}, {ShippingExpress, 45000, false}}},
+		{"city", oneKilo, 200000, "48", []want{{ShippingStandard, 30000, false}, {ShippingExpress, 60000, false}}},
+		{"other has no express", oneKilo, 200000, "38", []want{{ShippingStandard, 35000, false}}},
+		{"free standard only", oneKilo, 500000, "01", []want{{ShippingStandard, 0, true}, {ShippingExpress, 45000, false}}},
+		{"just under threshold", oneKilo, 499999, "01", []want{{ShippingStandard, 20000, false}, {ShippingExpress, 45000, false}}},
+	}
+	for _, tt := range tests {
+		got := shippingOptions(config, tt.items, tt.merchandise, tt.province)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d options, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, w := range tt.want {
+			if got[i].Method != w.method || got[i].Fee != w.fee || got[i].FreeShipping != w.free {
+				t.Errorf("%s: option %d = %s %v free=%v, want %s %v free=%v",
+					tt.name, i, got[i].Method, got[i].Fee, got[i].FreeShipping, w.method, w.fee, w.free)
+			}
+		}
+	}
+
+	config.FreeShippingThreshold = 0
+	if got := shippingOptions(config, oneKilo, 10000000, "01"); got[0].FreeShipping {
+		t.Error("free shipping applied with threshold disabled")
+	}
+}
+
+func TestShippingConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(c *ShippingConfig)
+		wantErr bool
+	}{
+		{"default", func(c *ShippingConfig) {}, false},
+		{"negative threshold", func(c *ShippingConfig) { c.FreeShippingThreshold = -1 }, true},
+		{"duplicate zone", func(c *ShippingConfig) { c.Zones[1].Code = "metro" }, true},
+		{"no default zone", func(c *ShippingConfig) { c.Zones[2].Default = false }, true},
+		{"province in two zones", func(c *ShippingConfig) { c.Zones[1].Provinces = append(c.Zones[1].Provinces, "01") }, true},
+		{"unknown rate zone", func(c *ShippingConfig) { c.Methods[0].Rates[0].Zone = "moon" }, true},
+		{"unknown rate type", func(c *ShippingConfig) { c.Methods[0].Rates[0].Type = "distance" }, true},
+		{"negative fee", func(c *ShippingConfig) { c.Methods[0].Rates[0].StepFee = -1 }, true},
+		{"no methods", func(c *ShippingConfig) { c.Methods = nil }, true},
+	}
+	for _, tt := range tests {
+		config := defaultShippingConfig()
+		tt.modify(&config)
+		if err := config.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validate() = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gosporty-backend/database"
+	"gosporty-backend/models"
+)
+
+// StockError - Một hoặc nhiều dòng sản phẩm không đủ tồn kho
+type StockError struct {
+	Items []ItemError `json:"items"`
+}
+
+func (e *StockError) Error() string {
+	msgs := make([]string, 0, len(e.Items))
+	for _, item := range e.Items {
+		msgs = append(msgs, item.Error())
+	}
+	return "insufficient stock: " + strings.Join(msgs, ", ")
+}
+
+// reserveStock - Trừ tồn kho cho từng dòng bằng $inc có điều kiện stock >= qty.
+// Sản phẩm có variant thì trừ trên đúng variant (màu × size) và cả tổng stock.
+// Nếu bất kỳ dòng nào thiếu hàng thì hoàn lại các dòng đã trừ và trả về StockError.
+func reserveStock(ctx context.Context, items []OrderItem) error {
+	coll := database.DB.Collection("products")
+	reserved := make([]OrderItem, 0, len(items))
+	stockErr := &StockError{}
+
+	for _, item := range items {
+		objectID, err := primitive.ObjectIDFromHex(item.ProductID)
+		if err != nil {
+			stockErr.Items = append(stockErr.Items, ItemError{ProductID: item.ProductID, Message: "ID sản phẩm không hợp lệ"})
+			continue
+		}
+
+		var product models.Product
+		if err := coll.FindOne(ctx, bson.M{"_id": objectID}).Decode(&product); err != nil {
+			stockErr.Items = append(stockErr.Items, ItemError{ProductID: item.ProductID, Message: "Sản phẩm không tồn tại"})
+			continue
+		}
+
+		filter := bson.M{"_id": objectID, "stock": bson.M{"$gte": item.Qty}}
+		update := bson.M{"$inc": bson.M{"stock": -item.Qty, "stockVersion": 1}}
+		if len(product.Variants) > 0 {
+			filter = bson.M{
+				"_id": objectID,
+				"variants": bson.M{"$elemMatch": bson.M{
+					"color": item.SelectedColor,
+					"size":  item.SelectedSize,
+					"stock": bson.M{"$gte": item.Qty},
+				}},
+			}
+			update = bson.M{"$inc": bson.M{"variants.$.stock": -item.Qty, "stock": -item.Qty, "stockVersion": 1}}
+		}
+
+		result, err := coll.UpdateOne(ctx, filter, update)
+		if err != nil {
+			releaseStock(ctx, reserved)
+			return err
+		}
+
+		if result.ModifiedCount == 0 {
+			stockErr.Items = append(stockErr.Items, ItemError{
+				ProductID: item.ProductID,
+				Message:   "Sản phẩm \"" + product.Name + "\" " + variantLabel(item) + "không đủ hàng (còn " + strconv.Itoa(availableStock(product, item)) + ")",
+			})
+			continue
+		}
+
+		reserved = append(reserved, item)
+	}
+
+	if len(stockErr.Items) > 0 {
+		releaseStock(ctx, reserved)
+		return stockErr
+	}
+
+	return nil
+}
+
+// variantLabel - Mô tả phân loại trong thông báo lỗi
+func variantLabel(item OrderItem) string {
+	if item.SelectedColor == "Mặc định" && item.SelectedSize == "One Size" {
+		return ""
+	}
+	return "(" + item.SelectedColor + " / " + item.SelectedSize + ") "
+}
+
+// availableStock - Tồn kho hiện có của dòng sản phẩm để hiển thị trong thông báo lỗi
+func availableStock(product models.Product, item OrderItem) int {
+	if len(product.Variants) > 0 {
+		if variant := product.FindVariant(item.SelectedColor, item.SelectedSize); variant != nil {
+			return variant.Stock
+		}
+		return 0
+	}
+	return product.Stock
+}
+
+// releaseStock - Cộng lại tồn kho (khi hủy đơn hoặc tạo đơn thất bại)
+func releaseStock(ctx context.Context, items []OrderItem) {
+	coll := database.DB.Collection("products")
+
+	for _, item := range items {
+		objectID, err := primitive.ObjectIDFromHex(item.ProductID)
+		if err != nil {
+			continue
+		}
+
+		// Ưu tiên hoàn về đúng variant, nếu sản phẩm không có variant thì hoàn vào stock tổng
+		result, err := coll.UpdateOne(ctx,
+			bson.M{"_id": objectID, "variants": bson.M{"$elemMatch": bson.M{
+				"color": item.SelectedColor,
+				"size":  item.SelectedSize,
+			}}},
+			bson.M{"$inc": bson.M{"variants.$.stock": item.Qty, "stock": item.Qty, "stockVersion": 1}},
+		)
+		if err == nil && result.MatchedCount == 0 {
+			_, err = coll.UpdateOne(ctx,
+				bson.M{"_id": objectID},
+				bson.M{"$inc": bson.M{"stock": item.Qty, "stockVersion": 1}},
+			)
+		}
+		if err != nil {
+			log.Printf("❌ Failed to release stock for %s (qty %d): %v\n", item.ProductID, item.Qty, err)
+		}
+	}
+}
+
+// writeStockError - Trả lỗi tồn kho theo từng dòng sản phẩm
+func writeStockError(w http.ResponseWriter, err error) {
+	if stockErr, ok := err.(*StockError); ok {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": "Một số sản phẩm không đủ hàng",
+			"code":  "INSUFFICIENT_STOCK",
+			"items": stockErr.Items,
+		})
+		return
+	}
+
+	log.Println("❌ Stock reservation error:", err)
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Không thể giữ hàng cho đơn hàng",
+	})
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"gosporty-backend/database"
+	"gosporty-backend/models"
+)
+
+// buildSKU - Sinh SKU mặc định từ slug + màu + size
+func buildSKU(slug, color, size string) string {
+	parts := []string{slug, color, size}
+	for i, part := range parts {
+		part = strings.ToUpper(strings.TrimSpace(part))
+		parts[i] = strings.Join(strings.Fields(part), "-")
+	}
+	return strings.Join(parts, "-")
+}
+
+// normalizeVariants - Kiểm tra ma trận variant và đồng bộ Colors/Sizes/Stock của sản phẩm
+func normalizeVariants(p *models.Product) error {
+	if len(p.Variants) == 0 {
+		return nil
+	}
+
+	seenCombos := map[string]bool{}
+	seenSKUs := map[string]bool{}
+	colors := []string{}
+	sizes := []string{}
+	seenColors := map[string]bool{}
+	seenSizes := map[string]bool{}
+	total := 0
+
+	for i := range p.Variants {
+		v := &p.Variants[i]
+		v.Color = strings.TrimSpace(v.Color)
+		v.Size = strings.TrimSpace(v.Size)
+		v.SKU = strings.TrimSpace(v.SKU)
+
+		if v.Color == "" || v.Size == "" {
+			return errors.New("Mỗi phân loại phải có màu và size")
+		}
+		if v.Stock < 0 {
+			return fmt.Errorf("Tồn kho của %s / %s không hợp lệ", v.Color, v.Size)
+		}
+		if v.Price < 0 {
+			return fmt.Errorf("Giá của %s / %s không hợp lệ", v.Color, v.Size)
+		}
+
+		combo := v.Color + "|" + v.Size
+		if seenCombos[combo] {
+			return fmt.Errorf("Phân loại %s / %s bị trùng", v.Color, v.Size)
+		}
+		seenCombos[combo] = true
+
+		if v.SKU == "" {
+			v.SKU = buildSKU(p.Slug, v.Color, v.Size)
+		}
+		if seenSKUs[v.SKU] {
+			return fmt.Errorf("SKU %s bị trùng", v.SKU)
+		}
+		seenSKUs[v.SKU] = true
+
+		if !seenColors[v.Color] {
+			seenColors[v.Color] = true
+			colors = append(colors, v.Color)
+		}
+		if !seenSizes[v.Size] {
+			seenSizes[v.Size] = true
+			sizes = append(sizes, v.Size)
+		}
+		total += v.Stock
+	}
+
+	p.Colors = colors
+	p.Sizes = sizes
+	p.Stock = total
+	return nil
+}
+
+// checkVariantAvailable - Kiểm tra sản phẩm/phân loại tồn tại và còn đủ hàng
+func checkVariantAvailable(ctx context.Context, productID, color, size string, qty int) error {
+	objectID, err := primitive.ObjectIDFromHex(productID)
+	if err != nil {
+		return &ItemError{ProductID: productID, Message: "ID sản phẩm không hợp lệ"}
+	}
+
+	var product models.Product
+	err = database.DB.Collection("products").FindOne(ctx, bson.M{"_id": objectID}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return &ItemError{ProductID: productID, Message: "Sản phẩm không tồn tại"}
+	}
+	if err != nil {
+		return err
+	}
+
+	stock := product.Stock
+	if len(product.Variants) > 0 {
+		variant := product.FindVariant(color, size)
+		if variant == nil {
+			return &ItemError{ProductID: productID, Message: fmt.Sprintf("Phân loại %s / %s không tồn tại", color, size)}
+		}
+		stock = variant.Stock
+	}
+
+	if stock < qty {
+		return &ItemError{ProductID: productID, Message: fmt.Sprintf("Sản phẩm \"%s\" chỉ còn %d", product.Name, stock)}
+	}
+
+	return nil
+}
+
+// UpdateProductVariants - Admin thay thế toàn bộ ma trận variant của sản phẩm.
+// Sản phẩm không có variant: gửi "stock" để đặt lại tồn kho tổng (kiểm kho).
+// Gửi kèm "stockVersion" đã đọc: tồn kho đổi từ lúc đó (có đơn đặt/hủy) thì trả 409 thay vì ghi đè.
+func UpdateProductVariants(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	objectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "ID sản phẩm không hợp lệ"})
+		return
+	}
+
+	var req struct {
+		Variants     []models.ProductVariant `json:"variants"`
+		Stock        *int                    `json:"stock"`
+		StockVersion *int64                  `json:"stockVersion"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Dữ liệu không hợp lệ"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	coll := database.DB.Collection("products")
+
+	var product models.Product
+	if err := coll.FindOne(ctx, bson.M{"_id": objectID}).Decode(&product); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Không tìm thấy sản phẩm"})
+		return
+	}
+
+	if req.StockVersion != nil && *req.StockVersion != product.StockVersion {
+		writeStockChanged(w)
+		return
+	}
+	version := product.StockVersion
+
+	product.Variants = req.Variants
+	if err := normalizeVariants(&product); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	product.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+
+	set := bson.M{
+		"variants":  product.Variants,
+		"updatedAt": product.UpdatedAt,
+	}
+	if len(product.Variants) > 0 {
+		set["colors"] = product.Colors
+		set["sizes"] = product.Sizes
+		set["stock"] = product.Stock
+	} else if req.Stock != nil {
+		if *req.Stock < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Tồn kho không hợp lệ"})
+			return
+		}
+		product.Stock = *req.Stock
+		set["stock"] = product.Stock
+	}
+
+	// Điều kiện stockVersion: reserveStock/releaseStock chạy xen giữa lúc đọc và ghi thì không ghi đè
+	filter := bson.M{"_id": objectID, "stockVersion": version}
+	if version == 0 {
+		filter["stockVersion"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	result, err := coll.UpdateOne(ctx, filter, bson.M{"$set": set, "$inc": bson.M{"stockVersion": 1}})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if result.MatchedCount == 0 {
+		writeStockChanged(w)
+		return
+	}
+	product.StockVersion = version + 1
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Cập nhật phân loại thành công",
+		"product": product,
+	})
+}
+
+// writeStockChanged - Tồn kho đã thay đổi từ lúc admin mở form, cần tải lại
+func writeStockChanged(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": "Tồn kho vừa thay đổi (có đơn hàng mới hoặc bị hủy), vui lòng tải lại",
+		"code":  "STOCK_CHANGED",
+	})
+}
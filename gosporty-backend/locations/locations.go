@@ -0,0 +1,204 @@
+package locations
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// Bộ dữ liệu đơn vị hành chính Việt Nam (mã theo danh mục của Tổng cục Thống kê).
+// Bản nhúng có đủ 63 tỉnh/thành; quận/huyện và phường/xã chỉ có cho một số
+// tỉnh/thành. Có thể thay bằng bộ đầy đủ qua biến môi trường LOCATIONS_FILE.
+//
+//go:embed data/vn_units.json
+var embeddedData []byte
+
+var (
+	ErrUnknownProvince = errors.New("unknown province")
+	ErrUnknownDistrict = errors.New("unknown district")
+	ErrUnknownWard     = errors.New("unknown ward")
+)
+
+// Ward - Phường/xã/thị trấn
+type Ward struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// District - Quận/huyện/thị xã
+type District struct {
+	Code  string `json:"code"`
+	Name  string `json:"name"`
+	Wards []Ward `json:"wards,omitempty"`
+}
+
+// Province - Tỉnh/thành phố trực thuộc trung ương
+type Province struct {
+	Code      string     `json:"code"`
+	Name      string     `json:"name"`
+	Districts []District `json:"districts,omitempty"`
+}
+
+// Resolved - Kết quả tra cứu 1 địa chỉ theo mã
+type Resolved struct {
+	ProvinceCode string
+	ProvinceName string
+	DistrictCode string
+	DistrictName string
+	WardCode     string
+	WardName     string
+}
+
+type dataset struct {
+	Version   string     `json:"version"`
+	Provinces []Province `json:"provinces"`
+
+	provinceByCode map[string]*Province
+	districtByCode map[string]*District
+	districtParent map[string]string // mã quận -> mã tỉnh
+	wardByCode     map[string]*Ward
+	wardParent     map[string]string // mã phường -> mã quận
+}
+
+var (
+	loadOnce sync.Once
+	data     *dataset
+)
+
+func parse(raw []byte) (*dataset, error) {
+	var ds dataset
+	if err := json.Unmarshal(raw, &ds); err != nil {
+		return nil, err
+	}
+	if len(ds.Provinces) == 0 {
+		return nil, errors.New("dataset has no provinces")
+	}
+
+	ds.provinceByCode = map[string]*Province{}
+	ds.districtByCode = map[string]*District{}
+	ds.districtParent = map[string]string{}
+	ds.wardByCode = map[string]*Ward{}
+	ds.wardParent = map[string]string{}
+
+	for i := range ds.Provinces {
+		p := &ds.Provinces[i]
+		ds.provinceByCode[p.Code] = p
+		for j := range p.Districts {
+			d := &p.Districts[j]
+			ds.districtByCode[d.Code] = d
+			ds.districtParent[d.Code] = p.Code
+			for k := range d.Wards {
+				ds.wardByCode[d.Wards[k].Code] = &d.Wards[k]
+				ds.wardParent[d.Wards[k].Code] = d.Code
+			}
+		}
+	}
+	return &ds, nil
+}
+
+// load - Đọc dữ liệu 1 lần: ưu tiên LOCATIONS_FILE, lỗi thì dùng bản nhúng
+func load() *dataset {
+	loadOnce.Do(func() {
+		if path := os.Getenv("LOCATIONS_FILE"); path != "" {
+			raw, err := os.ReadFile(path)
+			if err == nil {
+				data, err = parse(raw)
+			}
+			if err == nil {
+				log.Printf("✅ Locations loaded from %s (%d provinces)\n", path, len(data.Provinces))
+				return
+			}
+			log.Println("⚠️ Could not load LOCATIONS_FILE, using embedded dataset:", err)
+		}
+
+		var err error
+		data, err = parse(embeddedData)
+		if err != nil {
+			panic(fmt.Sprintf("locations: invalid embedded dataset: %v", err))
+		}
+	})
+	return data
+}
+
+// Provinces - Danh sách tỉnh/thành (không kèm quận/huyện)
+func Provinces() []Province {
+	ds := load()
+	list := make([]Province, 0, len(ds.Provinces))
+	for _, p := range ds.Provinces {
+		list = append(list, Province{Code: p.Code, Name: p.Name})
+	}
+	return list
+}
+
+// Districts - Danh sách quận/huyện của 1 tỉnh (không kèm phường/xã)
+func Districts(provinceCode string) ([]District, error) {
+	p, ok := load().provinceByCode[provinceCode]
+	if !ok {
+		return nil, ErrUnknownProvince
+	}
+	list := make([]District, 0, len(p.Districts))
+	for _, d := range p.Districts {
+		list = append(list, District{Code: d.Code, Name: d.Name})
+	}
+	return list, nil
+}
+
+// Wards - Danh sách phường/xã của 1 quận/huyện
+func Wards(districtCode string) ([]Ward, error) {
+	d, ok := load().districtByCode[districtCode]
+	if !ok {
+		return nil, ErrUnknownDistrict
+	}
+	list := make([]Ward, len(d.Wards))
+	copy(list, d.Wards)
+	return list, nil
+}
+
+// Resolve - Kiểm tra bộ mã tỉnh/quận/phường có khớp nhau và trả về tên đầy đủ.
+// Tỉnh luôn bắt buộc; quận/phường chỉ được kiểm tra chặt khi bộ dữ liệu có
+// danh sách con cho cấp cha tương ứng.
+func Resolve(provinceCode, districtCode, wardCode string) (Resolved, error) {
+	ds := load()
+	res := Resolved{ProvinceCode: provinceCode, DistrictCode: districtCode, WardCode: wardCode}
+
+	p, ok := ds.provinceByCode[provinceCode]
+	if !ok {
+		return res, ErrUnknownProvince
+	}
+	res.ProvinceName = p.Name
+
+	if districtCode == "" {
+		return res, ErrUnknownDistrict
+	}
+	if len(p.Districts) == 0 {
+		return res, nil
+	}
+	d, ok := ds.districtByCode[districtCode]
+	if !ok || ds.districtParent[districtCode] != provinceCode {
+		return res, ErrUnknownDistrict
+	}
+	res.DistrictName = d.Name
+
+	if wardCode == "" {
+		return res, ErrUnknownWard
+	}
+	if len(d.Wards) == 0 {
+		return res, nil
+	}
+	wd, ok := ds.wardByCode[wardCode]
+	if !ok || ds.wardParent[wardCode] != districtCode {
+		return res, ErrUnknownWard
+	}
+	res.WardName = wd.Name
+
+	return res, nil
+}
+
+// Version - Phiên bản bộ dữ liệu đang dùng
+func Version() string {
+	return load().Version
+}
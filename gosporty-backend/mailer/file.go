@@ -0,0 +1,50 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileMailer - Ghi mỗi email thành 1 file .eml trong Dir (dùng khi dev/test)
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.seq++
+	seq := m.seq
+	m.mu.Unlock()
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%s-%04d-%s.eml", time.Now().Format("20060102-150405"), seq, recipient)
+	return os.WriteFile(filepath.Join(m.Dir, name), buildMIME(m.From, msg), 0o644)
+}
+
+// StdoutMailer - In email ra stdout (mặc định khi chạy local)
+type StdoutMailer struct {
+	From string
+}
+
+func (m *StdoutMailer) Send(ctx context.Context, msg Message) error {
+	line := strings.Repeat("-", 60)
+	fmt.Printf("%s\n📧 To: %s\n📧 Subject: %s\n\n%s\n%s\n", line, msg.To, msg.Subject, msg.Text, line)
+	return nil
+}
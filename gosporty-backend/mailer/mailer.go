@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+)
+
+// Message - 1 email cần gửi
+type Message struct {
+	To      string
+	Subject string
+	Text    string // Nội dung dạng text
+	HTML    string // Nội dung HTML (không bắt buộc)
+}
+
+// Mailer - Interface gửi email, cho phép thay SMTP bằng file/stdout khi dev và test
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv - Chọn mailer theo MAIL_DRIVER: "smtp", "file" hoặc "stdout" (mặc định)
+func FromEnv() Mailer {
+	switch os.Getenv("MAIL_DRIVER") {
+	case "smtp":
+		port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if port == 0 {
+			port = 587
+		}
+		log.Println("📧 Mailer: SMTP", os.Getenv("SMTP_HOST"))
+		return &SMTPMailer{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     fromAddress(),
+		}
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		log.Println("📧 Mailer: file drop in", dir)
+		return &FileMailer{Dir: dir, From: fromAddress()}
+	default:
+		log.Println("📧 Mailer: stdout")
+		return &StdoutMailer{From: fromAddress()}
+	}
+}
+
+func fromAddress() string {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "GoSporty <no-reply@gosporty.local>"
+	}
+	return from
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 
+	"gosporty-backend/carrier"
 	"gosporty-backend/database"
 	"gosporty-backend/handlers"
+	"gosporty-backend/mailer"
 	"gosporty-backend/middlewares"
+	"gosporty-backend/notify"
+	"gosporty-backend/payment"
+	"gosporty-backend/scheduler"
 )
 
 func main() {
@@ -20,6 +29,35 @@ func main() {
 
 	// Initialize cart collection
 	handlers.InitCartCollection(database.DB)
+	handlers.InitOrderCollection(database.DB)
+	handlers.InitSessionCollection(database.DB)
+	handlers.InitUserTokenCollection(database.DB)
+	handlers.InitLoginAttemptCollection(database.DB)
+	handlers.InitAddressCollection(database.DB)
+	handlers.InitAuditCollection(database.DB)
+	handlers.InitCouponCollection(database.DB)
+	handlers.InitReturnCollection(database.DB)
+	handlers.InitShipmentIndexes(database.DB)
+	middlewares.InitIdempotencyCollection(database.DB)
+
+	// Mailer (MAIL_DRIVER=smtp|file|stdout)
+	appMailer := mailer.FromEnv()
+	handlers.SetMailer(appMailer)
+
+	// Email thông báo đơn hàng: xếp hàng trong MongoDB, gửi bởi job send-notifications
+	notifications := notify.NewQueue(database.DB, appMailer)
+	initCtx, initCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	notifications.Init(initCtx)
+	initCancel()
+	handlers.SetNotificationQueue(notifications)
+	handlers.SetPaymentProviders(payment.FromEnv())
+	handlers.SetCarriers(carrier.FromEnv())
+
+	// Background jobs (lock trong MongoDB -> chỉ 1 instance chạy mỗi lượt)
+	jobs := scheduler.New(database.DB)
+	jobs.Register("expire-unpaid-orders", handlers.OrderExpiryInterval, handlers.ExpireUnpaidOrders)
+	jobs.Register("send-notifications", notifyInterval(), notifications.Process)
+	jobs.Start()
 
 	// Create router
 	r := mux.NewRouter()
@@ -30,8 +68,15 @@ func main() {
 	// ============ PUBLIC ROUTES (NO AUTH) ============
 
 	// Auth
-	api.HandleFunc("/register", handlers.RegisterHandler).Methods("POST", "OPTIONS")
+	api.HandleFunc("/register", middlewares.Idempotent(handlers.RegisterHandler)).Methods("POST", "OPTIONS")
 	api.HandleFunc("/login", handlers.LoginHandler).Methods("POST", "OPTIONS")
+	api.HandleFunc("/token/refresh", handlers.RefreshTokenHandler).Methods("POST", "OPTIONS")
+	api.HandleFunc("/logout", handlers.LogoutHandler).Methods("POST", "OPTIONS")
+	api.HandleFunc("/logout/all", middlewares.VerifyJWT(handlers.LogoutAllHandler)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/password/forgot", handlers.ForgotPasswordHandler).Methods("POST", "OPTIONS")
+	api.HandleFunc("/password/reset", handlers.ResetPasswordHandler).Methods("POST", "OPTIONS")
+	api.HandleFunc("/email/verify", handlers.VerifyEmailHandler).Methods("POST", "OPTIONS")
+	api.HandleFunc("/email/resend", handlers.ResendVerificationHandler).Methods("POST", "OPTIONS")
 
 	// Products (Public)
 	api.HandleFunc("/products", handlers.GetProducts).Methods("GET", "OPTIONS")
@@ -41,39 +86,87 @@ func main() {
 
 	// ============ CART ROUTES (Optional Auth) ============
 	api.HandleFunc("/cart", middlewares.OptionalAuthMiddleware(handlers.GetCart)).Methods("GET", "OPTIONS")
-	api.HandleFunc("/cart", middlewares.OptionalAuthMiddleware(handlers.AddToCart)).Methods("POST", "OPTIONS")
-	api.HandleFunc("/cart/update", middlewares.OptionalAuthMiddleware(handlers.UpdateCartItem)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/cart", middlewares.OptionalAuthMiddleware(middlewares.Idempotent(handlers.AddToCart))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/cart/update", middlewares.OptionalAuthMiddleware(middlewares.Idempotent(handlers.UpdateCartItem))).Methods("PUT", "OPTIONS")
 	api.HandleFunc("/cart/remove", middlewares.OptionalAuthMiddleware(handlers.RemoveItem)).Methods("DELETE", "OPTIONS")
 	api.HandleFunc("/cart/clear", middlewares.OptionalAuthMiddleware(handlers.ClearCart)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/cart/coupon", middlewares.OptionalAuthMiddleware(middlewares.Idempotent(handlers.ApplyCartCoupon))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/cart/coupon", middlewares.OptionalAuthMiddleware(handlers.RemoveCartCoupon)).Methods("DELETE", "OPTIONS")
 
 	// ============ ORDER ROUTES (Optional Auth) ============
-	api.HandleFunc("/orders", middlewares.OptionalAuthMiddleware(handlers.GetOrders)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/orders", middlewares.VerifyJWT(handlers.GetOrders)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/orders/quote", handlers.QuoteOrder).Methods("POST", "OPTIONS")
+	api.HandleFunc("/orders/statuses", handlers.GetOrderStatuses).Methods("GET", "OPTIONS")
 	api.HandleFunc("/orders/{id}", middlewares.OptionalAuthMiddleware(handlers.GetOrderByID)).Methods("GET", "OPTIONS")
-	api.HandleFunc("/orders", middlewares.OptionalAuthMiddleware(handlers.CreateOrder)).Methods("POST", "OPTIONS")
-	api.HandleFunc("/orders/{id}/cancel", middlewares.OptionalAuthMiddleware(handlers.CancelOrder)).Methods("PUT", "OPTIONS") // ✅ Thêm dòng này
-	api.HandleFunc("/orders/{id}", middlewares.VerifyJWT(handlers.UpdateOrderStatus)).Methods("PUT", "OPTIONS")
-	api.HandleFunc("/orders/{id}", middlewares.VerifyJWT(handlers.DeleteOrder)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/orders/number/{number}", middlewares.OptionalAuthMiddleware(handlers.GetOrderByNumber)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/orders/{id}/invoice.pdf", middlewares.OptionalAuthMiddleware(handlers.GetOrderInvoice)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/orders", middlewares.OptionalAuthMiddleware(middlewares.Idempotent(handlers.CreateOrder))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/orders/{id}/pay", middlewares.OptionalAuthMiddleware(middlewares.Idempotent(handlers.PayOrder))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/payments/providers", handlers.PaymentProviders).Methods("GET", "OPTIONS")
+	api.HandleFunc("/payments/mock/checkout", handlers.MockCheckout).Methods("GET")
+	api.HandleFunc("/payments/mock/complete", handlers.MockComplete).Methods("POST")
+	api.HandleFunc("/payments/{provider}/return", handlers.PaymentReturn).Methods("GET", "OPTIONS")
+	api.HandleFunc("/payments/{provider}/ipn", middlewares.DedupeCallback(handlers.PaymentIPN)).Methods("GET", "POST")
+	api.HandleFunc("/shipping/{carrier}/webhook", middlewares.DedupeCallback(handlers.CarrierWebhook)).Methods("POST")
+	api.HandleFunc("/orders/{id}/returns", middlewares.OptionalAuthMiddleware(handlers.GetOrderReturns)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/orders/{id}/returns", middlewares.OptionalAuthMiddleware(middlewares.Idempotent(handlers.CreateReturn))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/returns", middlewares.VerifyJWT(handlers.GetMyReturns)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/orders/{id}/cancel", middlewares.OptionalAuthMiddleware(middlewares.Idempotent(handlers.CancelOrder))).Methods("PUT", "OPTIONS") // ✅ Thêm dòng này
+	api.HandleFunc("/orders/{id}", middlewares.RequireRole("admin", middlewares.Idempotent(handlers.UpdateOrderStatus))).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/orders/{id}", middlewares.RequireRole("admin", handlers.DeleteOrder)).Methods("DELETE", "OPTIONS")
 
 	// ============ PROTECTED ROUTES (WITH AUTH) ============
 
-	// Products (Admin only)
-	api.HandleFunc("/admin/products", middlewares.VerifyJWT(handlers.CreateProduct)).Methods("POST", "OPTIONS")
-	api.HandleFunc("/admin/products/{id}", middlewares.VerifyJWT(handlers.UpdateProduct)).Methods("PUT", "OPTIONS")
-	api.HandleFunc("/admin/products/{id}", middlewares.VerifyJWT(handlers.DeleteProduct)).Methods("DELETE", "OPTIONS")
+	// Products (Admin only - RequireRole kiểm tra lại isAdmin trong database)
+	api.HandleFunc("/admin/products", middlewares.RequireRole("admin", handlers.CreateProduct)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/admin/products/export", middlewares.RequireRole("admin", handlers.ExportProducts)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/products/{id}", middlewares.RequireRole("admin", handlers.UpdateProduct)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/admin/products/{id}", middlewares.RequireRole("admin", handlers.DeleteProduct)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/admin/products/{id}/variants", middlewares.RequireRole("admin", handlers.UpdateProductVariants)).Methods("PUT", "OPTIONS")
 
 	// ============ ADMIN ROUTES (Protected) ============
-	api.HandleFunc("/admin/stats", middlewares.VerifyJWT(handlers.GetDashboardStats)).Methods("GET", "OPTIONS")
-	api.HandleFunc("/admin/users", middlewares.VerifyJWT(handlers.GetUsersWithStats)).Methods("GET", "OPTIONS")
-	api.HandleFunc("/admin/orders/recent", middlewares.VerifyJWT(handlers.GetRecentOrders)).Methods("GET", "OPTIONS")
-	api.HandleFunc("/admin/orders", middlewares.VerifyJWT(handlers.GetAllOrders)).Methods("GET", "OPTIONS")
-	api.HandleFunc("/admin/products/top", middlewares.VerifyJWT(handlers.GetTopProducts)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/stats", middlewares.RequireRole("admin", handlers.GetDashboardStats)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/users", middlewares.RequireRole("admin", handlers.GetUsersWithStats)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/users/export", middlewares.RequireRole("admin", handlers.ExportCustomers)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/users/{id}/unlock", middlewares.RequireRole("admin", handlers.UnlockUserLogin)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/admin/users/{id}/role", middlewares.RequireRole("admin", handlers.SetUserRole)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/admin/users/{id}/status", middlewares.RequireRole("admin", handlers.SetUserStatus)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/admin/users/{id}", middlewares.RequireRole("admin", handlers.DeleteUser)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/admin/audit-logs", middlewares.RequireRole("admin", handlers.GetAuditLogs)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/orders/recent", middlewares.RequireRole("admin", handlers.GetRecentOrders)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/orders", middlewares.RequireRole("admin", handlers.GetAllOrders)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/orders/export", middlewares.RequireRole("admin", handlers.ExportOrders)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/products/top", middlewares.RequireRole("admin", handlers.GetTopProducts)).Methods("GET", "OPTIONS")
 
 	// Profile
-	api.HandleFunc("/profile", middlewares.VerifyJWT(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"message": "Welcome to your profile"}`))
-	})).Methods("GET", "OPTIONS")
+	api.HandleFunc("/profile", middlewares.VerifyJWT(handlers.GetProfile)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/profile", middlewares.VerifyJWT(handlers.UpdateProfile)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/profile/password", middlewares.VerifyJWT(middlewares.Idempotent(handlers.ChangePassword))).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/shipping/quote", middlewares.OptionalAuthMiddleware(handlers.QuoteShipping)).Methods("POST", "OPTIONS")
+	api.HandleFunc("/admin/orders/{id}/payment", middlewares.RequireRole("admin", middlewares.Idempotent(handlers.UpdatePaymentStatus))).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/admin/orders/{id}/shipment", middlewares.RequireRole("admin", middlewares.Idempotent(handlers.CreateShipment))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/admin/orders/{id}/shipment", middlewares.RequireRole("admin", handlers.CancelShipment)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/admin/orders/{id}/shipment/label", middlewares.RequireRole("admin", handlers.GetShipmentLabel)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/orders/{id}/packing-slip.pdf", middlewares.RequireRole("admin", handlers.GetPackingSlip)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/returns", middlewares.RequireRole("admin", handlers.GetAllReturns)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/returns/{id}/approve", middlewares.RequireRole("admin", handlers.ApproveReturn)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/admin/returns/{id}/reject", middlewares.RequireRole("admin", handlers.RejectReturn)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/admin/returns/{id}/receive", middlewares.RequireRole("admin", handlers.ReceiveReturn)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/admin/returns/{id}/refund", middlewares.RequireRole("admin", middlewares.Idempotent(handlers.RefundReturn))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/admin/coupons", middlewares.RequireRole("admin", handlers.GetCoupons)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/coupons", middlewares.RequireRole("admin", middlewares.Idempotent(handlers.CreateCoupon))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/admin/coupons/{id}", middlewares.RequireRole("admin", handlers.UpdateCoupon)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/admin/coupons/{id}", middlewares.RequireRole("admin", handlers.DeleteCoupon)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/admin/shipping/config", middlewares.RequireRole("admin", handlers.GetShippingConfig)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/admin/shipping/config", middlewares.RequireRole("admin", handlers.UpdateShippingConfig)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/locations/provinces", handlers.GetProvinces).Methods("GET", "OPTIONS")
+	api.HandleFunc("/locations/provinces/{provinceCode}/districts", handlers.GetDistricts).Methods("GET", "OPTIONS")
+	api.HandleFunc("/locations/districts/{districtCode}/wards", handlers.GetWards).Methods("GET", "OPTIONS")
+	api.HandleFunc("/profile/addresses", middlewares.VerifyJWT(handlers.GetAddresses)).Methods("GET", "OPTIONS")
+	api.HandleFunc("/profile/addresses", middlewares.VerifyJWT(middlewares.Idempotent(handlers.CreateAddress))).Methods("POST", "OPTIONS")
+	api.HandleFunc("/profile/addresses/{addressId}", middlewares.VerifyJWT(handlers.UpdateAddress)).Methods("PUT", "OPTIONS")
+	api.HandleFunc("/profile/addresses/{addressId}", middlewares.VerifyJWT(handlers.DeleteAddress)).Methods("DELETE", "OPTIONS")
+	api.HandleFunc("/profile/addresses/{addressId}/default", middlewares.VerifyJWT(handlers.SetDefaultAddress)).Methods("PUT", "OPTIONS")
 
 	// ============ CORS CONFIGURATION ============
 
@@ -87,7 +180,8 @@ func main() {
 			"http://127.0.0.1:5173",
 		},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "Accept", "Origin", "X-Requested-With"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "Accept", "Origin", "X-Requested-With", handlers.CartTokenHeader, handlers.OrderTokenHeader, middlewares.IdempotencyKeyHeader},
+		ExposedHeaders:   []string{handlers.CartTokenHeader, middlewares.IdempotentReplayedHeader},
 		AllowCredentials: false,
 		Debug:            true,
 	})
@@ -110,9 +204,19 @@ func main() {
 	log.Println("🌐 Public Endpoints:")
 	log.Println("   - POST   /api/register")
 	log.Println("   - POST   /api/login")
+	log.Println("   - POST   /api/token/refresh")
+	log.Println("   - POST   /api/logout")
+	log.Println("   - POST   /api/logout/all (Auth)")
+	log.Println("   - POST   /api/password/forgot")
+	log.Println("   - POST   /api/password/reset")
+	log.Println("   - POST   /api/email/verify")
+	log.Println("   - POST   /api/email/resend")
 	log.Println("   - GET    /api/products")
 	log.Println("   - GET    /api/products/{id}")
 	log.Println("   - GET    /api/products/slug/{slug}")
+	log.Println("   - GET    /api/locations/provinces")
+	log.Println("   - GET    /api/locations/provinces/{provinceCode}/districts")
+	log.Println("   - GET    /api/locations/districts/{districtCode}/wards")
 	log.Println("")
 	log.Println("🛒 Cart Endpoints:")
 	log.Println("   - GET    /api/cart")
@@ -120,26 +224,104 @@ func main() {
 	log.Println("   - PUT    /api/cart/update")
 	log.Println("   - DELETE /api/cart/remove")
 	log.Println("   - DELETE /api/cart/clear")
+	log.Println("   - POST   /api/cart/coupon")
+	log.Println("   - DELETE /api/cart/coupon")
 	log.Println("")
 	log.Println("📦 Order Endpoints:")
-	log.Println("   - GET    /api/orders")
+	log.Println("   - GET    /api/orders (Auth)")
 	log.Println("   - GET    /api/orders/{id}")
+	log.Println("   - GET    /api/orders/number/{number}")
+	log.Println("   - GET    /api/orders/{id}/invoice.pdf")
 	log.Println("   - POST   /api/orders")
-	log.Println("   - PUT    /api/orders/{id} (Auth)")
-	log.Println("   - DELETE /api/orders/{id} (Auth)")
+	log.Println("   - POST   /api/orders/quote")
+	log.Println("   - POST   /api/shipping/quote")
+	log.Println("   - GET    /api/orders/statuses")
+	log.Println("   - POST   /api/orders/{id}/pay")
+	log.Println("   - GET    /api/orders/{id}/returns")
+	log.Println("   - POST   /api/orders/{id}/returns")
+	log.Println("   - GET    /api/returns (Auth)")
+	log.Println("   - GET    /api/payments/providers")
+	log.Println("   - GET    /api/payments/{provider}/return")
+	log.Println("   - POST   /api/payments/{provider}/ipn")
+	log.Println("   - POST   /api/shipping/{carrier}/webhook")
+	log.Println("   - PUT    /api/orders/{id} (Admin)")
+	log.Println("   - DELETE /api/orders/{id} (Admin)")
 	log.Println("")
-	log.Println("🔒 Protected Endpoints (Auth Required):")
+	log.Println("🔒 Admin Endpoints (Role: admin):")
 	log.Println("   - POST   /api/admin/products")
 	log.Println("   - PUT    /api/admin/products/{id}")
 	log.Println("   - DELETE /api/admin/products/{id}")
+	log.Println("   - GET    /api/admin/products/export?format=csv|xlsx")
+	log.Println("   - PUT    /api/admin/products/{id}/variants")
 	log.Println("   - GET    /api/admin/stats")
 	log.Println("   - GET    /api/admin/users")
+	log.Println("   - GET    /api/admin/users/export?format=csv|xlsx")
+	log.Println("   - POST   /api/admin/users/{id}/unlock")
+	log.Println("   - PUT    /api/admin/users/{id}/role")
+	log.Println("   - PUT    /api/admin/users/{id}/status")
+	log.Println("   - DELETE /api/admin/users/{id}")
+	log.Println("   - GET    /api/admin/audit-logs")
 	log.Println("   - GET    /api/admin/orders")
+	log.Println("   - GET    /api/admin/orders/export?format=csv|xlsx")
 	log.Println("   - GET    /api/admin/orders/recent")
+	log.Println("   - PUT    /api/admin/orders/{id}/payment")
+	log.Println("   - POST   /api/admin/orders/{id}/shipment")
+	log.Println("   - DELETE /api/admin/orders/{id}/shipment")
+	log.Println("   - GET    /api/admin/orders/{id}/shipment/label")
+	log.Println("   - GET    /api/admin/orders/{id}/packing-slip.pdf")
+	log.Println("   - GET    /api/admin/returns")
+	log.Println("   - PUT    /api/admin/returns/{id}/approve|reject|receive")
+	log.Println("   - POST   /api/admin/returns/{id}/refund")
+	log.Println("   - GET    /api/admin/coupons")
+	log.Println("   - POST   /api/admin/coupons")
+	log.Println("   - PUT    /api/admin/coupons/{id}")
+	log.Println("   - DELETE /api/admin/coupons/{id}")
+	log.Println("   - GET    /api/admin/shipping/config")
+	log.Println("   - PUT    /api/admin/shipping/config")
+	log.Println("")
+	log.Println("🔑 Auth Required:")
 	log.Println("   - GET    /api/profile")
+	log.Println("   - PUT    /api/profile")
+	log.Println("   - PUT    /api/profile/password")
+	log.Println("   - GET    /api/profile/addresses")
+	log.Println("   - POST   /api/profile/addresses")
+	log.Println("   - PUT    /api/profile/addresses/{addressId}")
+	log.Println("   - DELETE /api/profile/addresses/{addressId}")
+	log.Println("   - PUT    /api/profile/addresses/{addressId}/default")
 	log.Println(line)
 	log.Println("✅ Server started successfully!")
 	log.Println("")
 
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	srv := &http.Server{Addr: ":" + port, Handler: handler}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// Graceful shutdown: dừng nhận request, chờ request và job đang chạy kết thúc
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	log.Println("🛑 Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Println("⚠️ HTTP server shutdown:", err)
+	}
+	if err := jobs.Stop(ctx); err != nil {
+		log.Println("⚠️ Scheduler shutdown:", err)
+	}
+	log.Println("✅ Server stopped")
+}
+
+// notifyInterval - Chu kỳ gửi email trong hàng đợi (NOTIFY_INTERVAL, mặc định 10s)
+func notifyInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("NOTIFY_INTERVAL")); err == nil && d > 0 {
+		return d
+	}
+	return 10 * time.Second
 }
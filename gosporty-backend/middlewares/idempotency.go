@@ -0,0 +1,288 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/database"
+)
+
+// IdempotencyKeyHeader - Client gửi kèm request thay đổi dữ liệu để retry an toàn
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader - Đánh dấu response được phát lại từ lần xử lý trước
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const (
+	idempotencyProcessing = "processing"
+	idempotencyCompleted  = "completed"
+
+	maxIdempotencyKeyLen = 255
+	maxIdempotentBody    = 1 << 20 // 1MB
+)
+
+// idempotencyLock - Request đang xử lý quá thời gian này (server crash) thì cho phép xử lý lại
+const idempotencyLock = 30 * time.Second
+
+var idempotencyTTL = idempotencyTTLFromEnv()
+
+func idempotencyTTLFromEnv() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// IdempotencyRecord - Key đã dùng cùng response gốc, tự xóa khi hết hạn
+type IdempotencyRecord struct {
+	ID          string              `bson:"_id"` // sha256(scope)
+	RequestHash string              `bson:"requestHash"`
+	Status      string              `bson:"status"`
+	LockedUntil time.Time           `bson:"lockedUntil"`
+	StatusCode  int                 `bson:"statusCode,omitempty"`
+	Header      map[string][]string `bson:"header,omitempty"`
+	Body        []byte              `bson:"body,omitempty"`
+	CreatedAt   time.Time           `bson:"createdAt"`
+	ExpiresAt   time.Time           `bson:"expiresAt"`
+}
+
+// InitIdempotencyCollection - TTL index cho idempotency_keys
+func InitIdempotencyCollection(db *mongo.Database) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := db.Collection("idempotency_keys").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create idempotency index:", err)
+	} else {
+		log.Println("✅ Idempotency collection initialized with index")
+	}
+}
+
+func sha256Hex(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter - Ghi response ra client đồng thời giữ lại bản sao để lưu
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+		rw.header = rw.ResponseWriter.Header().Clone()
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// replayHeader - Header được phát lại (bỏ header CORS vì do middleware CORS đặt theo từng request)
+func replayHeader(h http.Header) map[string][]string {
+	out := map[string][]string{}
+	for k, v := range h {
+		if strings.HasPrefix(k, "Access-Control-") || k == "Vary" {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func writeIdempotencyError(w http.ResponseWriter, status int, message, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message, "code": code})
+}
+
+// runIdempotent - Xử lý request 1 lần cho mỗi scope; request lặp lại được phát lại response gốc.
+// requestHash khác với lần đầu -> 422 (key bị dùng lại cho payload khác).
+func runIdempotent(w http.ResponseWriter, r *http.Request, scope, requestHash string, next http.HandlerFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	coll := database.DB.Collection("idempotency_keys")
+	now := time.Now()
+	id := sha256Hex([]byte(scope))
+
+	_, err := coll.InsertOne(ctx, IdempotencyRecord{
+		ID:          id,
+		RequestHash: requestHash,
+		Status:      idempotencyProcessing,
+		LockedUntil: now.Add(idempotencyLock),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(idempotencyTTL),
+	})
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		// Không lưu được key thì vẫn xử lý bình thường (như khi không gửi key)
+		log.Println("⚠️ Idempotency store unavailable:", err)
+		next(w, r)
+		return
+	}
+
+	if err != nil {
+		var existing IdempotencyRecord
+		if err := coll.FindOne(ctx, bson.M{"_id": id}).Decode(&existing); err != nil {
+			writeIdempotencyError(w, http.StatusConflict, "Request đang được xử lý, vui lòng thử lại", "IDEMPOTENCY_IN_PROGRESS")
+			return
+		}
+		if existing.RequestHash != requestHash {
+			writeIdempotencyError(w, http.StatusUnprocessableEntity, "Idempotency-Key đã được dùng cho request khác", "IDEMPOTENCY_KEY_REUSED")
+			return
+		}
+		if existing.Status == idempotencyCompleted {
+			for k, v := range existing.Header {
+				w.Header()[k] = v
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(existing.StatusCode)
+			w.Write(existing.Body)
+			return
+		}
+
+		// Đang xử lý: chỉ tiếp quản khi lock đã hết hạn
+		result, err := coll.UpdateOne(ctx,
+			bson.M{"_id": id, "status": idempotencyProcessing, "lockedUntil": bson.M{"$lt": now}},
+			bson.M{"$set": bson.M{"lockedUntil": now.Add(idempotencyLock)}},
+		)
+		if err != nil || result.ModifiedCount == 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(idempotencyLock.Seconds())))
+			writeIdempotencyError(w, http.StatusConflict, "Request đang được xử lý, vui lòng thử lại", "IDEMPOTENCY_IN_PROGRESS")
+			return
+		}
+	}
+
+	rw := &recordingWriter{ResponseWriter: w}
+	next(rw, r)
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+		rw.header = w.Header().Clone()
+	}
+
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer saveCancel()
+
+	// Lỗi server -> xóa key để client retry được
+	if rw.status >= 500 {
+		coll.DeleteOne(saveCtx, bson.M{"_id": id})
+		return
+	}
+
+	_, err = coll.UpdateOne(saveCtx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":     idempotencyCompleted,
+		"statusCode": rw.status,
+		"header":     replayHeader(rw.header),
+		"body":       rw.body.Bytes(),
+	}})
+	if err != nil {
+		log.Println("⚠️ Failed to store idempotent response:", err)
+	}
+}
+
+// readBody - Đọc body để băm rồi trả lại cho handler
+func readBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil {
+		return nil, true
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+	r.Body.Close()
+	if err != nil || len(body) > maxIdempotentBody {
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// idempotencyOwner - Phạm vi của key: userId, không thì guest cart token / order token (rỗng nếu không có)
+func idempotencyOwner(r *http.Request) string {
+	if userID, _ := r.Context().Value("userId").(string); userID != "" {
+		return userID
+	}
+	if token := strings.TrimSpace(r.Header.Get("X-Cart-Token")); token != "" { // handlers.CartTokenHeader
+		return "guest:" + token
+	}
+	if token := strings.TrimSpace(r.Header.Get("X-Order-Token")); token != "" { // handlers.OrderTokenHeader
+		return "order:" + sha256Hex([]byte(token))
+	}
+	return ""
+}
+
+// Idempotent - Middleware cho request có header Idempotency-Key.
+// Key được tính riêng theo user (hoặc guest token) và endpoint, request không có key hoặc không có owner xử lý như cũ.
+// Đặt bên trong VerifyJWT/OptionalAuthMiddleware để lấy được userId.
+func Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+		if key == "" || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeIdempotencyError(w, http.StatusBadRequest, "Idempotency-Key quá dài", "IDEMPOTENCY_KEY_INVALID")
+			return
+		}
+
+		body, ok := readBody(r)
+		if !ok {
+			writeIdempotencyError(w, http.StatusRequestEntityTooLarge, "Request quá lớn", "REQUEST_TOO_LARGE")
+			return
+		}
+
+		owner := idempotencyOwner(r)
+		if owner == "" {
+			// Khách không có token nào: không có phạm vi riêng -> không phát lại response của người khác
+			next(w, r)
+			return
+		}
+
+		scope := strings.Join([]string{"key", owner, r.Method, r.URL.Path, key}, "|")
+		runIdempotent(w, r, scope, sha256Hex([]byte(r.Method), []byte(r.URL.Path), body), next)
+	}
+}
+
+// DedupeCallback - Chống xử lý lặp callback server-to-server (IPN) không có Idempotency-Key:
+// callback gửi lại y hệt (cùng query + body) được phát lại response lần đầu.
+func DedupeCallback(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := readBody(r)
+		if !ok {
+			writeIdempotencyError(w, http.StatusRequestEntityTooLarge, "Request quá lớn", "REQUEST_TOO_LARGE")
+			return
+		}
+
+		payload := sha256Hex([]byte(r.Method), []byte(r.URL.Path), []byte(r.URL.RawQuery), body)
+		runIdempotent(w, r, "callback|"+payload, payload, next)
+	}
+}
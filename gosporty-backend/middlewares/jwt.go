@@ -68,6 +68,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -84,11 +85,27 @@ func getSecret() string {
 
 // Claims struct
 type Claims struct {
-	UserID string `json:"userId"`
-	Email  string `json:"email"`
+	UserID string   `json:"userId"`
+	Email  string   `json:"email"`
+	Roles  []string `json:"roles,omitempty"`
+
+	TokenVersion int `json:"tv"`
 	jwt.RegisteredClaims
 }
 
+// tokenVersionValid - Token bị vô hiệu khi user "đăng xuất mọi thiết bị" (tokenVersion tăng)
+// hoặc khi tài khoản bị khóa/xóa
+func tokenVersionValid(r *http.Request, claims *Claims) bool {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	user, err := loadAuthUser(ctx, claims.UserID)
+	if err != nil || user.blocked() {
+		return false
+	}
+	return user.TokenVersion == claims.TokenVersion
+}
+
 // VerifyJWT - Middleware xác thực JWT token
 func VerifyJWT(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -127,9 +144,17 @@ func VerifyJWT(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// Token đã bị thu hồi (đăng xuất mọi thiết bị) hoặc user không còn tồn tại
+		if !tokenVersionValid(r, claims) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Token has been revoked"}`))
+			return
+		}
+
 		// Lưu userId vào context
 		ctx := context.WithValue(r.Context(), "userId", claims.UserID)
 		ctx = context.WithValue(ctx, "email", claims.Email)
+		ctx = context.WithValue(ctx, "roles", claims.Roles)
 
 		// Continue với request có userId trong context
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -152,9 +177,10 @@ func OptionalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 					return jwtSecret, nil
 				})
 
-				if err == nil && token.Valid {
+				if err == nil && token.Valid && tokenVersionValid(r, claims) {
 					ctx := context.WithValue(r.Context(), "userId", claims.UserID)
 					ctx = context.WithValue(ctx, "email", claims.Email)
+					ctx = context.WithValue(ctx, "roles", claims.Roles)
 					r = r.WithContext(ctx)
 				}
 			}
@@ -0,0 +1,125 @@
+package middlewares
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"gosporty-backend/database"
+)
+
+// UserRoles - Tính danh sách role của user từ document trong collection "users"
+func UserRoles(isAdmin bool) []string {
+	if isAdmin {
+		return []string{"user", "admin"}
+	}
+	return []string{"user"}
+}
+
+// HasRole - Kiểm tra role có nằm trong danh sách không
+func HasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// authUser - Các trường của user cần cho việc xác thực/phân quyền
+type authUser struct {
+	IsAdmin      bool   `bson:"isAdmin"`
+	TokenVersion int    `bson:"tokenVersion"`
+	Status       string `bson:"status"`
+}
+
+// blocked - Tài khoản bị khóa hoặc đã xóa mềm
+func (u authUser) blocked() bool {
+	return u.Status == "suspended" || u.Status == "deleted"
+}
+
+// loadAuthUser - Đọc thông tin phân quyền của user từ database
+func loadAuthUser(ctx context.Context, userID string) (authUser, error) {
+	var user authUser
+
+	objectID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return user, err
+	}
+
+	err = database.DB.Collection("users").FindOne(ctx, bson.M{"_id": objectID}).Decode(&user)
+	return user, err
+}
+
+// loadUserRoles - Đọc lại role từ database để việc hạ quyền có hiệu lực ngay
+func loadUserRoles(ctx context.Context, userID string) ([]string, error) {
+	user, err := loadAuthUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.blocked() {
+		return []string{}, nil
+	}
+	return UserRoles(user.IsAdmin), nil
+}
+
+// IsAdmin - Kiểm tra user có quyền admin (đọc trực tiếp từ database)
+func IsAdmin(ctx context.Context, userID string) bool {
+	if userID == "" {
+		return false
+	}
+	roles, err := loadUserRoles(ctx, userID)
+	if err != nil {
+		return false
+	}
+	return HasRole(roles, "admin")
+}
+
+// writeForbidden - Trả về lỗi 403 theo format chung cho admin SPA
+func writeForbidden(w http.ResponseWriter, role string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":        "Bạn không có quyền truy cập chức năng này",
+		"code":         "FORBIDDEN",
+		"requiredRole": role,
+	})
+}
+
+// RequireRole - Middleware yêu cầu JWT hợp lệ VÀ user phải có role tương ứng
+// Role trong token chỉ là gợi ý, luôn kiểm tra lại với database
+func RequireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return VerifyJWT(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value("userId").(string)
+		if userID == "" {
+			writeForbidden(w, role)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		defer cancel()
+
+		roles, err := loadUserRoles(ctx, userID)
+		if err != nil {
+			log.Println("❌ RequireRole - cannot load user:", userID, err)
+			writeForbidden(w, role)
+			return
+		}
+
+		if !HasRole(roles, role) {
+			log.Printf("⚠️ RequireRole - user %s missing role %q\n", userID, role)
+			writeForbidden(w, role)
+			return
+		}
+
+		// Ghi đè roles trong context bằng giá trị mới nhất từ database
+		reqCtx := context.WithValue(r.Context(), "roles", roles)
+		next.ServeHTTP(w, r.WithContext(reqCtx))
+	})
+}
@@ -16,15 +16,38 @@ type Product struct {
 	Brand         string             `bson:"brand,omitempty" json:"brand,omitempty"`
 	Slug          string             `bson:"slug" json:"slug"`
 	Stock         int                `bson:"stock" json:"stock"`
+	Weight        int                `bson:"weight,omitempty" json:"weight,omitempty"` // gram, dùng tính phí vận chuyển
 	Colors        []string           `bson:"colors,omitempty" json:"colors,omitempty"`
 	Sizes         []string           `bson:"sizes,omitempty" json:"sizes,omitempty"`
 	Features      []string           `bson:"features,omitempty" json:"features,omitempty"`
+	Variants      []ProductVariant   `bson:"variants,omitempty" json:"variants,omitempty"`
+	StockVersion  int64              `bson:"stockVersion,omitempty" json:"stockVersion"` // Tăng mỗi lần tồn kho đổi, admin gửi lại khi ghi đè tồn kho
 	Rating        float64            `bson:"rating,omitempty" json:"rating,omitempty"`
 	ReviewCount   int                `bson:"reviewCount,omitempty" json:"reviewCount,omitempty"`
 	CreatedAt     primitive.DateTime `bson:"createdAt" json:"createdAt"`
 	UpdatedAt     primitive.DateTime `bson:"updatedAt" json:"updatedAt"`
 }
 
+// ProductVariant - Tồn kho theo từng tổ hợp màu × size
+type ProductVariant struct {
+	SKU   string `bson:"sku" json:"sku"`
+	Color string `bson:"color" json:"color"`
+	Size  string `bson:"size" json:"size"`
+	Stock int    `bson:"stock" json:"stock"`
+	Price int64  `bson:"price,omitempty" json:"price,omitempty"` // ghi đè giá sản phẩm nếu > 0
+	Image string `bson:"image,omitempty" json:"image,omitempty"`
+}
+
+// FindVariant - Tìm variant theo màu và size, trả về nil nếu không có
+func (p *Product) FindVariant(color, size string) *ProductVariant {
+	for i := range p.Variants {
+		if p.Variants[i].Color == color && p.Variants[i].Size == size {
+			return &p.Variants[i]
+		}
+	}
+	return nil
+}
+
 type ProductResponse struct {
 	Products []Product `json:"products"`
 	Page     int       `json:"page"`
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Sự kiện đơn hàng có email thông báo
+const (
+	EventOrderCreated       = "order_created"
+	EventOrderStatusChanged = "order_status_changed"
+	EventOrderCancelled     = "order_cancelled"
+	EventRefundRequired     = "refund_required" // Gửi admin: đơn đã hủy nhưng khách vẫn thanh toán
+)
+
+// Ngôn ngữ email hỗ trợ
+const (
+	LocaleVI = "vi"
+	LocaleEN = "en"
+)
+
+// Locales - Ngôn ngữ có template, phần tử đầu là mặc định
+var Locales = []string{LocaleVI, LocaleEN}
+
+// NormalizeLocale - "en", "en-US", "en-GB,en;q=0.9" -> "en"; còn lại -> "vi"
+func NormalizeLocale(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if i := strings.IndexAny(value, ",;"); i >= 0 {
+		value = value[:i]
+	}
+	if value == LocaleEN || strings.HasPrefix(value, LocaleEN+"-") {
+		return LocaleEN
+	}
+	return LocaleVI
+}
+
+// OrderEmailItem - 1 dòng sản phẩm trong email đơn hàng
+type OrderEmailItem struct {
+	Name      string
+	Variant   string
+	Qty       int
+	Price     float64
+	LineTotal float64
+}
+
+// OrderEmail - Dữ liệu cho template email đơn hàng
+type OrderEmail struct {
+	CustomerName  string
+	OrderNumber   string
+	OrderURL      string
+	Items         []OrderEmailItem
+	Subtotal      float64
+	Discount      float64
+	ShippingFee   float64
+	Total         float64
+	PaymentMethod string
+	PaymentURL    string
+	Address       string
+	StatusKey     string // pending | confirmed | shipping | delivered | completed | cancelled
+	CancelReason  string
+	TrackingCode  string
+	CreatedAt     time.Time
+}
+
+// statusLabels - Tên trạng thái đơn hàng theo ngôn ngữ (key giống mã tiếng Anh của OrderStatus)
+var statusLabels = map[string]map[string]string{
+	LocaleVI: {
+		"pending":   "Chờ xác nhận",
+		"confirmed": "Đã xác nhận",
+		"shipping":  "Đang giao",
+		"delivered": "Đã giao",
+		"completed": "Hoàn thành",
+		"cancelled": "Đã hủy",
+	},
+	LocaleEN: {
+		"pending":   "Pending confirmation",
+		"confirmed": "Confirmed",
+		"shipping":  "Out for delivery",
+		"delivered": "Delivered",
+		"completed": "Completed",
+		"cancelled": "Cancelled",
+	},
+}
+
+func statusLabel(locale, key string) string {
+	if label, ok := statusLabels[locale][key]; ok {
+		return label
+	}
+	return key
+}
+
+// FormatVND - 1250000 -> "1.250.000 ₫"
+func FormatVND(amount float64) string {
+	n := int64(amount + 0.5)
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+
+	digits := fmt.Sprintf("%d", n)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String() + " ₫"
+}
@@ -0,0 +1,199 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"gosporty-backend/mailer"
+)
+
+// Trạng thái email trong hàng đợi
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed" // Hết số lần thử
+)
+
+const (
+	defaultMaxAttempts = 5
+	defaultBatchSize   = 50
+
+	// sendingLock - Email "sending" quá thời gian này (process chết giữa chừng) được gửi lại
+	sendingLock = 2 * time.Minute
+
+	// sentRetention - Email đã gửi được tự xóa sau 30 ngày
+	sentRetention = 30 * 24 * time.Hour
+)
+
+// Notification - 1 email trong hàng đợi, nội dung đã render sẵn lúc sự kiện xảy ra
+type Notification struct {
+	ID            primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Event         string             `json:"event" bson:"event"`
+	Locale        string             `json:"locale" bson:"locale"`
+	To            string             `json:"to" bson:"to"`
+	Subject       string             `json:"subject" bson:"subject"`
+	Text          string             `json:"text" bson:"text"`
+	HTML          string             `json:"html,omitempty" bson:"html,omitempty"`
+	DedupeKey     string             `json:"dedupeKey,omitempty" bson:"dedupeKey,omitempty"`
+	Status        string             `json:"status" bson:"status"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	MaxAttempts   int                `json:"maxAttempts" bson:"maxAttempts"`
+	NextAttemptAt time.Time          `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	LastError     string             `json:"lastError,omitempty" bson:"lastError,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
+	SentAt        *time.Time         `json:"sentAt,omitempty" bson:"sentAt,omitempty"`
+}
+
+// Queue - Hàng đợi email trong MongoDB (collection notifications), gửi qua mailer.Mailer
+type Queue struct {
+	coll        *mongo.Collection
+	mailer      mailer.Mailer
+	MaxAttempts int
+	BatchSize   int
+}
+
+// NewQueue - Hàng đợi lưu trong db, gửi bằng m
+func NewQueue(db *mongo.Database, m mailer.Mailer) *Queue {
+	return &Queue{
+		coll:        db.Collection("notifications"),
+		mailer:      m,
+		MaxAttempts: defaultMaxAttempts,
+		BatchSize:   defaultBatchSize,
+	}
+}
+
+// Init - Index cho hàng đợi
+func (q *Queue) Init(ctx context.Context) {
+	_, err := q.coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "dedupeKey", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{
+				"dedupeKey": bson.M{"$type": "string"},
+			}),
+		},
+		{
+			Keys:    bson.D{{Key: "sentAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(sentRetention.Seconds())),
+		},
+	})
+	if err != nil {
+		log.Println("⚠️ Warning: Could not create notification indexes:", err)
+	} else {
+		log.Println("✅ Notification queue initialized with indexes")
+	}
+}
+
+// Enqueue - Render template rồi đưa vào hàng đợi.
+// dedupeKey (không bắt buộc) chống gửi trùng cho cùng 1 sự kiện, VD: "order_created:<orderId>".
+func (q *Queue) Enqueue(ctx context.Context, to, event, locale, dedupeKey string, data interface{}) error {
+	msg, err := Render(event, locale, data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	_, err = q.coll.InsertOne(ctx, Notification{
+		Event:         event,
+		Locale:        NormalizeLocale(locale),
+		To:            to,
+		Subject:       msg.Subject,
+		Text:          msg.Text,
+		HTML:          msg.HTML,
+		DedupeKey:     dedupeKey,
+		Status:        StatusPending,
+		MaxAttempts:   q.MaxAttempts,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil
+	}
+	return err
+}
+
+// backoff - Chờ trước lần thử tiếp theo: 30s, 2m, 8m, 32m, ... tối đa 6 giờ
+func backoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < 6*time.Hour; i++ {
+		d *= 4
+	}
+	if d > 6*time.Hour {
+		d = 6 * time.Hour
+	}
+	return d
+}
+
+// claim - Lấy 1 email đến hạn gửi và đánh dấu "sending" (nguyên tử, nhiều worker không lấy trùng)
+func (q *Queue) claim(ctx context.Context) (Notification, error) {
+	now := time.Now()
+	var n Notification
+	err := q.coll.FindOneAndUpdate(ctx,
+		bson.M{"$or": []bson.M{
+			{"status": StatusPending, "nextAttemptAt": bson.M{"$lte": now}},
+			{"status": StatusSending, "nextAttemptAt": bson.M{"$lte": now.Add(-sendingLock)}},
+		}},
+		bson.M{
+			"$set": bson.M{"status": StatusSending, "nextAttemptAt": now, "updatedAt": now},
+			"$inc": bson.M{"attempts": 1},
+		},
+		options.FindOneAndUpdate().
+			SetSort(bson.D{{Key: "nextAttemptAt", Value: 1}}).
+			SetReturnDocument(options.After),
+	).Decode(&n)
+	return n, err
+}
+
+// Process - Gửi các email đến hạn (tối đa BatchSize), dùng làm job của scheduler
+func (q *Queue) Process(ctx context.Context) error {
+	sent, failed := 0, 0
+	for i := 0; i < q.BatchSize; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		n, err := q.claim(ctx)
+		if err == mongo.ErrNoDocuments {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		sendErr := q.mailer.Send(ctx, mailer.Message{To: n.To, Subject: n.Subject, Text: n.Text, HTML: n.HTML})
+		if sendErr == nil {
+			q.coll.UpdateOne(ctx, bson.M{"_id": n.ID}, bson.M{
+				"$set":   bson.M{"status": StatusSent, "sentAt": now, "updatedAt": now},
+				"$unset": bson.M{"lastError": ""},
+			})
+			sent++
+			continue
+		}
+
+		set := bson.M{"lastError": sendErr.Error(), "updatedAt": now}
+		if n.Attempts >= n.MaxAttempts {
+			set["status"] = StatusFailed
+			log.Printf("❌ Notification %s to %s failed permanently: %v\n", n.Event, n.To, sendErr)
+		} else {
+			set["status"] = StatusPending
+			set["nextAttemptAt"] = now.Add(backoff(n.Attempts))
+		}
+		q.coll.UpdateOne(ctx, bson.M{"_id": n.ID}, bson.M{"$set": set})
+		failed++
+	}
+
+	if sent > 0 || failed > 0 {
+		log.Printf("📧 Notifications: %d sent, %d failed\n", sent, failed)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"gosporty-backend/mailer"
+)
+
+// Mỗi sự kiện có 2 file cho mỗi ngôn ngữ:
+//   - <event>.<locale>.txt:  {{define "subject"}} và {{define "text"}} (text/template)
+//   - <event>.<locale>.html: {{define "content"}}, được chèn vào layout.<locale>.html (html/template, tự escape)
+//
+//go:embed templates/*
+var templateFS embed.FS
+
+var events = []string{EventOrderCreated, EventOrderStatusChanged, EventOrderCancelled, EventRefundRequired}
+
+type compiled struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// templates - key: event + "." + locale
+var templates = mustParseTemplates()
+
+func templateFuncs(locale string) map[string]interface{} {
+	return map[string]interface{}{
+		"vnd":    FormatVND,
+		"status": func(key string) string { return statusLabel(locale, key) },
+		"date":   func(t time.Time) string { return t.Format("02/01/2006 15:04") },
+	}
+}
+
+func mustParseTemplates() map[string]compiled {
+	out := map[string]compiled{}
+	for _, locale := range Locales {
+		funcs := templateFuncs(locale)
+		for _, event := range events {
+			name := event + "." + locale
+
+			text, err := texttemplate.New(name).Funcs(funcs).ParseFS(templateFS, "templates/"+name+".txt")
+			if err != nil {
+				panic(fmt.Sprintf("notify: parse %s.txt: %v", name, err))
+			}
+			html, err := htmltemplate.New(name).Funcs(funcs).ParseFS(templateFS,
+				"templates/layout."+locale+".html", "templates/"+name+".html")
+			if err != nil {
+				panic(fmt.Sprintf("notify: parse %s.html: %v", name, err))
+			}
+			out[name] = compiled{text: text, html: html}
+		}
+	}
+	return out
+}
+
+// Render - Tạo subject/text/HTML cho 1 sự kiện theo ngôn ngữ (không hỗ trợ thì dùng tiếng Việt)
+func Render(event, locale string, data interface{}) (mailer.Message, error) {
+	locale = NormalizeLocale(locale)
+	tpl, ok := templates[event+"."+locale]
+	if !ok {
+		return mailer.Message{}, fmt.Errorf("notify: no template for %s", event)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := tpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := tpl.html.ExecuteTemplate(&html, "layout", data); err != nil {
+		return mailer.Message{}, err
+	}
+
+	return mailer.Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,137 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Mã kết quả của mock provider (giống VNPay: "00" = thành công)
+const (
+	MockCodeSuccess   = "00"
+	MockCodeCancelled = "24"
+)
+
+// MockProvider - Cổng thanh toán giả lập, ký callback bằng HMAC để chạy toàn bộ luồng offline.
+// Khách được chuyển tới trang checkout giả của chính server (/api/payments/mock/checkout).
+type MockProvider struct {
+	Secret  []byte
+	BaseURL string // URL public của backend
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) CreatePayment(ctx context.Context, req Request) (Result, error) {
+	if req.Amount <= 0 {
+		return Result{}, errors.New("amount must be positive")
+	}
+
+	params := url.Values{}
+	params.Set("orderId", req.OrderID)
+	params.Set("txnRef", req.TransactionRef)
+	params.Set("amount", strconv.FormatInt(req.Amount, 10))
+	params.Set("description", req.Description)
+	params.Set("returnUrl", req.ReturnURL)
+	params.Set("signature", Sign(p.Secret, params))
+
+	return Result{
+		PaymentURL:     p.BaseURL + "/api/payments/mock/checkout?" + params.Encode(),
+		TransactionRef: req.TransactionRef,
+	}, nil
+}
+
+// Complete - Trang checkout giả gọi khi khách bấm thanh toán/hủy: tạo callback đã ký
+func (p *MockProvider) Complete(checkout url.Values, success bool) (url.Values, error) {
+	if !Verify(p.Secret, checkout) {
+		return nil, ErrInvalidSignature
+	}
+
+	code := MockCodeCancelled
+	if success {
+		code = MockCodeSuccess
+	}
+
+	params := url.Values{}
+	params.Set("orderId", checkout.Get("orderId"))
+	params.Set("txnRef", checkout.Get("txnRef"))
+	params.Set("amount", checkout.Get("amount"))
+	params.Set("providerTxnId", "MOCK"+strconv.FormatInt(mockSeq(), 10))
+	params.Set("responseCode", code)
+	params.Set("signature", Sign(p.Secret, params))
+	return params, nil
+}
+
+func (p *MockProvider) VerifyReturn(query url.Values) (Callback, error) {
+	return p.parseCallback(query)
+}
+
+// HandleIPN - Mock gửi IPN dạng form POST (hoặc query string) với cùng tham số như redirect
+func (p *MockProvider) HandleIPN(r *http.Request) (Callback, error) {
+	if err := r.ParseForm(); err != nil {
+		return Callback{}, ErrInvalidCallback
+	}
+	return p.parseCallback(r.Form)
+}
+
+func (p *MockProvider) parseCallback(params url.Values) (Callback, error) {
+	if !Verify(p.Secret, params) {
+		return Callback{}, ErrInvalidSignature
+	}
+
+	amount, err := strconv.ParseInt(params.Get("amount"), 10, 64)
+	if err != nil || params.Get("orderId") == "" || params.Get("txnRef") == "" {
+		return Callback{}, ErrInvalidCallback
+	}
+
+	cb := Callback{
+		OrderID:        params.Get("orderId"),
+		TransactionRef: params.Get("txnRef"),
+		ProviderTxnID:  params.Get("providerTxnId"),
+		Amount:         amount,
+		ResponseCode:   params.Get("responseCode"),
+		Success:        params.Get("responseCode") == MockCodeSuccess,
+	}
+	if !cb.Success {
+		cb.Message = "Khách hủy thanh toán"
+	}
+	return cb, nil
+}
+
+// Refund - Mock hoàn tiền ngay lập tức cho giao dịch đã có mã phía cổng
+func (p *MockProvider) Refund(ctx context.Context, req RefundRequest) (RefundResult, error) {
+	if req.Amount <= 0 {
+		return RefundResult{}, errors.New("amount must be positive")
+	}
+	if req.TransactionRef == "" {
+		return RefundResult{}, errors.New("missing original transaction")
+	}
+
+	return RefundResult{
+		RefundRef:        req.RefundRef,
+		ProviderRefundID: "MOCKRF" + strconv.FormatInt(mockSeq(), 10),
+		Amount:           req.Amount,
+	}, nil
+}
+
+var mockCounter struct {
+	sync.Mutex
+	n int64
+}
+
+// mockSeq - Mã giao dịch tăng dần (theo thời gian) cho mock provider
+func mockSeq() int64 {
+	mockCounter.Lock()
+	defer mockCounter.Unlock()
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	if now <= mockCounter.n {
+		now = mockCounter.n + 1
+	}
+	mockCounter.n = now
+	return now
+}
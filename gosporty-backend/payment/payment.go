@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid payment signature")
+	ErrInvalidCallback  = errors.New("invalid payment callback")
+)
+
+// Request - Yêu cầu tạo giao dịch thanh toán cho 1 đơn hàng
+type Request struct {
+	OrderID        string
+	TransactionRef string // Mã giao dịch phía shop, duy nhất cho mỗi lần thanh toán
+	Amount         int64  // VND
+	Description    string
+	ReturnURL      string // Trang frontend nhận kết quả sau khi thanh toán
+	ClientIP       string
+}
+
+// Result - Kết quả tạo giao dịch: URL chuyển khách sang cổng thanh toán
+type Result struct {
+	PaymentURL     string
+	TransactionRef string
+}
+
+// Callback - Kết quả thanh toán cổng gửi về (qua redirect hoặc IPN), đã kiểm tra chữ ký
+type Callback struct {
+	OrderID        string
+	TransactionRef string
+	ProviderTxnID  string // Mã giao dịch phía cổng thanh toán
+	Amount         int64
+	Success        bool
+	ResponseCode   string
+	Message        string
+}
+
+// Provider - Cổng thanh toán kiểu redirect (VNPay/MoMo): tạo link, kiểm tra redirect, xử lý IPN
+type Provider interface {
+	Name() string
+	CreatePayment(ctx context.Context, req Request) (Result, error)
+	VerifyReturn(query url.Values) (Callback, error)
+	HandleIPN(r *http.Request) (Callback, error)
+}
+
+// RefundRequest - Yêu cầu hoàn tiền (1 phần hoặc toàn bộ) cho giao dịch đã thanh toán
+type RefundRequest struct {
+	OrderID        string
+	TransactionRef string // Mã giao dịch thanh toán gốc
+	ProviderTxnID  string
+	RefundRef      string // Mã hoàn tiền phía shop, duy nhất cho mỗi lần hoàn
+	Amount         int64  // VND
+	Reason         string
+}
+
+// RefundResult - Kết quả hoàn tiền từ cổng thanh toán
+type RefundResult struct {
+	RefundRef        string
+	ProviderRefundID string
+	Amount           int64
+}
+
+// Refunder - Cổng thanh toán hỗ trợ hoàn tiền qua API (không bắt buộc với mọi Provider)
+type Refunder interface {
+	Refund(ctx context.Context, req RefundRequest) (RefundResult, error)
+}
+
+// FromEnv - Chọn các cổng thanh toán theo PAYMENT_PROVIDERS (mặc định: không có, chỉ COD).
+// Cổng "mock" cho phép tự đánh dấu đơn đã thanh toán nên phải bật rõ ràng và có PAYMENT_MOCK_SECRET.
+func FromEnv() []Provider {
+	names := os.Getenv("PAYMENT_PROVIDERS")
+	if names == "" {
+		log.Println("💳 No online payment provider configured (PAYMENT_PROVIDERS)")
+	}
+
+	providers := []Provider{}
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(strings.ToLower(name)) {
+		case "mock":
+			secret := os.Getenv("PAYMENT_MOCK_SECRET")
+			if secret == "" {
+				log.Println("❌ PAYMENT_MOCK_SECRET not set, mock payment provider disabled")
+				continue
+			}
+			baseURL := os.Getenv("PAYMENT_BASE_URL")
+			if baseURL == "" {
+				port := os.Getenv("PORT")
+				if port == "" {
+					port = "10000"
+				}
+				baseURL = "http://localhost:" + port
+			}
+			log.Println("💳 Payment provider: mock")
+			providers = append(providers, &MockProvider{Secret: []byte(secret), BaseURL: strings.TrimRight(baseURL, "/")})
+		case "":
+		default:
+			log.Println("⚠️ Unknown payment provider:", name)
+		}
+	}
+	return providers
+}
+
+// Sign - HMAC-SHA256 (hex) trên các tham số đã sắp xếp theo tên, bỏ qua "signature"
+func Sign(secret []byte, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "signature" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(url.QueryEscape(params.Get(k)))
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(b.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify - Kiểm tra tham số "signature" (so sánh constant-time)
+func Verify(secret []byte, params url.Values) bool {
+	got, err := hex.DecodeString(params.Get("signature"))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	want, _ := hex.DecodeString(Sign(secret, params))
+	return hmac.Equal(got, want)
+}
@@ -0,0 +1,140 @@
+package payment
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var testSecret = []byte("secret")
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name   string
+		params url.Values
+		want   string
+	}{
+		{
+			"sorted keys",
+			url.Values{"txnRef": {"T1"}, "orderId": {"abc"}, "amount": {"150000"}},
+			"497d26b11aafcb4ade145744b08ad4cbff8651ca724e9f0079d8c2e07da7f4dc",
+		},
+		{
+			"signature ignored",
+			url.Values{"txnRef": {"T1"}, "orderId": {"abc"}, "amount": {"150000"}, "signature": {"deadbeef"}},
+			"497d26b11aafcb4ade145744b08ad4cbff8651ca724e9f0079d8c2e07da7f4dc",
+		},
+		{
+			"values escaped",
+			url.Values{"description": {"Áo thun & quần"}},
+			"1c2e47db7059b0469a03d561eb5f44cd8b087b37209c3c41c408083f5bc874fd",
+		},
+	}
+	for _, tt := range tests {
+		if got := Sign(testSecret, tt.params); got != tt.want {
+			t.Errorf("%s: Sign = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	signed := func(modify func(v url.Values)) url.Values {
+		v := url.Values{"orderId": {"abc"}, "txnRef": {"T1"}, "amount": {"150000"}}
+		v.Set("signature", Sign(testSecret, v))
+		modify(v)
+		return v
+	}
+	tests := []struct {
+		name   string
+		secret []byte
+		params url.Values
+		want   bool
+	}{
+		{"valid", testSecret, signed(func(url.Values) {}), true},
+		{"uppercase hex", testSecret, signed(func(v url.Values) { v.Set("signature", strings.ToUpper(v.Get("signature"))) }), true},
+		{"tampered amount", testSecret, signed(func(v url.Values) { v.Set("amount", "1") }), false},
+		{"added param", testSecret, signed(func(v url.Values) { v.Set("responseCode", MockCodeSuccess) }), false},
+		{"wrong secret", []byte("other"), signed(func(url.Values) {}), false},
+		{"missing signature", testSecret, signed(func(v url.Values) { v.Del("signature") }), false},
+		{"not hex", testSecret, signed(func(v url.Values) { v.Set("signature", "zz") }), false},
+		{"truncated", testSecret, signed(func(v url.Values) { v.Set("signature", v.Get("signature")[:10]) }), false},
+	}
+	for _, tt := range tests {
+		if got := Verify(tt.secret, tt.params); got != tt.want {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMockProviderFlow(t *testing.T) {
+	p := &MockProvider{Secret: testSecret, BaseURL: "http://localhost:10000"}
+
+	result, err := p.CreatePayment(context.Background(), Request{
+		OrderID: "abc", TransactionRef: "T1", Amount: 150000, ReturnURL: "http://shop/return",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(result.PaymentURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkout := u.Query()
+
+	tests := []struct {
+		name    string
+		success bool
+		code    string
+	}{
+		{"paid", true, MockCodeSuccess},
+		{"cancelled", false, MockCodeCancelled},
+	}
+	for _, tt := range tests {
+		callback, err := p.Complete(checkout, tt.success)
+		if err != nil {
+			t.Fatalf("%s: Complete: %v", tt.name, err)
+		}
+		cb, err := p.VerifyReturn(callback)
+		if err != nil {
+			t.Fatalf("%s: VerifyReturn: %v", tt.name, err)
+		}
+		if cb.Success != tt.success || cb.ResponseCode != tt.code || cb.Amount != 150000 || cb.OrderID != "abc" {
+			t.Errorf("%s: callback = %+v", tt.name, cb)
+		}
+
+		// Khách sửa responseCode trên URL trả về -> chữ ký không còn khớp
+		callback.Set("responseCode", MockCodeSuccess)
+		if !tt.success {
+			if _, err := p.VerifyReturn(callback); err != ErrInvalidSignature {
+				t.Errorf("%s: forged callback err = %v, want %v", tt.name, err, ErrInvalidSignature)
+			}
+		}
+	}
+
+	checkout.Set("amount", "1")
+	if _, err := p.Complete(checkout, true); err != ErrInvalidSignature {
+		t.Errorf("tampered checkout err = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers string
+		secret    string
+		want      int
+	}{
+		{"none by default", "", "", 0},
+		{"mock without secret", "mock", "", 0},
+		{"mock with secret", "mock", "s3cret", 1},
+		{"unknown", "paypal", "s3cret", 0},
+	}
+	for _, tt := range tests {
+		t.Setenv("PAYMENT_PROVIDERS", tt.providers)
+		t.Setenv("PAYMENT_MOCK_SECRET", tt.secret)
+		if got := len(FromEnv()); got != tt.want {
+			t.Errorf("%s: %d providers, want %d", tt.name, got, tt.want)
+		}
+	}
+}
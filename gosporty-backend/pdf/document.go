@@ -0,0 +1,453 @@
+// Package pdf - Tạo file PDF đơn giản (hóa đơn, phiếu đóng gói) bằng Go thuần, không dùng dịch vụ ngoài.
+//
+// Chữ dùng font DejaVu Sans nhúng sẵn (hỗ trợ tiếng Việt), chỉ nhúng các glyph đã dùng.
+// Tọa độ tính bằng point (1/72 inch), gốc ở góc trên bên trái trang, y tăng dần xuống dưới.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	_ "embed"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+)
+
+// Khổ A4
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// FontStyle - Kiểu chữ
+type FontStyle int
+
+const (
+	Regular FontStyle = iota
+	Bold
+)
+
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	regularTTF []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	boldTTF []byte
+
+	fontsOnce sync.Once
+	fonts     [2]*ttfFont
+	fontsErr  error
+)
+
+// loadFonts - Đọc font nhúng 1 lần cho cả process
+func loadFonts() ([2]*ttfFont, error) {
+	fontsOnce.Do(func() {
+		if fonts[Regular], fontsErr = parseTTF("DejaVuSans", regularTTF); fontsErr != nil {
+			return
+		}
+		fonts[Bold], fontsErr = parseTTF("DejaVuSans-Bold", boldTTF)
+	})
+	return fonts, fontsErr
+}
+
+// Color - Màu RGB (0-255)
+type Color struct {
+	R, G, B uint8
+}
+
+var (
+	Black = Color{0, 0, 0}
+	White = Color{255, 255, 255}
+)
+
+func (c Color) String() string {
+	return fmt.Sprintf("%.3f %.3f %.3f", float64(c.R)/255, float64(c.G)/255, float64(c.B)/255)
+}
+
+// Document - File PDF nhiều trang
+type Document struct {
+	Title   string
+	Author  string
+	Creator string
+
+	fonts [2]*ttfFont
+	used  [2]map[uint16]rune // Glyph đã dùng -> ký tự (cho subset và ToUnicode)
+	pages []*bytes.Buffer
+
+	style     FontStyle
+	size      float64
+	fill      Color
+	stroke    Color
+	lineWidth float64
+}
+
+// New - Document rỗng, chữ mặc định Regular 10pt màu đen
+func New() (*Document, error) {
+	f, err := loadFonts()
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		fonts:     f,
+		used:      [2]map[uint16]rune{{}, {}},
+		size:      10,
+		fill:      Black,
+		stroke:    Black,
+		lineWidth: 0.5,
+	}, nil
+}
+
+// AddPage - Thêm trang mới, các lệnh vẽ sau đó ghi vào trang này
+func (d *Document) AddPage() {
+	page := &bytes.Buffer{}
+	d.pages = append(d.pages, page)
+	fmt.Fprintf(page, "%s rg\n%s RG\n%.2f w\n", d.fill, d.stroke, d.lineWidth)
+}
+
+// PageCount - Số trang hiện có
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// SetFont - Kiểu và cỡ chữ (pt) cho các lệnh Text tiếp theo
+func (d *Document) SetFont(style FontStyle, size float64) {
+	if style != Bold {
+		style = Regular
+	}
+	d.style = style
+	d.size = size
+}
+
+// FontSize - Cỡ chữ hiện tại
+func (d *Document) FontSize() float64 {
+	return d.size
+}
+
+// SetFillColor - Màu chữ và màu tô hình
+func (d *Document) SetFillColor(c Color) {
+	d.fill = c
+	if len(d.pages) > 0 {
+		fmt.Fprintf(d.page(), "%s rg\n", c)
+	}
+}
+
+// SetStrokeColor - Màu đường kẻ
+func (d *Document) SetStrokeColor(c Color) {
+	d.stroke = c
+	if len(d.pages) > 0 {
+		fmt.Fprintf(d.page(), "%s RG\n", c)
+	}
+}
+
+// SetLineWidth - Độ dày đường kẻ (pt)
+func (d *Document) SetLineWidth(width float64) {
+	d.lineWidth = width
+	if len(d.pages) > 0 {
+		fmt.Fprintf(d.page(), "%.2f w\n", width)
+	}
+}
+
+// TextWidth - Độ rộng chuỗi với font hiện tại (pt)
+func (d *Document) TextWidth(s string) float64 {
+	f := d.fonts[d.style]
+	units := 0
+	for _, r := range s {
+		units += f.advance(f.glyph(r))
+	}
+	return float64(units) * d.size / float64(f.unitsPerEm)
+}
+
+// Text - Viết chuỗi tại (x, y), y là đường chân chữ
+func (d *Document) Text(x, y float64, s string) {
+	if s == "" {
+		return
+	}
+
+	f := d.fonts[d.style]
+	var hex strings.Builder
+	for _, r := range s {
+		if r == '\n' || r == '\r' || r == '\t' {
+			r = ' '
+		}
+		gid := f.glyph(r)
+		if _, ok := d.used[d.style][gid]; !ok && gid != 0 {
+			d.used[d.style][gid] = r
+		}
+		fmt.Fprintf(&hex, "%04X", gid)
+	}
+
+	fmt.Fprintf(d.page(), "BT /F%d %.2f Tf %.2f %.2f Td <%s> Tj ET\n",
+		d.style+1, d.size, x, PageHeight-y, hex.String())
+}
+
+// TextRight - Viết chuỗi căn phải tại x
+func (d *Document) TextRight(x, y float64, s string) {
+	d.Text(x-d.TextWidth(s), y, s)
+}
+
+// TextCenter - Viết chuỗi căn giữa tại x
+func (d *Document) TextCenter(x, y float64, s string) {
+	d.Text(x-d.TextWidth(s)/2, y, s)
+}
+
+// Wrap - Chia chuỗi thành các dòng không rộng quá width (ngắt theo khoảng trắng, từ quá dài thì cắt theo ký tự)
+func (d *Document) Wrap(s string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if d.TextWidth(candidate) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			line = ""
+			for _, r := range word {
+				if line != "" && d.TextWidth(line+string(r)) > width {
+					lines = append(lines, line)
+					line = ""
+				}
+				line += string(r)
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Line - Kẻ đoạn thẳng
+func (d *Document) Line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(d.page(), "%.2f %.2f m %.2f %.2f l S\n", x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// Rect - Hình chữ nhật, (x, y) là góc trên bên trái. fill = tô màu nền, stroke = kẻ viền
+func (d *Document) Rect(x, y, w, h float64, fill, stroke bool) {
+	op := "n"
+	switch {
+	case fill && stroke:
+		op = "B"
+	case fill:
+		op = "f"
+	case stroke:
+		op = "S"
+	}
+	fmt.Fprintf(d.page(), "%.2f %.2f %.2f %.2f re %s\n", x, PageHeight-y-h, w, h, op)
+}
+
+// WriteTo - Xuất file PDF
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	pw := &objectWriter{}
+	pw.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	// 1 Catalog, 2 Pages, 3 Info, mỗi font đã dùng 5 object, mỗi trang 2 object
+	const fontObjects = 5
+	usedFonts := []int{}
+	for i := range d.fonts {
+		if len(d.used[i]) > 0 {
+			usedFonts = append(usedFonts, i)
+		}
+	}
+	fontBase := 4
+	pageBase := fontBase + fontObjects*len(usedFonts)
+
+	pw.object(1, "<< /Type /Catalog /Pages 2 0 R >>")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", pageBase+2*i)
+	}
+	pw.object(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	creator := d.Creator
+	if creator == "" {
+		creator = "gosporty-backend"
+	}
+	pw.object(3, fmt.Sprintf("<< /Title %s /Author %s /Creator %s /Producer %s /CreationDate %s >>",
+		textString(d.Title), textString(d.Author), textString(creator), textString("gosporty-backend/pdf"),
+		textString(time.Now().Format("D:20060102150405-07'00'"))))
+
+	fontRefs := []string{}
+	for n, i := range usedFonts {
+		base := fontBase + fontObjects*n
+		fontRefs = append(fontRefs, fmt.Sprintf("/F%d %d 0 R", i+1, base))
+		pw.writeFont(base, d.fonts[i], d.used[i])
+	}
+
+	resources := fmt.Sprintf("<< /Font << %s >> >>", strings.Join(fontRefs, " "))
+	for i, content := range d.pages {
+		pageObj := pageBase + 2*i
+		pw.object(pageObj, fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			PageWidth, PageHeight, resources, pageObj+1))
+		pw.stream(pageObj+1, "", content.Bytes())
+	}
+
+	pw.finish(3)
+	return pw.buf.WriteTo(w)
+}
+
+// Bytes - Nội dung file PDF
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// objectWriter - Ghi object PDF và ghi nhớ vị trí cho bảng xref
+type objectWriter struct {
+	buf     bytes.Buffer
+	offsets map[int]int
+}
+
+func (pw *objectWriter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&pw.buf, format, args...)
+}
+
+func (pw *objectWriter) begin(num int) {
+	if pw.offsets == nil {
+		pw.offsets = map[int]int{}
+	}
+	pw.offsets[num] = pw.buf.Len()
+	pw.printf("%d 0 obj\n", num)
+}
+
+func (pw *objectWriter) object(num int, body string) {
+	pw.begin(num)
+	pw.printf("%s\nendobj\n", body)
+}
+
+// stream - Object stream nén Flate, extra là các key thêm vào dictionary
+func (pw *objectWriter) stream(num int, extra string, data []byte) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+
+	pw.begin(num)
+	pw.printf("<< /Length %d /Filter /FlateDecode%s >>\nstream\n", compressed.Len(), extra)
+	pw.buf.Write(compressed.Bytes())
+	pw.printf("\nendstream\nendobj\n")
+}
+
+// writeFont - Font Type0 (Identity-H) gồm: Type0, CIDFontType2, FontDescriptor, FontFile2, ToUnicode
+func (pw *objectWriter) writeFont(base int, f *ttfFont, used map[uint16]rune) {
+	gids := make([]int, 0, len(used))
+	glyphs := map[uint16]bool{}
+	for gid := range used {
+		gids = append(gids, int(gid))
+		glyphs[gid] = true
+	}
+	sort.Ints(gids)
+
+	// Tiền tố subset 6 chữ in hoa, cố định theo tập glyph
+	hash := sha1.New()
+	for _, gid := range gids {
+		fmt.Fprintf(hash, "%d,", gid)
+	}
+	sum := hash.Sum(nil)
+	tag := make([]byte, 6)
+	for i := range tag {
+		tag[i] = 'A' + sum[i]%26
+	}
+	fontName := string(tag) + "+" + f.name
+
+	scale := func(v int) int { return v * 1000 / f.unitsPerEm }
+
+	widths := []string{}
+	for _, gid := range gids {
+		widths = append(widths, fmt.Sprintf("%d [%d]", gid, scale(f.advance(uint16(gid)))))
+	}
+
+	pw.object(base, fmt.Sprintf(
+		"<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		fontName, base+1, base+4))
+	pw.object(base+1, fmt.Sprintf(
+		"<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /DW %d /W [%s] /CIDToGIDMap /Identity >>",
+		fontName, base+2, scale(f.advance(0)), strings.Join(widths, " ")))
+	pw.object(base+2, fmt.Sprintf(
+		"<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		fontName, scale(f.bbox[0]), scale(f.bbox[1]), scale(f.bbox[2]), scale(f.bbox[3]),
+		scale(f.ascent), scale(f.descent), scale(f.ascent), base+3))
+
+	file := f.subset(glyphs)
+	pw.stream(base+3, fmt.Sprintf(" /Length1 %d", len(file)), file)
+	pw.stream(base+4, "", toUnicodeCMap(gids, used))
+}
+
+// finish - Bảng xref và trailer
+func (pw *objectWriter) finish(info int) {
+	maxObj := 0
+	for num := range pw.offsets {
+		if num > maxObj {
+			maxObj = num
+		}
+	}
+
+	xref := pw.buf.Len()
+	pw.printf("xref\n0 %d\n0000000000 65535 f \n", maxObj+1)
+	for num := 1; num <= maxObj; num++ {
+		pw.printf("%010d 00000 n \n", pw.offsets[num])
+	}
+	pw.printf("trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", maxObj+1, info, xref)
+}
+
+// toUnicodeCMap - Map glyph -> Unicode để copy/tìm kiếm chữ trong PDF
+func toUnicodeCMap(gids []int, used map[uint16]rune) []byte {
+	var b bytes.Buffer
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	// Mỗi khối bfchar tối đa 100 mục
+	for start := 0; start < len(gids); start += 100 {
+		end := start + 100
+		if end > len(gids) {
+			end = len(gids)
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, gid := range gids[start:end] {
+			fmt.Fprintf(&b, "<%04X> <%s>\n", gid, utf16Hex(string(used[uint16(gid)])))
+		}
+		b.WriteString("endbfchar\n")
+	}
+
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.Bytes()
+}
+
+// textString - Chuỗi PDF dạng UTF-16BE có BOM (hiển thị được tiếng Việt trong metadata)
+func textString(s string) string {
+	return "<FEFF" + utf16Hex(s) + ">"
+}
+
+func utf16Hex(s string) string {
+	var b strings.Builder
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// checkStructure - Header, bảng xref trỏ đúng vị trí từng object, trailer và %%EOF
+func checkStructure(t *testing.T, data []byte) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header: %q", data[:16])
+	}
+	if !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("missing EOF marker")
+	}
+
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if m == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(m[1]))
+	header := regexp.MustCompile(`^xref\n0 (\d+)\n`).FindSubmatch(data[xref:])
+	if header == nil {
+		t.Fatalf("startxref %d does not point to xref table", xref)
+	}
+	count, _ := strconv.Atoi(string(header[1]))
+	entries := data[xref+len(header[0]):]
+	if !bytes.HasPrefix(entries, []byte("0000000000 65535 f \n")) {
+		t.Fatal("xref entry 0 is not the free head")
+	}
+	for num := 1; num < count; num++ {
+		entry := entries[20*num : 20*num+20]
+		offset, err := strconv.Atoi(string(entry[:10]))
+		if err != nil {
+			t.Fatalf("xref entry %d: %q", num, entry)
+		}
+		want := fmt.Sprintf("%d 0 obj\n", num)
+		if !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Errorf("xref entry %d points to %q, want %q", num, data[offset:offset+len(want)], want)
+		}
+	}
+	if !bytes.Contains(data, []byte(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R", count))) {
+		t.Error("trailer /Size does not match xref")
+	}
+}
+
+func TestDocumentBytes(t *testing.T) {
+	tests := []struct {
+		name  string
+		draw  func(d *Document)
+		pages int
+		fonts int
+	}{
+		{"empty", func(d *Document) {}, 1, 0},
+		{"regular text", func(d *Document) { d.Text(40, 40, "Hóa đơn bán hàng") }, 1, 1},
+		{"both fonts", func(d *Document) {
+			d.Text(40, 40, "Tạm tính")
+			d.SetFont(Bold, 12)
+			d.Text(40, 60, "Tổng cộng")
+		}, 1, 2},
+		{"shapes only", func(d *Document) {
+			d.Rect(10, 10, 100, 20, true, true)
+			d.Line(10, 40, 200, 40)
+		}, 1, 0},
+		{"three pages", func(d *Document) {
+			for i := 0; i < 3; i++ {
+				d.AddPage()
+				d.Text(40, 40, fmt.Sprintf("Trang %d", i+1))
+			}
+		}, 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			d.Title = "Đơn hàng GS-0001"
+			tt.draw(d)
+
+			data, err := d.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkStructure(t, data)
+
+			if !bytes.Contains(data, []byte(fmt.Sprintf("/Count %d", tt.pages))) {
+				t.Errorf("want /Count %d", tt.pages)
+			}
+			if got := bytes.Count(data, []byte("/Subtype /Type0")); got != tt.fonts {
+				t.Errorf("%d embedded fonts, want %d", got, tt.fonts)
+			}
+			if !bytes.Contains(data, []byte("/Title "+textString(d.Title))) {
+				t.Error("missing /Title in info dictionary")
+			}
+		})
+	}
+}
+
+func TestSubsetChecksum(t *testing.T) {
+	fonts, err := loadFonts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fonts[Regular]
+	used := map[uint16]bool{}
+	for _, r := range "Giày chạy bộ – 1.250.000₫" {
+		used[f.glyph(r)] = true
+	}
+
+	out := f.subset(used)
+	if got := checksum(out); got != 0xB1B0AFBA {
+		t.Errorf("subset checksum = %#x, want 0xB1B0AFBA", got)
+	}
+	if len(out) >= len(regularTTF) {
+		t.Errorf("subset is %d bytes, full font %d bytes", len(out), len(regularTTF))
+	}
+	for _, tag := range []string{"glyf", "head", "hhea", "hmtx", "loca", "maxp"} {
+		if tableOffset(out, tag) == 0 {
+			t.Errorf("subset missing %q table", tag)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	d, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	width := d.TextWidth("aaaa bbbb")
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"fits", "aaaa bbbb", []string{"aaaa bbbb"}},
+		{"breaks on space", "aaaa bbbb cccc", []string{"aaaa bbbb", "cccc"}},
+		{"keeps newlines", "aaaa\nbbbb", []string{"aaaa", "bbbb"}},
+		{"crlf", "aaaa\r\nbbbb", []string{"aaaa", "bbbb"}},
+		{"long word split", "aaaaaaaaaaaaaaaaaaaa", []string{"aaaaaaaa", "aaaaaaaa", "aaaa"}},
+		{"empty", "", []string{""}},
+	}
+	for _, tt := range tests {
+		got := d.Wrap(tt.text, width)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Wrap(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+		for _, line := range got {
+			if d.TextWidth(line) > width {
+				t.Errorf("%s: line %q wider than %v", tt.name, line, width)
+			}
+		}
+	}
+}
+
+func TestTextString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", "<FEFF>"},
+		{"Đơn", "<FEFF011001A1006E>"},
+		{"😀", "<FEFFD83DDE00>"},
+	}
+	for _, tt := range tests {
+		if got := textString(tt.in); got != tt.want {
+			t.Errorf("textString(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var errInvalidFont = errors.New("invalid TrueType font")
+
+// ttfFont - Font TrueType đã đọc sẵn các bảng cần để đo chữ và cắt bớt (subset) khi nhúng vào PDF
+type ttfFont struct {
+	name        string
+	data        []byte
+	tables      map[string][]byte
+	unitsPerEm  int
+	ascent      int
+	descent     int
+	bbox        [4]int
+	numGlyphs   int
+	numHMetrics int
+	advances    []uint16
+	loca        []uint32 // numGlyphs+1 offset trong bảng glyf
+	cmap        map[rune]uint16
+}
+
+// parseTTF - Đọc các bảng head, hhea, maxp, hmtx, loca, cmap của 1 file .ttf
+func parseTTF(name string, data []byte) (*ttfFont, error) {
+	if len(data) < 12 {
+		return nil, errInvalidFont
+	}
+
+	f := &ttfFont{name: name, data: data, tables: map[string][]byte{}}
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(data) {
+			return nil, errInvalidFont
+		}
+		tag := string(data[rec : rec+4])
+		offset := int(binary.BigEndian.Uint32(data[rec+8:]))
+		length := int(binary.BigEndian.Uint32(data[rec+12:]))
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("%w: table %q out of range", errInvalidFont, tag)
+		}
+		f.tables[tag] = data[offset : offset+length]
+	}
+
+	for _, tag := range []string{"head", "hhea", "maxp", "hmtx", "loca", "glyf", "cmap"} {
+		if _, ok := f.tables[tag]; !ok {
+			return nil, fmt.Errorf("%w: missing table %q", errInvalidFont, tag)
+		}
+	}
+
+	head := f.tables["head"]
+	f.unitsPerEm = int(binary.BigEndian.Uint16(head[18:]))
+	for i := range f.bbox {
+		f.bbox[i] = int(int16(binary.BigEndian.Uint16(head[36+2*i:])))
+	}
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+
+	hhea := f.tables["hhea"]
+	f.ascent = int(int16(binary.BigEndian.Uint16(hhea[4:])))
+	f.descent = int(int16(binary.BigEndian.Uint16(hhea[6:])))
+	f.numHMetrics = int(binary.BigEndian.Uint16(hhea[34:]))
+	f.numGlyphs = int(binary.BigEndian.Uint16(f.tables["maxp"][4:]))
+
+	hmtx := f.tables["hmtx"]
+	if f.numHMetrics == 0 || len(hmtx) < 4*f.numHMetrics {
+		return nil, fmt.Errorf("%w: bad hmtx", errInvalidFont)
+	}
+	f.advances = make([]uint16, f.numHMetrics)
+	for i := range f.advances {
+		f.advances[i] = binary.BigEndian.Uint16(hmtx[4*i:])
+	}
+
+	loca := f.tables["loca"]
+	f.loca = make([]uint32, f.numGlyphs+1)
+	for i := range f.loca {
+		if longLoca {
+			if 4*i+4 > len(loca) {
+				return nil, fmt.Errorf("%w: bad loca", errInvalidFont)
+			}
+			f.loca[i] = binary.BigEndian.Uint32(loca[4*i:])
+		} else {
+			if 2*i+2 > len(loca) {
+				return nil, fmt.Errorf("%w: bad loca", errInvalidFont)
+			}
+			f.loca[i] = uint32(binary.BigEndian.Uint16(loca[2*i:])) * 2
+		}
+	}
+
+	cmap, err := parseCmap(f.tables["cmap"])
+	if err != nil {
+		return nil, err
+	}
+	f.cmap = cmap
+	return f, nil
+}
+
+// parseCmap - Bảng Unicode -> glyph: ưu tiên format 12 (3,10), sau đó format 4 (3,1)
+func parseCmap(table []byte) (map[rune]uint16, error) {
+	if len(table) < 4 {
+		return nil, errInvalidFont
+	}
+
+	var format4, format12 []byte
+	numSubtables := int(binary.BigEndian.Uint16(table[2:]))
+	for i := 0; i < numSubtables; i++ {
+		rec := 4 + 8*i
+		if rec+8 > len(table) {
+			return nil, errInvalidFont
+		}
+		platform := binary.BigEndian.Uint16(table[rec:])
+		encoding := binary.BigEndian.Uint16(table[rec+2:])
+		offset := int(binary.BigEndian.Uint32(table[rec+4:]))
+		if platform != 3 || offset+4 > len(table) {
+			continue
+		}
+		sub := table[offset:]
+		switch format := binary.BigEndian.Uint16(sub); {
+		case encoding == 10 && format == 12:
+			format12 = sub
+		case encoding == 1 && format == 4:
+			format4 = sub
+		}
+	}
+
+	m := map[rune]uint16{}
+	switch {
+	case format12 != nil:
+		numGroups := int(binary.BigEndian.Uint32(format12[12:]))
+		if 16+12*numGroups > len(format12) {
+			return nil, errInvalidFont
+		}
+		for i := 0; i < numGroups; i++ {
+			g := format12[16+12*i:]
+			start := binary.BigEndian.Uint32(g)
+			end := binary.BigEndian.Uint32(g[4:])
+			gid := binary.BigEndian.Uint32(g[8:])
+			for c := start; c <= end && c <= 0x10FFFF; c++ {
+				m[rune(c)] = uint16(gid + c - start)
+			}
+		}
+	case format4 != nil:
+		segCount := int(binary.BigEndian.Uint16(format4[6:])) / 2
+		endCodes := 14
+		startCodes := endCodes + 2*segCount + 2
+		idDeltas := startCodes + 2*segCount
+		idRangeOffsets := idDeltas + 2*segCount
+		if idRangeOffsets+2*segCount > len(format4) {
+			return nil, errInvalidFont
+		}
+		for i := 0; i < segCount; i++ {
+			end := int(binary.BigEndian.Uint16(format4[endCodes+2*i:]))
+			start := int(binary.BigEndian.Uint16(format4[startCodes+2*i:]))
+			delta := binary.BigEndian.Uint16(format4[idDeltas+2*i:])
+			rangeOffset := int(binary.BigEndian.Uint16(format4[idRangeOffsets+2*i:]))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var gid uint16
+				if rangeOffset == 0 {
+					gid = uint16(c) + delta
+				} else {
+					pos := idRangeOffsets + 2*i + rangeOffset + 2*(c-start)
+					if pos+2 > len(format4) {
+						continue
+					}
+					if gid = binary.BigEndian.Uint16(format4[pos:]); gid != 0 {
+						gid += delta
+					}
+				}
+				if gid != 0 {
+					m[rune(c)] = gid
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: no Unicode cmap", errInvalidFont)
+	}
+	return m, nil
+}
+
+// glyph - Glyph của 1 ký tự (0 = .notdef nếu font không có)
+func (f *ttfFont) glyph(r rune) uint16 {
+	return f.cmap[r]
+}
+
+// advance - Độ rộng glyph theo đơn vị font
+func (f *ttfFont) advance(gid uint16) int {
+	if int(gid) < len(f.advances) {
+		return int(f.advances[gid])
+	}
+	return int(f.advances[len(f.advances)-1])
+}
+
+// glyphData - Dữ liệu glyph trong bảng glyf
+func (f *ttfFont) glyphData(gid uint16) []byte {
+	if int(gid) >= f.numGlyphs {
+		return nil
+	}
+	glyf := f.tables["glyf"]
+	start, end := f.loca[gid], f.loca[gid+1]
+	if start >= end || int(end) > len(glyf) {
+		return nil
+	}
+	return glyf[start:end]
+}
+
+// components - Các glyph con của 1 glyph ghép (VD: "ế" = "e" + dấu)
+func (f *ttfFont) components(gid uint16) []uint16 {
+	data := f.glyphData(gid)
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil
+	}
+
+	var out []uint16
+	for pos := 10; pos+4 <= len(data); {
+		flags := binary.BigEndian.Uint16(data[pos:])
+		out = append(out, binary.BigEndian.Uint16(data[pos+2:]))
+		pos += 4
+		if flags&0x0001 != 0 { // ARG_1_AND_2_ARE_WORDS
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&0x0008 != 0: // WE_HAVE_A_SCALE
+			pos += 2
+		case flags&0x0040 != 0: // WE_HAVE_AN_X_AND_Y_SCALE
+			pos += 4
+		case flags&0x0080 != 0: // WE_HAVE_A_TWO_BY_TWO
+			pos += 8
+		}
+		if flags&0x0020 == 0 { // MORE_COMPONENTS
+			break
+		}
+	}
+	return out
+}
+
+// subsetTables - Các bảng giữ lại khi nhúng font vào PDF (ISO 32000-1, 9.9)
+var subsetTables = []string{"cvt ", "fpgm", "glyf", "head", "hhea", "hmtx", "loca", "maxp", "prep"}
+
+// subset - File font chỉ chứa các glyph đã dùng. Glyph giữ nguyên ID để dùng CIDToGIDMap /Identity,
+// glyph không dùng để trống, cắt bỏ phần glyph sau ID lớn nhất.
+func (f *ttfFont) subset(used map[uint16]bool) []byte {
+	keep := map[uint16]bool{0: true}
+	queue := []uint16{}
+	for gid := range used {
+		queue = append(queue, gid)
+	}
+	for len(queue) > 0 {
+		gid := queue[0]
+		queue = queue[1:]
+		if keep[gid] || int(gid) >= f.numGlyphs {
+			continue
+		}
+		keep[gid] = true
+		queue = append(queue, f.components(gid)...)
+	}
+
+	numGlyphs := 0
+	for gid := range keep {
+		if int(gid)+1 > numGlyphs {
+			numGlyphs = int(gid) + 1
+		}
+	}
+
+	// glyf + loca (long format)
+	var glyf []byte
+	loca := make([]byte, 4*(numGlyphs+1))
+	for gid := 0; gid < numGlyphs; gid++ {
+		binary.BigEndian.PutUint32(loca[4*gid:], uint32(len(glyf)))
+		if keep[uint16(gid)] {
+			glyf = append(glyf, f.glyphData(uint16(gid))...)
+			for len(glyf)%4 != 0 {
+				glyf = append(glyf, 0)
+			}
+		}
+	}
+	binary.BigEndian.PutUint32(loca[4*numGlyphs:], uint32(len(glyf)))
+
+	// hmtx: numberOfHMetrics cặp (advance, lsb) rồi lsb cho các glyph còn lại
+	numHMetrics := f.numHMetrics
+	if numHMetrics > numGlyphs {
+		numHMetrics = numGlyphs
+	}
+	hmtxLen := 4*numHMetrics + 2*(numGlyphs-numHMetrics)
+	hmtx := append([]byte(nil), f.tables["hmtx"][:hmtxLen]...)
+
+	head := append([]byte(nil), f.tables["head"]...)
+	binary.BigEndian.PutUint32(head[8:], 0) // checksumAdjustment, tính lại bên dưới
+	binary.BigEndian.PutUint16(head[50:], 1)
+
+	hhea := append([]byte(nil), f.tables["hhea"]...)
+	binary.BigEndian.PutUint16(hhea[34:], uint16(numHMetrics))
+
+	maxp := append([]byte(nil), f.tables["maxp"]...)
+	binary.BigEndian.PutUint16(maxp[4:], uint16(numGlyphs))
+
+	tables := map[string][]byte{
+		"glyf": glyf, "loca": loca, "hmtx": hmtx,
+		"head": head, "hhea": hhea, "maxp": maxp,
+	}
+	for _, tag := range subsetTables {
+		if _, ok := tables[tag]; !ok {
+			if data, ok := f.tables[tag]; ok {
+				tables[tag] = data
+			}
+		}
+	}
+
+	out := writeTTF(tables)
+
+	// checksumAdjustment = 0xB1B0AFBA - checksum cả file
+	headOffset := tableOffset(out, "head")
+	binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-checksum(out))
+	return out
+}
+
+// writeTTF - Ghép các bảng thành file font (bảng xếp theo tag, căn 4 byte)
+func writeTTF(tables map[string][]byte) []byte {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	n := len(tags)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= n {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	header := make([]byte, 12+16*n)
+	binary.BigEndian.PutUint32(header, 0x00010000)
+	binary.BigEndian.PutUint16(header[4:], uint16(n))
+	binary.BigEndian.PutUint16(header[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(header[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(header[10:], uint16(n*16-searchRange))
+
+	body := []byte{}
+	for i, tag := range tags {
+		data := tables[tag]
+		rec := header[12+16*i:]
+		copy(rec, tag)
+		binary.BigEndian.PutUint32(rec[4:], checksum(data))
+		binary.BigEndian.PutUint32(rec[8:], uint32(len(header)+len(body)))
+		binary.BigEndian.PutUint32(rec[12:], uint32(len(data)))
+		body = append(body, data...)
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+	}
+	return append(header, body...)
+}
+
+// tableOffset - Vị trí bảng trong file font do writeTTF tạo
+func tableOffset(font []byte, tag string) int {
+	n := int(binary.BigEndian.Uint16(font[4:]))
+	for i := 0; i < n; i++ {
+		rec := font[12+16*i:]
+		if string(rec[:4]) == tag {
+			return int(binary.BigEndian.Uint32(rec[8:]))
+		}
+	}
+	return -1
+}
+
+// checksum - Tổng uint32 big-endian (phần cuối lẻ coi như đệm 0)
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,181 @@
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// JobFunc - Công việc chạy định kỳ, ctx bị hủy khi scheduler dừng
+type JobFunc func(ctx context.Context) error
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      JobFunc
+}
+
+// Scheduler - Chạy job định kỳ trong process. Khi chạy nhiều instance,
+// mỗi lượt của 1 job chỉ do instance đang giữ lock trong MongoDB thực hiện.
+type Scheduler struct {
+	locks *mongo.Collection
+	owner string
+	jobs  []job
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New - Tạo scheduler lưu lock trong db
+func New(db *mongo.Database) *Scheduler {
+	return &Scheduler{
+		locks: db.Collection("scheduler_locks"),
+		owner: instanceID(),
+	}
+}
+
+// instanceID - Định danh instance: hostname-pid-random
+func instanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Register - Đăng ký job, phải gọi trước Start
+func (s *Scheduler) Register(name string, interval time.Duration, run JobFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		log.Printf("⚠️ Scheduler: job %s registered after Start, ignored\n", name)
+		return
+	}
+	s.jobs = append(s.jobs, job{name: name, interval: interval, run: run})
+}
+
+// Start - Chạy các job đã đăng ký trong goroutine riêng
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.started {
+		return
+	}
+	s.started = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(ctx, j)
+		log.Printf("✅ Scheduler: job %s every %s\n", j.name, j.interval)
+	}
+}
+
+// Stop - Dừng nhận lượt mới và chờ job đang chạy kết thúc (hoặc ctx hết hạn)
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("✅ Scheduler stopped")
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	s.tick(ctx, j)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tick(ctx, j)
+		}
+	}
+}
+
+// tick - Chạy 1 lượt nếu giành được lock
+func (s *Scheduler) tick(ctx context.Context, j job) {
+	if !s.acquire(ctx, j) {
+		return
+	}
+
+	// Lượt chạy không được dài hơn thời gian giữ lock
+	runCtx, cancel := context.WithTimeout(ctx, j.interval)
+	defer cancel()
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("❌ Scheduler: job %s panicked: %v\n", j.name, rec)
+		}
+	}()
+
+	if err := j.run(runCtx); err != nil && ctx.Err() == nil {
+		log.Printf("❌ Scheduler: job %s failed: %v\n", j.name, err)
+	}
+}
+
+// acquire - Giữ lock của job trong 1 chu kỳ. Lock hết hạn hoặc đang do chính instance này giữ
+// thì giành được; instance khác giữ thì upsert bị trùng _id -> bỏ qua lượt này.
+func (s *Scheduler) acquire(ctx context.Context, j job) bool {
+	lockCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	_, err := s.locks.UpdateOne(lockCtx,
+		bson.M{
+			"_id": j.name,
+			"$or": []bson.M{
+				{"lockedUntil": bson.M{"$lte": now}},
+				{"owner": s.owner},
+			},
+		},
+		bson.M{"$set": bson.M{
+			"owner":       s.owner,
+			"lockedUntil": now.Add(j.interval),
+			"lastRunAt":   now,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		if !mongo.IsDuplicateKeyError(err) && ctx.Err() == nil {
+			log.Printf("⚠️ Scheduler: could not acquire lock for %s: %v\n", j.name, err)
+		}
+		return false
+	}
+	return true
+}
@@ -20,8 +20,12 @@ import AdminLayout from "./components/AdminLayout";
 import AdminProducts from "./pages/admin/AdminProducts";
 import CheckoutPage from "./pages/CheckoutPage";
 import OrderSuccessPage from "./pages/OrderSuccessPage";
+import PaymentResult from "./pages/PaymentResult";
 import BlogPost from "./pages/BlogPost";
 import OrdersPage from "./pages/OrdersPage";
+import VerifyEmail from "./pages/VerifyEmail";
+import ForgotPassword from "./pages/ForgotPassword";
+import ResetPassword from "./pages/ResetPassword";
 
 function App() {
   return (
@@ -33,6 +37,9 @@ function App() {
           <Route path="/about" element={<About />} />
           <Route path="/login" element={<Login />} />
           <Route path="/register" element={<Register />} />
+          <Route path="/verify-email" element={<VerifyEmail />} />
+          <Route path="/forgot-password" element={<ForgotPassword />} />
+          <Route path="/reset-password" element={<ResetPassword />} />
           <Route path="/product/:id" element={<ProductDetailPage />} />
           <Route path="/cart" element={<CartPage />} />
           <Route path="/blog" element={<Blog />} />
@@ -44,6 +51,7 @@ function App() {
             path="/order-success/:orderId"
             element={<OrderSuccessPage />}
           />
+          <Route path="/payment/result/:provider" element={<PaymentResult />} />
           {/* Admin Routes - Wrapped in AdminLayout */}
           <Route
             path="/admin"
@@ -1,10 +1,12 @@
 import React from "react";
 import { Link, Outlet, useNavigate } from "react-router-dom";
+import { logout } from "../services/api";
 
 const AdminLayout = () => {
   const navigate = useNavigate();
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    await logout();
     localStorage.clear();
     navigate("/login");
   };
@@ -1,13 +1,11 @@
 import React, { useState, useEffect } from "react";
 import { Link } from "react-router-dom";
-import axios from "axios";
+import { productAPI } from "../services/api";
 import { toast } from "react-toastify";
 import { useContext } from "react";
 import { CartContext } from "../context/CartContext";
 import ReactDOM from "react-dom";
 
-const API_URL = process.env.REACT_APP_API_URL;
-
 const ProductQuickView = ({
   product,
   productId: propProductId,
@@ -41,7 +39,7 @@ const ProductQuickView = ({
       setError(null);
 
       console.log("🔍 Fetching product detail for ID:", productId);
-      const response = await axios.get(`${API_URL}/api/products/${productId}`);
+      const response = await productAPI.getById(productId);
 
       console.log("✅ Product detail response:", response.data);
 
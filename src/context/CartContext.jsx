@@ -1,5 +1,5 @@
 import React, { createContext, useState, useEffect } from "react";
-import api from "../services/api";
+import api, { getCartToken } from "../services/api";
 
 const LOCAL_KEY = "gosporty_cart";
 
@@ -14,6 +14,9 @@ export const CartProvider = ({ children }) => {
     return token && token !== "undefined" && token !== "null";
   };
 
+  // Giỏ hàng nằm trên server: user đã login hoặc khách đã có guest cart token
+  const hasServerCart = () => isLoggedIn() || !!getCartToken();
+
   // Lấy cart từ localStorage
   const getLocalCart = () => {
     const data = localStorage.getItem(LOCAL_KEY);
@@ -78,8 +81,8 @@ export const CartProvider = ({ children }) => {
   // Init load cart
   useEffect(() => {
     const init = async () => {
-      if (isLoggedIn()) {
-        // User đã login -> fetch từ server
+      if (hasServerCart()) {
+        // User đã login hoặc khách có guest cart token -> fetch từ server
         try {
           setLoading(true);
           const res = await api.get("/cart");
@@ -99,7 +102,7 @@ export const CartProvider = ({ children }) => {
           setLoading(false);
         }
       } else {
-        // User chưa login -> dùng localStorage
+        // Khách chưa có giỏ trên server -> dùng localStorage
         const local = getLocalCart();
         console.log("📦 Cart from localStorage:", local);
         setCart(local);
@@ -126,33 +129,33 @@ export const CartProvider = ({ children }) => {
       actualSize 
     });
 
-    if (isLoggedIn()) {
-      // ✅ User đã login -> Gọi API
-      try {
-        const res = await api.post("/cart", {
-          productId: actualProductId,
-          qty: actualQty,
-          selectedColor: actualColor,
-          selectedSize: actualSize,
-          price: price || 0,
-          name: name || "Sản phẩm",
-          image: image || "",
-        });
+    // ✅ Gọi API cả khi chưa login: server cấp guest cart token ở lần thêm đầu tiên
+    try {
+      const res = await api.post("/cart", {
+        productId: actualProductId,
+        qty: actualQty,
+        selectedColor: actualColor,
+        selectedSize: actualSize,
+        price: price || 0,
+        name: name || "Sản phẩm",
+        image: image || "",
+      });
 
-        console.log("✅ Added to server cart:", res.data);
-        setCart(res.data);
-        return res.data;
+      console.log("✅ Added to server cart:", res.data);
+      setCart(res.data);
 
-      } catch (err) {
-        console.error("❌ Add to cart API failed:", err.message);
-        console.error("Error response:", err.response?.status, err.response?.data);
-        
-        // ✅ FIX: Luôn fallback về localStorage khi API thất bại
-        console.log("⚠️ Falling back to localStorage");
-        return addToLocalCart(payload);
+      // Khách vừa có giỏ trên server -> chuyển các món đang lưu localStorage lên
+      if (!isLoggedIn() && getLocalCart().items.length > 0) {
+        await syncCartToServer();
       }
-    } else {
-      // ✅ User chưa login -> Lưu vào localStorage
+      return res.data;
+
+    } catch (err) {
+      console.error("❌ Add to cart API failed:", err.message);
+      console.error("Error response:", err.response?.status, err.response?.data);
+      
+      // ✅ FIX: Luôn fallback về localStorage khi API thất bại
+      console.log("⚠️ Falling back to localStorage");
       return addToLocalCart(payload);
     }
   };
@@ -205,7 +208,7 @@ export const CartProvider = ({ children }) => {
   const updateQuantity = async (productId, selectedColor, selectedSize, newQty) => {
     console.log("🔄 Updating quantity:", { productId, selectedColor, selectedSize, newQty });
 
-    if (isLoggedIn()) {
+    if (hasServerCart()) {
       // Update trên server
       try {
         const res = await api.put("/cart/update", {
@@ -271,7 +274,7 @@ export const CartProvider = ({ children }) => {
   const removeItem = async (productId, selectedColor, selectedSize) => {
     console.log("🗑️ Removing item:", { productId, selectedColor, selectedSize });
 
-    if (isLoggedIn()) {
+    if (hasServerCart()) {
       // Remove từ server
       try {
         const res = await api.delete("/cart/remove", {
@@ -323,7 +326,7 @@ export const CartProvider = ({ children }) => {
   const clearCart = async () => {
     console.log("🗑️ Clearing cart");
 
-    if (isLoggedIn()) {
+    if (hasServerCart()) {
       try {
         await api.delete("/cart/clear");
         console.log("✅ Cleared server cart");
@@ -1,15 +1,29 @@
-import React, { useState, useEffect, useContext } from "react";
+import React, { useState, useEffect, useContext, useRef } from "react";
 import { useNavigate } from "react-router-dom";
 import { CartContext } from "../context/CartContext";
 import { toast } from "react-toastify";
-import axios from "axios";
-
-const API_URL = process.env.REACT_APP_API_URL;
+import api, { paymentAPI, saveOrderToken } from "../services/api";
+
+// Idempotency-Key ngẫu nhiên cho 1 lần đặt hàng
+const newIdempotencyKey = () =>
+  window.crypto?.randomUUID
+    ? window.crypto.randomUUID()
+    : `${Date.now()}-${Math.random().toString(36).slice(2)}`;
+
+// Tên hiển thị của cổng thanh toán online (key = tên provider server trả về)
+const PROVIDER_LABELS = {
+  mock: {
+    title: "Thanh toán online",
+    description: "Cổng thanh toán thử nghiệm (mock)",
+  },
+};
 
 const CheckoutPage = () => {
   const navigate = useNavigate();
   const { cart, clearCart } = useContext(CartContext);
   const [loading, setLoading] = useState(false);
+  // Giữ nguyên key khi gửi lại sau lỗi mạng để server không tạo đơn 2 lần
+  const idempotencyKey = useRef(null);
 
   // Thông tin khách hàng
   const [customerInfo, setCustomerInfo] = useState({
@@ -22,12 +36,51 @@ const CheckoutPage = () => {
 
   // Phương thức thanh toán
   const [paymentMethod, setPaymentMethod] = useState("COD");
+  const [providers, setProviders] = useState([]);
+
+  // Cổng thanh toán online đang bật trên server
+  useEffect(() => {
+    paymentAPI
+      .getProviders()
+      .then((res) => setProviders(res.data.providers || []))
+      .catch(() => setProviders([]));
+  }, []);
 
   // Tính toán giá
 const items = cart?.items || [];
-const subtotal = items.reduce((sum, item) => sum + item.price * item.qty, 0);
-  const shippingFee = subtotal >= 500000 ? 0 : 30000;
-  const total = subtotal + shippingFee;
+  const orderItems = items.map((item) => ({
+    productId: item.productId,
+    name: item.name,
+    price: item.price,
+    qty: item.qty,
+    image: item.image,
+    selectedColor: item.selectedColor || "Mặc định",
+    selectedSize: item.selectedSize || "One Size",
+  }));
+
+  // Giá, giảm giá và phí vận chuyển do server tính (/orders/quote) - giống hệt lúc tạo đơn
+  const [pricing, setPricing] = useState(null);
+  const itemsKey = JSON.stringify(orderItems);
+  useEffect(() => {
+    if (orderItems.length === 0) return;
+    api
+      .post("/orders/quote", {
+        items: orderItems,
+        couponCode: cart?.couponCode || undefined,
+      })
+      .then((res) => setPricing(res.data.pricing))
+      .catch((err) => {
+        console.warn("⚠️ Quote failed:", err.response?.data || err.message);
+        setPricing(null);
+      });
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [itemsKey, cart?.couponCode]);
+
+  const localSubtotal = items.reduce((sum, item) => sum + item.price * item.qty, 0);
+  const subtotal = pricing ? pricing.subtotal : localSubtotal;
+  const discount = pricing ? pricing.discount + (pricing.couponDiscount || 0) : 0;
+  const shippingFee = pricing ? pricing.shippingFee : 0;
+  const total = pricing ? pricing.grandTotal : localSubtotal;
 
   // Kiểm tra giỏ hàng rỗng
   useEffect(() => {
@@ -83,43 +136,49 @@ const subtotal = items.reduce((sum, item) => sum + item.price * item.qty, 0);
     setLoading(true);
 
     try {
-      // Lấy userId từ localStorage hoặc context
-      const userStr = localStorage.getItem("user");
-      const user = userStr ? JSON.parse(userStr) : null;
-      const userId = user?._id || "67..."; // Fallback nếu chưa đăng nhập
-
-      // Chuẩn bị dữ liệu order
+      // Chuẩn bị dữ liệu order (server lấy userId từ token đăng nhập)
       const orderData = {
-        userId: userId,
         customerName: customerInfo.customerName,
         customerEmail: customerInfo.customerEmail,
         customerPhone: customerInfo.customerPhone,
         address: customerInfo.address,
         note: customerInfo.note,
-        items: items.map((item) => ({
-          productId: item.productId,
-          name: item.name,
-          price: item.price,
-          qty: item.qty,
-          image: item.image,
-          selectedColor: item.selectedColor || "Mặc định",
-          selectedSize: item.selectedSize || "One Size",
-        })),
-        total: total,
+        items: orderItems,
+        // Chỉ gửi tổng tiền đã báo giá để server đối chiếu (409 nếu giá đổi)
+        total: pricing ? pricing.grandTotal : 0,
         status: "Chờ xác nhận",
         paymentMethod: paymentMethod,
       };
 
       console.log("📦 Order data:", orderData);
 
-      // Gửi request tạo order
-      const response = await axios.post(`${API_URL}/api/orders`, orderData);
+      // Gửi request tạo order (kèm token đăng nhập / guest cart token)
+      if (!idempotencyKey.current) {
+        idempotencyKey.current = newIdempotencyKey();
+      }
+      const response = await api.post("/orders", orderData, {
+        headers: { "Idempotency-Key": idempotencyKey.current },
+      });
+      idempotencyKey.current = null;
 
       console.log("✅ Order created:", response.data);
 
+      // Lưu order token để khách chưa đăng nhập xem được đơn vừa đặt
+      saveOrderToken(response.data._id, response.data.accessToken);
+
       // Xóa giỏ hàng
       await clearCart();
 
+      // Thanh toán online: chuyển khách sang cổng thanh toán, cổng redirect về /payment/result
+      if (response.data.paymentUrl) {
+        toast.info("Đang chuyển sang cổng thanh toán...");
+        window.location.href = response.data.paymentUrl;
+        return;
+      }
+      if (providers.includes(paymentMethod)) {
+        toast.warning("Chưa tạo được giao dịch thanh toán, bạn có thể thanh toán lại sau");
+      }
+
       // Thông báo thành công
       toast.success("🎉 Đặt hàng thành công!");
 
@@ -129,8 +188,19 @@ const subtotal = items.reduce((sum, item) => sum + item.price * item.qty, 0);
       }, 1500);
     } catch (error) {
       console.error("❌ Error creating order:", error);
+      // Server đã trả lời (giá đổi, hết hàng...) -> lần đặt sau là yêu cầu mới.
+      // Request cũ còn đang xử lý thì giữ key để lần bấm sau nhận đúng kết quả của nó
+      if (error.response && error.response.data?.code !== "IDEMPOTENCY_IN_PROGRESS") {
+        idempotencyKey.current = null;
+      }
+      // Giá thay đổi: hiển thị giá mới server trả về để khách xác nhận lại
+      if (error.response?.status === 409 && error.response.data?.pricing) {
+        setPricing(error.response.data.pricing);
+      }
       toast.error(
-        error.response?.data?.message || "Đặt hàng thất bại, vui lòng thử lại!"
+        error.response?.data?.error ||
+          error.response?.data?.message ||
+          "Đặt hàng thất bại, vui lòng thử lại!"
       );
     } finally {
       setLoading(false);
@@ -305,6 +375,31 @@ const subtotal = items.reduce((sum, item) => sum + item.price * item.qty, 0);
                   </div>
                 </label>
 
+                {providers.map((provider) => (
+                  <label
+                    key={provider}
+                    className="flex items-center p-4 border-2 border-gray-200 rounded-lg cursor-pointer hover:border-blue-500 transition"
+                  >
+                    <input
+                      type="radio"
+                      name="paymentMethod"
+                      value={provider}
+                      checked={paymentMethod === provider}
+                      onChange={(e) => setPaymentMethod(e.target.value)}
+                      className="w-5 h-5 text-blue-600"
+                    />
+                    <div className="ml-4 flex-1">
+                      <p className="font-semibold text-gray-900">
+                        {PROVIDER_LABELS[provider]?.title || provider}
+                      </p>
+                      <p className="text-sm text-gray-500">
+                        {PROVIDER_LABELS[provider]?.description ||
+                          "Chuyển sang cổng thanh toán để trả tiền online"}
+                      </p>
+                    </div>
+                  </label>
+                ))}
+
                 <label className="flex items-center p-4 border-2 border-gray-200 rounded-lg cursor-pointer hover:border-blue-500 transition opacity-50">
                   <input
                     type="radio"
@@ -372,6 +467,15 @@ const subtotal = items.reduce((sum, item) => sum + item.price * item.qty, 0);
                   </span>
                 </div>
 
+                {discount > 0 && (
+                  <div className="flex justify-between text-gray-600">
+                    <span>Giảm giá</span>
+                    <span className="font-semibold text-green-600">
+                      -{formatPrice(discount)}₫
+                    </span>
+                  </div>
+                )}
+
                 <div className="flex justify-between text-gray-600">
                   <span>Phí vận chuyển</span>
                   <span
@@ -383,7 +487,7 @@ const subtotal = items.reduce((sum, item) => sum + item.price * item.qty, 0);
                   </span>
                 </div>
 
-                {subtotal < 500000 && (
+                {shippingFee > 0 && subtotal < 500000 && (
                   <p className="text-xs text-gray-500">
                     💡 Mua thêm {formatPrice(500000 - subtotal)}₫ để được miễn
                     phí vận chuyển
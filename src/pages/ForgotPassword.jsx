@@ -0,0 +1,33 @@
+import { useState } from "react";
+import { Link } from "react-router-dom";
+import api from "../services/api";
+
+// Yêu cầu gửi link đặt lại mật khẩu qua email
+function ForgotPassword() {
+  const [email, setEmail] = useState("");
+  const [msg, setMsg] = useState("");
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    try {
+      const res = await api.post("/password/forgot", { email });
+      setMsg(res.data.message || "✅ Vui lòng kiểm tra email để đặt lại mật khẩu");
+    } catch (err) {
+      setMsg(err.response?.data?.error || "❌ Không thể gửi yêu cầu, vui lòng thử lại!");
+    }
+  };
+
+  return (
+    <div className="flex flex-col items-center min-h-screen justify-center bg-gray-100">
+      <h1 className="text-3xl font-bold mb-4">Quên mật khẩu</h1>
+      <form onSubmit={handleSubmit} className="flex flex-col gap-3 w-72">
+        <input className="border p-2 rounded" type="email" placeholder="Email" value={email}
+          onChange={(e) => setEmail(e.target.value)} required />
+        <button className="bg-blue-600 text-white p-2 rounded hover:bg-blue-700">Gửi link đặt lại mật khẩu</button>
+      </form>
+      <p className="mt-3">{msg}</p>
+      <Link to="/login" className="mt-3 text-blue-600 hover:text-blue-500">Quay lại đăng nhập</Link>
+    </div>
+  );
+}
+export default ForgotPassword;
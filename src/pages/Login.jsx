@@ -1,6 +1,6 @@
 import React, { useState, useContext } from "react";
 import { useNavigate } from "react-router-dom";
-import api, { setAuthToken } from "../services/api";
+import api, { setAuthToken, setRefreshToken, clearCartToken } from "../services/api";
 import { CartContext } from "../context/CartContext";
 
 const Login = () => {
@@ -12,6 +12,7 @@ const Login = () => {
     password: "",
   });
   const [error, setError] = useState("");
+  const [unverified, setUnverified] = useState(false);
   const [loading, setLoading] = useState(false);
 
   const handleChange = (e) => {
@@ -37,7 +38,10 @@ const Login = () => {
       // 2. Lưu token
       const token = res.data.token;
       setAuthToken(token);
+      setRefreshToken(res.data.refreshToken);
       localStorage.setItem("token", token);
+      // Server đã gộp giỏ hàng khách (X-Cart-Token) vào giỏ của user
+      clearCartToken();
       localStorage.setItem('userId', res.data.user._id);
 
       // 3. Lưu user info nếu có
@@ -76,6 +80,7 @@ const Login = () => {
                       err.response?.data?.error || 
                       "Đăng nhập thất bại. Vui lòng kiểm tra email và mật khẩu.";
       setError(errorMsg);
+      setUnverified(err.response?.data?.code === "EMAIL_NOT_VERIFIED");
     } finally {
       setLoading(false);
     }
@@ -105,6 +110,14 @@ const Login = () => {
               <div className="flex">
                 <div className="ml-3">
                   <h3 className="text-sm font-medium text-red-800">{error}</h3>
+                  {unverified && (
+                    <a
+                      href={`/verify-email?email=${encodeURIComponent(formData.email)}`}
+                      className="text-sm font-medium text-blue-600 hover:text-blue-500"
+                    >
+                      Gửi lại email xác nhận
+                    </a>
+                  )}
                 </div>
               </div>
             </div>
@@ -1,11 +1,10 @@
 import React, { useState, useEffect } from "react";
-import { useParams, Link, useNavigate } from "react-router-dom";
-import axios from "axios";
-
-const API_URL = process.env.REACT_APP_API_URL;
+import { useParams, Link, useNavigate, useSearchParams } from "react-router-dom";
+import api, { orderTokenHeaders, saveOrderToken } from "../services/api";
 
 const OrderSuccessPage = () => {
   const { orderId } = useParams();
+  const [searchParams] = useSearchParams();
   const navigate = useNavigate();
   const [order, setOrder] = useState(null);
   const [loading, setLoading] = useState(true);
@@ -18,7 +17,13 @@ const OrderSuccessPage = () => {
 
   const fetchOrder = async () => {
     try {
-      const response = await axios.get(`${API_URL}/api/orders/${orderId}`);
+      // Link trong email có ?token=... -> lưu lại để khách mở trên máy khác vẫn xem được đơn
+      saveOrderToken(orderId, searchParams.get("token"));
+
+      // Token đăng nhập do api tự gắn, khách dùng order token lưu khi đặt hàng
+      const response = await api.get(`/orders/${orderId}`, {
+        headers: orderTokenHeaders(orderId),
+      });
       setOrder(response.data);
       setLoading(false);
     } catch (error) {
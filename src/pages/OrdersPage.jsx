@@ -1,10 +1,8 @@
 import React, { useState, useEffect } from "react";
 import { Link } from "react-router-dom";
-import axios from "axios";
+import api from "../services/api";
 import Navbar from "../components/Navbar";
 
-const API_URL = process.env.REACT_APP_API_URL;
-
 const OrdersPage = () => {
   const [orders, setOrders] = useState([]);
   const [loading, setLoading] = useState(true);
@@ -21,12 +19,8 @@ const OrdersPage = () => {
 
   const fetchOrders = async () => {
     try {
-      const userId = localStorage.getItem("userId");
-      
-      // Gọi API với userId trong query params
-      const response = await axios.get(
-        `${API_URL}/api/orders?userId=${userId}`
-      );
+      // Server lấy user từ token đăng nhập (api tự gắn header Authorization)
+      const response = await api.get("/orders");
       
       // Mapping trạng thái tiếng Việt sang tiếng Anh
       const normalizedOrders = response.data.map(order => ({
@@ -78,8 +72,8 @@ const OrdersPage = () => {
 
     setCancellingOrder(true);
     try {
-      const response = await axios.put(
-        `${API_URL}/api/orders/${selectedOrder._id}/cancel`,
+      const response = await api.put(
+        `/orders/${selectedOrder._id}/cancel`,
         {
           cancelReason: cancelReason,
           status: "Đã hủy"
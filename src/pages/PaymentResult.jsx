@@ -0,0 +1,95 @@
+import { useEffect, useRef, useState } from "react";
+import { Link, useLocation, useParams } from "react-router-dom";
+import { paymentAPI } from "../services/api";
+
+// Cổng thanh toán redirect về: /payment/result/:provider?orderId=...&signature=...
+// Gửi nguyên query string cho server kiểm tra chữ ký, không tin kết quả trên URL
+function PaymentResult() {
+  const { provider } = useParams();
+  const { search } = useLocation();
+  const [status, setStatus] = useState("verifying");
+  const [result, setResult] = useState(null);
+  const [msg, setMsg] = useState("");
+  const [paying, setPaying] = useState(false);
+  const sent = useRef(false);
+
+  useEffect(() => {
+    if (sent.current) return;
+    sent.current = true;
+
+    paymentAPI
+      .verifyReturn(provider, search)
+      .then((res) => {
+        setResult(res.data);
+        setStatus(res.data.paymentStatus === "paid" ? "paid" : "failed");
+        setMsg(res.data.message || "");
+      })
+      .catch((err) => {
+        setStatus("invalid");
+        setMsg(err.response?.data?.error || "Không thể kiểm tra kết quả thanh toán");
+      });
+  }, [provider, search]);
+
+  // Thanh toán lại: server tạo giao dịch mới rồi chuyển khách sang cổng thanh toán
+  const handleRetry = async () => {
+    setPaying(true);
+    try {
+      const res = await paymentAPI.pay(result.orderId);
+      window.location.href = res.data.paymentUrl;
+    } catch (err) {
+      setMsg(err.response?.data?.error || "Không thể tạo giao dịch thanh toán");
+      setPaying(false);
+    }
+  };
+
+  return (
+    <div className="flex flex-col items-center min-h-screen justify-center bg-gray-100">
+      <h1 className="text-3xl font-bold mb-4">Kết quả thanh toán</h1>
+
+      {status === "verifying" && <p>Đang kiểm tra giao dịch...</p>}
+
+      {status === "paid" && (
+        <>
+          <p className="mb-3">✅ Thanh toán thành công</p>
+          <Link
+            to={`/order-success/${result.orderId}`}
+            className="bg-blue-600 text-white p-2 rounded hover:bg-blue-700"
+          >
+            Xem đơn hàng
+          </Link>
+        </>
+      )}
+
+      {status === "failed" && (
+        <>
+          <p className="mb-3">❌ Thanh toán chưa thành công{msg && `: ${msg}`}</p>
+          <div className="flex gap-3">
+            <button
+              onClick={handleRetry}
+              disabled={paying}
+              className="bg-blue-600 text-white p-2 rounded hover:bg-blue-700 disabled:bg-gray-400"
+            >
+              {paying ? "Đang chuyển..." : "Thanh toán lại"}
+            </button>
+            <Link
+              to={`/order-success/${result.orderId}`}
+              className="border border-gray-300 p-2 rounded hover:bg-gray-200"
+            >
+              Xem đơn hàng
+            </Link>
+          </div>
+        </>
+      )}
+
+      {status === "invalid" && (
+        <>
+          <p className="mb-3">{msg}</p>
+          <Link to="/orders" className="bg-blue-600 text-white p-2 rounded hover:bg-blue-700">
+            Đơn hàng của tôi
+          </Link>
+        </>
+      )}
+    </div>
+  );
+}
+export default PaymentResult;
@@ -1,12 +1,10 @@
 import React, { useState, useEffect, useContext } from "react";
 import { useParams, Link, useNavigate } from "react-router-dom";
-import axios from "axios";
+import { productAPI } from "../services/api";
 import ProductCard from "../components/ProductCard";
 import { toast } from "react-toastify";
 import { CartContext } from "../context/CartContext";
 
-const API_URL = process.env.REACT_APP_API_URL;
-
 const ProductDetailPage = () => {
   const { id } = useParams();
   const navigate = useNavigate();
@@ -33,7 +31,7 @@ const ProductDetailPage = () => {
       setError(null);
       
       console.log("Fetching product with ID:", id);
-      const response = await axios.get(`${API_URL}/api/products/${id}`);
+      const response = await productAPI.getById(id);
       console.log("Product response:", response.data);
       
       setProduct(response.data);
@@ -62,7 +60,7 @@ const ProductDetailPage = () => {
         console.warn("No product ID provided for fetching related products");
         return;
       }
-      const response = await axios.get(`${API_URL}/api/products/${productId}/related`);
+      const response = await productAPI.getRelated(productId);
       setRelatedProducts(response.data);
     } catch (error) {
       console.error("Error fetching related products:", error);
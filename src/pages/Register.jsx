@@ -1,21 +1,18 @@
 import { useState } from "react";
 import api from "../services/api";
-import { useNavigate } from "react-router";
 
 function Register() {
   const [form, setForm] = useState({ name: "", email: "", password: "" });
   const [msg, setMsg] = useState("");
-  let navigate = useNavigate();
 
   const handleSubmit = async (e) => {
     e.preventDefault();
     try {
       await api.post("/register", form);
-      alert("✅ Đăng ký thành công!")
-      setMsg("✅ Đăng ký thành công!");
-      navigate("/login");
+      // Tài khoản cần xác nhận email trước khi đăng nhập
+      setMsg("✅ Đăng ký thành công! Vui lòng mở link trong email để xác nhận tài khoản.");
     } catch (err) {
-      setMsg("❌ Lỗi đăng ký!");
+      setMsg(err.response?.data?.error || "❌ Lỗi đăng ký!");
     }
   };
 
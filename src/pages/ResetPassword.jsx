@@ -0,0 +1,51 @@
+import { useState } from "react";
+import { Link, useNavigate, useSearchParams } from "react-router-dom";
+import api from "../services/api";
+
+// Trang mở từ link trong email đặt lại mật khẩu: /reset-password?token=...
+function ResetPassword() {
+  const [params] = useSearchParams();
+  const token = params.get("token");
+  const [form, setForm] = useState({ password: "", confirm: "" });
+  const [msg, setMsg] = useState("");
+  const navigate = useNavigate();
+
+  const handleSubmit = async (e) => {
+    e.preventDefault();
+    if (form.password !== form.confirm) {
+      setMsg("❌ Mật khẩu nhập lại không khớp");
+      return;
+    }
+    try {
+      await api.post("/password/reset", { token, password: form.password });
+      alert("✅ Đặt lại mật khẩu thành công, vui lòng đăng nhập lại!");
+      navigate("/login");
+    } catch (err) {
+      setMsg(err.response?.data?.error || "❌ Không thể đặt lại mật khẩu!");
+    }
+  };
+
+  if (!token) {
+    return (
+      <div className="flex flex-col items-center min-h-screen justify-center bg-gray-100">
+        <p className="mb-3">Link đặt lại mật khẩu không hợp lệ.</p>
+        <Link to="/forgot-password" className="text-blue-600 hover:text-blue-500">Gửi lại link</Link>
+      </div>
+    );
+  }
+
+  return (
+    <div className="flex flex-col items-center min-h-screen justify-center bg-gray-100">
+      <h1 className="text-3xl font-bold mb-4">Đặt lại mật khẩu</h1>
+      <form onSubmit={handleSubmit} className="flex flex-col gap-3 w-72">
+        <input className="border p-2 rounded" type="password" placeholder="Mật khẩu mới" required
+          onChange={(e) => setForm({ ...form, password: e.target.value })} />
+        <input className="border p-2 rounded" type="password" placeholder="Nhập lại mật khẩu" required
+          onChange={(e) => setForm({ ...form, confirm: e.target.value })} />
+        <button className="bg-blue-600 text-white p-2 rounded hover:bg-blue-700">Đặt lại mật khẩu</button>
+      </form>
+      <p className="mt-3">{msg}</p>
+    </div>
+  );
+}
+export default ResetPassword;
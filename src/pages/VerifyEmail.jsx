@@ -0,0 +1,71 @@
+import { useEffect, useRef, useState } from "react";
+import { Link, useSearchParams } from "react-router-dom";
+import api from "../services/api";
+
+// Trang mở từ link trong email xác nhận: /verify-email?token=...
+function VerifyEmail() {
+  const [params] = useSearchParams();
+  const token = params.get("token");
+  const [status, setStatus] = useState(token ? "verifying" : "invalid");
+  const [msg, setMsg] = useState("");
+  const [email, setEmail] = useState(params.get("email") || "");
+  const sent = useRef(false);
+
+  useEffect(() => {
+    // Token chỉ dùng được 1 lần -> tránh gọi 2 lần khi StrictMode chạy effect lại
+    if (!token || sent.current) return;
+    sent.current = true;
+
+    api
+      .post("/email/verify", { token })
+      .then((res) => {
+        setStatus("verified");
+        setMsg(res.data.message || "Xác nhận email thành công");
+      })
+      .catch((err) => {
+        setStatus("invalid");
+        setMsg(err.response?.data?.error || "Link xác nhận không hợp lệ hoặc đã hết hạn");
+      });
+  }, [token]);
+
+  const handleResend = async (e) => {
+    e.preventDefault();
+    try {
+      const res = await api.post("/email/resend", { email });
+      setMsg(res.data.message);
+    } catch (err) {
+      setMsg(err.response?.data?.error || "❌ Không thể gửi lại email xác nhận");
+    }
+  };
+
+  return (
+    <div className="flex flex-col items-center min-h-screen justify-center bg-gray-100">
+      <h1 className="text-3xl font-bold mb-4">Xác nhận email</h1>
+
+      {status === "verifying" && <p>Đang xác nhận...</p>}
+
+      {status === "verified" && (
+        <>
+          <p className="mb-3">✅ {msg}</p>
+          <Link to="/login" className="bg-blue-600 text-white p-2 rounded hover:bg-blue-700">
+            Đăng nhập
+          </Link>
+        </>
+      )}
+
+      {status === "invalid" && (
+        <>
+          {msg && <p className="mb-3">{msg}</p>}
+          <form onSubmit={handleResend} className="flex flex-col gap-3 w-72">
+            <input className="border p-2 rounded" type="email" placeholder="Email" value={email}
+              onChange={(e) => setEmail(e.target.value)} required />
+            <button className="bg-blue-600 text-white p-2 rounded hover:bg-blue-700">
+              Gửi lại email xác nhận
+            </button>
+          </form>
+        </>
+      )}
+    </div>
+  );
+}
+export default VerifyEmail;
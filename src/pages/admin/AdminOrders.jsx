@@ -2,56 +2,61 @@ import React, { useState, useEffect } from "react";
 import { Link } from "react-router-dom";
 import api from "../../services/api"; // ✅ Import api service
 
+const PAGE_SIZE = 20;
+
 const AdminOrders = () => {
   const [orders, setOrders] = useState([]);
-  const [filteredOrders, setFilteredOrders] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [pages, setPages] = useState(0);
+  const [page, setPage] = useState(1);
   const [statusFilter, setStatusFilter] = useState("all");
   const [searchTerm, setSearchTerm] = useState("");
+  const [search, setSearch] = useState("");
+  const [sort, setSort] = useState("newest");
   const [selectedOrder, setSelectedOrder] = useState(null);
   const [showDetailModal, setShowDetailModal] = useState(false);
   const [loading, setLoading] = useState(true);
+  const [loaded, setLoaded] = useState(false);
   const [error, setError] = useState(null);
 
+  // ✅ Chờ người dùng gõ xong rồi mới tìm trên server
   useEffect(() => {
-    fetchOrders();
-  }, []);
+    const timer = setTimeout(() => {
+      setSearch(searchTerm.trim());
+      setPage(1);
+    }, 400);
+    return () => clearTimeout(timer);
+  }, [searchTerm]);
 
   useEffect(() => {
-    filterOrders();
-  }, [statusFilter, searchTerm, orders]);
+    fetchOrders();
+  }, [page, statusFilter, search, sort]);
 
   const fetchOrders = async () => {
     try {
       setLoading(true);
       setError(null);
-      
-      // ✅ Kiểm tra user và token
-      const token = localStorage.getItem("token");
-      const user = JSON.parse(localStorage.getItem("user") || "{}");
-      console.log("👤 User:", user);
-      console.log("🔑 Token exists:", !!token);
-      console.log("👮 Is Admin:", user.isAdmin);
-      
-      console.log("🔄 Fetching orders...");
-      
-      // ✅ Gọi endpoint admin (giống Dashboard)
-      const response = await api.get("/admin/orders");
-      
-      console.log("✅ Response:", response);
-      console.log("✅ Response data:", response.data);
-      console.log("✅ Is Array:", Array.isArray(response.data));
-      
-      // ✅ Set orders từ response.data
-      const ordersData = Array.isArray(response.data) ? response.data : [];
-      console.log("✅ Setting orders:", ordersData.length, "items");
+
+      // ✅ Lọc, tìm kiếm, sắp xếp và phân trang trên server
+      // ✅ API trả về dạng phân trang { total, page, limit, pages, orders }
+      const params = { page, limit: PAGE_SIZE, sort };
+      if (statusFilter !== "all") params.status = statusFilter;
+      if (search) params.search = search;
+
+      const response = await api.get("/admin/orders", { params });
+
+      const ordersData = Array.isArray(response.data?.orders) ? response.data.orders : [];
       setOrders(ordersData);
-      
+      setTotal(response.data?.total || 0);
+      setPages(response.data?.pages || 0);
     } catch (err) {
       console.error("❌ Error fetching orders:", err);
       console.error("❌ Error response:", err.response);
       
       if (err.response?.status === 401) {
         setError("Phiên đăng nhập đã hết hạn. Vui lòng đăng nhập lại.");
+      } else if (err.response?.status === 400) {
+        setError(err.response.data?.error || "Bộ lọc không hợp lệ");
       } else if (err.response?.status === 404) {
         setError("Endpoint không tồn tại. Kiểm tra backend.");
       } else if (err.message.includes('Network Error')) {
@@ -60,35 +65,22 @@ const AdminOrders = () => {
         setError(`Không thể tải danh sách đơn hàng: ${err.message}`);
       }
       setOrders([]);
+      setTotal(0);
+      setPages(0);
     } finally {
       setLoading(false);
+      setLoaded(true);
     }
   };
 
-  const filterOrders = () => {
-    let filtered = [...orders];
-
-    // Filter by status
-    if (statusFilter !== "all") {
-      filtered = filtered.filter(order => order.status === statusFilter);
-    }
-
-    // Filter by search term
-    if (searchTerm) {
-      filtered = filtered.filter(order => {
-        const orderId = order._id || "";
-        const customerName = order.customerName || "";
-        const customerEmail = order.customerEmail || "";
-        
-        return (
-          orderId.toLowerCase().includes(searchTerm.toLowerCase()) ||
-          customerName.toLowerCase().includes(searchTerm.toLowerCase()) ||
-          customerEmail.toLowerCase().includes(searchTerm.toLowerCase())
-        );
-      });
-    }
+  const changeStatusFilter = (status) => {
+    setStatusFilter(status);
+    setPage(1);
+  };
 
-    setFilteredOrders(filtered);
+  const changeSort = (value) => {
+    setSort(value);
+    setPage(1);
   };
 
   const updateOrderStatus = async (orderId, newStatus) => {
@@ -96,16 +88,15 @@ const AdminOrders = () => {
       // ✅ Dùng api service
       await api.put(`/orders/${orderId}`, { status: newStatus });
 
-      // Cập nhật local state
-      setOrders(orders.map(order =>
-        order._id === orderId ? { ...order, status: newStatus } : order
-      ));
-      
+      // Tải lại trang hiện tại (đơn có thể không còn khớp bộ lọc)
+      fetchOrders();
+
       setShowDetailModal(false);
       alert("Cập nhật trạng thái thành công!");
     } catch (err) {
       console.error("Error updating order status:", err);
-      alert("Không thể cập nhật trạng thái đơn hàng");
+      // Vd: hãng vận chuyển đã lấy hàng nên không hủy được đơn
+      alert(err.response?.data?.error || "Không thể cập nhật trạng thái đơn hàng");
     }
   };
 
@@ -150,7 +141,8 @@ const AdminOrders = () => {
     setShowDetailModal(true);
   };
 
-  if (loading) {
+  // Spinner toàn trang chỉ ở lần tải đầu, để ô tìm kiếm không mất focus khi tải lại
+  if (loading && !loaded) {
     return (
       <div className="min-h-screen bg-gray-100 flex items-center justify-center">
         <div className="text-center">
@@ -184,15 +176,14 @@ const AdminOrders = () => {
           </div>
         )}
 
-        {/* Debug Info */}
-        {!loading && (
-          <div className="bg-blue-50 border border-blue-200 text-blue-700 px-4 py-3 rounded mb-6">
-            <p className="text-sm">
-              <strong>Debug:</strong> Tìm thấy {orders.length} đơn hàng | 
-              Hiển thị {filteredOrders.length} đơn hàng sau filter
-            </p>
-          </div>
-        )}
+        {/* Summary */}
+        <div className="bg-blue-50 border border-blue-200 text-blue-700 px-4 py-3 rounded mb-6">
+          <p className="text-sm">
+            Tìm thấy <strong>{total}</strong> đơn hàng
+            {pages > 0 && ` | Trang ${page}/${pages}`}
+            {loading && " | Đang tải..."}
+          </p>
+        </div>
 
         {/* Filters */}
         <div className="bg-white rounded-lg shadow p-6 mb-6">
@@ -201,19 +192,33 @@ const AdminOrders = () => {
             <div>
               <input
                 type="text"
-                placeholder="Tìm theo mã đơn, tên KH, email..."
+                placeholder="Tìm theo mã đơn, tên KH, email, SĐT..."
                 value={searchTerm}
                 onChange={(e) => setSearchTerm(e.target.value)}
                 className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
               />
             </div>
 
+            {/* Sort */}
+            <div>
+              <select
+                value={sort}
+                onChange={(e) => changeSort(e.target.value)}
+                className="w-full px-4 py-2 border border-gray-300 rounded-lg focus:outline-none focus:ring-2 focus:ring-blue-500"
+              >
+                <option value="newest">Mới nhất</option>
+                <option value="oldest">Cũ nhất</option>
+                <option value="total_desc">Tổng tiền giảm dần</option>
+                <option value="total_asc">Tổng tiền tăng dần</option>
+              </select>
+            </div>
+
             {/* Status Filter */}
-            <div className="flex gap-2 flex-wrap">
+            <div className="flex gap-2 flex-wrap md:col-span-2">
               {["all", "Chờ xác nhận", "Đã xác nhận", "Đang giao", "Đã giao", "Đã hủy"].map(status => (
                 <button
                   key={status}
-                  onClick={() => setStatusFilter(status)}
+                  onClick={() => changeStatusFilter(status)}
                   className={`px-4 py-2 rounded-lg transition ${
                     statusFilter === status
                       ? "bg-blue-600 text-white"
@@ -244,11 +249,11 @@ const AdminOrders = () => {
                 </tr>
               </thead>
               <tbody className="divide-y divide-gray-200">
-                {filteredOrders.map((order) => (
+                {orders.map((order) => (
                   <tr key={order._id} className="hover:bg-gray-50">
                     <td className="px-6 py-4 whitespace-nowrap">
                       <span className="text-sm font-medium text-blue-600">
-                        {order._id ? order._id.slice(-6).toUpperCase() : "N/A"}
+                        {order.orderNumber || (order._id ? order._id.slice(-6).toUpperCase() : "N/A")}
                       </span>
                     </td>
                     <td className="px-6 py-4">
@@ -267,6 +272,11 @@ const AdminOrders = () => {
                     </td>
                     <td className="px-6 py-4 whitespace-nowrap">
                       <span className="text-sm text-gray-900">{order.paymentMethod}</span>
+                      {order.refundRequired && (
+                        <span className="ml-2 px-2 py-1 text-xs font-semibold rounded-full bg-red-100 text-red-800">
+                          Cần hoàn tiền
+                        </span>
+                      )}
                     </td>
                     <td className="px-6 py-4 whitespace-nowrap">
                       <span className={`px-2 py-1 text-xs font-semibold rounded-full ${getStatusColor(order.status)}`}>
@@ -290,19 +300,42 @@ const AdminOrders = () => {
             </table>
           </div>
 
-          {filteredOrders.length === 0 && !loading && (
+          {orders.length === 0 && !loading && (
             <div className="text-center py-12">
               <svg className="w-16 h-16 mx-auto mb-4 text-gray-400" fill="none" stroke="currentColor" viewBox="0 0 24 24">
                 <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M9 5H7a2 2 0 00-2 2v12a2 2 0 002 2h10a2 2 0 002-2V7a2 2 0 00-2-2h-2M9 5a2 2 0 002 2h2a2 2 0 002-2M9 5a2 2 0 012-2h2a2 2 0 012 2" />
               </svg>
               <h3 className="text-xl font-semibold text-gray-700">Không tìm thấy đơn hàng</h3>
               <p className="text-gray-500 mt-2">
-                {searchTerm || statusFilter !== "all" 
+                {search || statusFilter !== "all" 
                   ? "Thử thay đổi bộ lọc để xem kết quả khác" 
                   : "Chưa có đơn hàng nào trong hệ thống"}
               </p>
             </div>
           )}
+
+          {/* Pagination */}
+          {pages > 1 && (
+            <div className="flex items-center justify-between px-6 py-4 border-t">
+              <button
+                onClick={() => setPage(page - 1)}
+                disabled={page <= 1 || loading}
+                className="px-4 py-2 rounded-lg bg-gray-200 text-gray-700 hover:bg-gray-300 disabled:opacity-50"
+              >
+                ← Trước
+              </button>
+              <span className="text-sm text-gray-600">
+                Trang {page} / {pages}
+              </span>
+              <button
+                onClick={() => setPage(page + 1)}
+                disabled={page >= pages || loading}
+                className="px-4 py-2 rounded-lg bg-gray-200 text-gray-700 hover:bg-gray-300 disabled:opacity-50"
+              >
+                Sau →
+              </button>
+            </div>
+          )}
         </div>
       </div>
 
@@ -313,7 +346,7 @@ const AdminOrders = () => {
             {/* Modal Header */}
             <div className="p-6 border-b flex items-center justify-between">
               <h2 className="text-2xl font-bold">
-                Chi tiết đơn hàng #{selectedOrder._id ? selectedOrder._id.slice(-6).toUpperCase() : "N/A"}
+                Chi tiết đơn hàng #{selectedOrder.orderNumber || (selectedOrder._id ? selectedOrder._id.slice(-6).toUpperCase() : "N/A")}
               </h2>
               <button
                 onClick={() => setShowDetailModal(false)}
@@ -388,6 +421,11 @@ const AdminOrders = () => {
                   <span>Phương thức thanh toán:</span>
                   <span className="font-semibold">{selectedOrder.paymentMethod}</span>
                 </div>
+                {selectedOrder.refundRequired && (
+                  <p className="text-sm text-red-600 mt-1">
+                    ⚠️ Khách đã thanh toán sau khi đơn bị hủy, cần hoàn tiền tay
+                  </p>
+                )}
                 <div className="flex justify-between items-center text-sm text-gray-600 mt-1">
                   <span>Ngày đặt hàng:</span>
                   <span>{formatDate(selectedOrder.createdAt)}</span>
@@ -1,5 +1,6 @@
 import React, { useState, useEffect } from "react";
 import { Link } from "react-router-dom";
+import api from "../../services/api";
 
 const AdminProducts = () => {
   const [products, setProducts] = useState([]);
@@ -37,10 +38,8 @@ const AdminProducts = () => {
   const fetchProducts = async () => {
     try {
       setLoading(true);
-      const response = await fetch("http://localhost:10000/api/products?limit=9999");
-      const data = await response.json();
-      console.log("✅ Products:", data);
-      setProducts(data.products || []);
+      const response = await api.get("/products", { params: { limit: 9999 } });
+      setProducts(response.data?.products || []);
       setLoading(false);
     } catch (error) {
       console.error("❌ Error fetching products:", error);
@@ -89,8 +88,6 @@ const AdminProducts = () => {
 
   const addProduct = async () => {
     try {
-      const token = localStorage.getItem("token");
-      
       // Auto generate slug from name
       const slug = selectedProduct.name
         .toLowerCase()
@@ -111,83 +108,67 @@ const AdminProducts = () => {
         reviewCount: parseInt(selectedProduct.reviewCount) || 0, // ✅ Thêm reviewCount
       };
 
-      const response = await fetch("http://localhost:10000/api/admin/products", {
-        method: "POST",
-        headers: {
-          "Content-Type": "application/json",
-          Authorization: `Bearer ${token}`,
-        },
-        body: JSON.stringify(productData),
-      });
-
-      if (response.ok) {
-        console.log("✅ Product added");
-        fetchProducts();
-        setShowAddModal(false);
-        alert("Thêm sản phẩm thành công!");
-      } else {
-        const error = await response.json();
-        alert(`Thêm sản phẩm thất bại: ${error.error || "Unknown error"}`);
-      }
+      await api.post("/admin/products", productData);
+      console.log("✅ Product added");
+      fetchProducts();
+      setShowAddModal(false);
+      alert("Thêm sản phẩm thành công!");
     } catch (error) {
       console.error("❌ Add error:", error);
-      alert("Có lỗi xảy ra!");
+      if (error.response) {
+        alert(`Thêm sản phẩm thất bại: ${error.response.data?.error || "Unknown error"}`);
+      } else {
+        alert("Có lỗi xảy ra!");
+      }
     }
   };
 
   const saveProduct = async () => {
     try {
-      const token = localStorage.getItem("token");
-      const response = await fetch(
-        `http://localhost:10000/api/admin/products/${selectedProduct._id}`,
-        {
-          method: "PUT",
-          headers: {
-            "Content-Type": "application/json",
-            Authorization: `Bearer ${token}`,
-          },
-          body: JSON.stringify(selectedProduct),
-        }
-      );
-
-      if (response.ok) {
-        console.log("✅ Product updated");
-        fetchProducts();
-        setShowEditModal(false);
-        alert("Cập nhật sản phẩm thành công!");
-      } else {
-        alert("Cập nhật sản phẩm thất bại!");
-      }
+      await api.put(`/admin/products/${selectedProduct._id}`, selectedProduct);
     } catch (error) {
       console.error("❌ Update error:", error);
-      alert("Có lỗi xảy ra!");
+      alert(error.response ? "Cập nhật sản phẩm thất bại!" : "Có lỗi xảy ra!");
+      return;
     }
+    console.log("✅ Product updated");
+
+    // Tồn kho không sửa qua PUT sản phẩm - sản phẩm không có phân loại đặt lại qua endpoint kho
+    const original = products.find((p) => p._id === selectedProduct._id);
+    const stock = parseInt(selectedProduct.stock) || 0;
+    if (!selectedProduct.variants?.length && original && original.stock !== stock) {
+      try {
+        // Gửi kèm stockVersion lúc mở form: có đơn đặt/hủy xen giữa thì server trả 409, không ghi đè
+        await api.put(`/admin/products/${selectedProduct._id}/variants`, {
+          variants: [],
+          stock,
+          stockVersion: original.stockVersion || 0,
+        });
+      } catch (error) {
+        console.error("❌ Stock update error:", error);
+        alert(
+          error.response?.data?.code === "STOCK_CHANGED"
+            ? error.response.data.error
+            : "Cập nhật tồn kho thất bại!"
+        );
+      }
+    }
+
+    fetchProducts();
+    setShowEditModal(false);
+    alert("Cập nhật sản phẩm thành công!");
   };
 
   const confirmDelete = async () => {
     try {
-      const token = localStorage.getItem("token");
-      const response = await fetch(
-        `http://localhost:10000/api/admin/products/${selectedProduct._id}`,
-        {
-          method: "DELETE",
-          headers: {
-            Authorization: `Bearer ${token}`,
-          },
-        }
-      );
-
-      if (response.ok) {
-        console.log("✅ Product deleted");
-        fetchProducts();
-        setShowDeleteModal(false);
-        alert("Xóa sản phẩm thành công!");
-      } else {
-        alert("Xóa sản phẩm thất bại!");
-      }
+      await api.delete(`/admin/products/${selectedProduct._id}`);
+      console.log("✅ Product deleted");
+      fetchProducts();
+      setShowDeleteModal(false);
+      alert("Xóa sản phẩm thành công!");
     } catch (error) {
       console.error("❌ Delete error:", error);
-      alert("Có lỗi xảy ra!");
+      alert(error.response ? "Xóa sản phẩm thất bại!" : "Có lỗi xảy ra!");
     }
   };
 
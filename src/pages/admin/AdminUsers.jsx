@@ -1,5 +1,6 @@
 import React, { useState, useEffect } from "react";
 import { Link } from "react-router-dom";
+import api from "../../services/api";
 
 const AdminUsers = () => {
   const [users, setUsers] = useState([]);
@@ -19,14 +20,8 @@ const AdminUsers = () => {
 
   const fetchUsers = async () => {
     try {
-      const res = await fetch("http://localhost:10000/api/admin/users", {
-        headers: {
-          "Authorization": `Bearer ${localStorage.getItem("token")}`
-        }
-      });
-      const data = await res.json();
-      console.log("✅ Fetched users:", data);
-      setUsers(data);
+      const res = await api.get("/admin/users");
+      setUsers(Array.isArray(res.data) ? res.data : []);
     } catch (error) {
       console.error("❌ Error fetching users:", error);
     }
@@ -27,6 +27,75 @@ export const setAuthToken = (token) => {
   }
 };
 
+// Refresh token: dùng để lấy access token mới khi access token hết hạn
+const REFRESH_TOKEN_KEY = "refreshToken";
+
+export const setRefreshToken = (token) => {
+  if (token) {
+    localStorage.setItem(REFRESH_TOKEN_KEY, token);
+  } else {
+    localStorage.removeItem(REFRESH_TOKEN_KEY);
+  }
+};
+
+// logout: thu hồi refresh token trên server rồi xóa token phía client
+export const logout = async () => {
+  const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+  if (refreshToken) {
+    try {
+      await axios.post(`${API_URL}/api/logout`, { refreshToken });
+    } catch (e) {
+      // token hết hạn / đã thu hồi cũng coi như đã đăng xuất
+    }
+  }
+  setAuthToken(null);
+  setRefreshToken(null);
+};
+
+// Nhiều request cùng nhận 401 chỉ gọi refresh 1 lần
+let refreshing = null;
+
+const refreshAccessToken = () => {
+  if (!refreshing) {
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+    refreshing = (refreshToken
+      ? axios.post(`${API_URL}/api/token/refresh`, { refreshToken })
+      : Promise.reject(new Error("no refresh token"))
+    )
+      .then((res) => {
+        setAuthToken(res.data.token);
+        setRefreshToken(res.data.refreshToken);
+        return res.data.token;
+      })
+      .finally(() => {
+        refreshing = null;
+      });
+  }
+  return refreshing;
+};
+
+// Giỏ hàng khách chưa đăng nhập: server cấp token qua header X-Cart-Token
+const CART_TOKEN_KEY = "gosporty_cart_token";
+const CART_TOKEN_HEADER = "X-Cart-Token";
+
+export const getCartToken = () => localStorage.getItem(CART_TOKEN_KEY);
+
+export const clearCartToken = () => localStorage.removeItem(CART_TOKEN_KEY);
+
+// Order access token: khách không đăng nhập dùng để xem / hủy đơn vừa đặt
+const ORDER_TOKEN_PREFIX = "gosporty_order_token_";
+
+export const saveOrderToken = (orderId, token) => {
+  if (orderId && token) {
+    localStorage.setItem(ORDER_TOKEN_PREFIX + orderId, token);
+  }
+};
+
+export const orderTokenHeaders = (orderId) => {
+  const token = localStorage.getItem(ORDER_TOKEN_PREFIX + orderId);
+  return token ? { "X-Order-Token": token } : {};
+};
+
 // interceptor: tự động attach token từ localStorage nếu có
 api.interceptors.request.use(
   (config) => {
@@ -36,6 +105,12 @@ api.interceptors.request.use(
         config.headers = config.headers || {};
         config.headers.Authorization = `Bearer ${token}`;
       }
+      // Gửi kèm guest cart token (giỏ hàng, đặt hàng, đăng nhập để gộp giỏ)
+      const cartToken = getCartToken();
+      if (cartToken) {
+        config.headers = config.headers || {};
+        config.headers[CART_TOKEN_HEADER] = cartToken;
+      }
     } catch (e) {
       // ignore
     }
@@ -46,12 +121,34 @@ api.interceptors.request.use(
 
 // optional: response interceptor để log lỗi 401/403
 api.interceptors.response.use(
-  (res) => res,
-  (err) => {
-    // nếu token expired => clear localStorage và chuyển về login nếu cần
-    if (err?.response?.status === 401) {
-      // console.warn("API: unauthorized - clearing token");
-      // setAuthToken(null); // uncomment nếu muốn tự động logout
+  (res) => {
+    // Lưu guest cart token server vừa cấp
+    const cartToken = res.headers?.[CART_TOKEN_HEADER.toLowerCase()];
+    if (cartToken) {
+      localStorage.setItem(CART_TOKEN_KEY, cartToken);
+    }
+    return res;
+  },
+  async (err) => {
+    // access token hết hạn => đổi refresh token lấy token mới rồi gửi lại request 1 lần
+    const original = err?.config;
+    if (
+      err?.response?.status === 401 &&
+      original &&
+      !original._retried &&
+      localStorage.getItem(REFRESH_TOKEN_KEY)
+    ) {
+      original._retried = true;
+      try {
+        const token = await refreshAccessToken();
+        original.headers = original.headers || {};
+        original.headers.Authorization = `Bearer ${token}`;
+        return api(original);
+      } catch (refreshErr) {
+        // refresh token hết hạn / bị thu hồi => đăng xuất phía client
+        setAuthToken(null);
+        setRefreshToken(null);
+      }
     }
     return Promise.reject(err);
   }
@@ -67,4 +164,12 @@ export const productAPI = {
   delete: (id) => api.delete(`/products/${id}`),
 };
 
+// Thanh toán online: danh sách cổng, kiểm tra kết quả redirect về, thanh toán lại
+export const paymentAPI = {
+  getProviders: () => api.get("/payments/providers"),
+  verifyReturn: (provider, search) => api.get(`/payments/${provider}/return${search}`),
+  pay: (orderId) =>
+    api.post(`/orders/${orderId}/pay`, null, { headers: orderTokenHeaders(orderId) }),
+};
+
 export default api;